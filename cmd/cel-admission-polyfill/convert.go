@@ -0,0 +1,237 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	controllerv0alpha2 "github.com/alexzielenski/cel_polyfill/pkg/controller/celadmissionpolyfill.k8s.io/v0alpha2"
+	"github.com/alexzielenski/cel_polyfill/pkg/convert"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/scheme"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// Flag which may be given more than once
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Converts PolicyTemplates and their instances read from files into
+// ValidatingAdmissionPolicies and bindings, written to stdout. Constructs
+// which could not be translated are reported on stderr.
+func runConvertTemplate(args []string) error {
+	flags := flag.NewFlagSet("convert-template", flag.ContinueOnError)
+
+	var files stringsFlag
+	flags.Var(&files, "f", "file containing PolicyTemplates and their instances. May be repeated")
+	strict := flags.Bool("strict", false, "exit with an error if any construct could not be translated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file must be given with -f")
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, path := range files {
+		decoded, err := decodeObjects(path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, decoded...)
+	}
+
+	// Group instances by the kind of the template which defines them
+	templateGK := v0alpha2.SchemeGroupVersion.WithKind("PolicyTemplate").GroupKind()
+	var templates []*v0alpha2.PolicyTemplate
+	instances := map[schema.GroupVersionKind][]*unstructured.Unstructured{}
+	for _, obj := range objects {
+		if obj.GroupVersionKind().GroupKind() != templateGK {
+			instances[obj.GroupVersionKind()] = append(instances[obj.GroupVersionKind()], obj)
+			continue
+		}
+
+		template := &v0alpha2.PolicyTemplate{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, template); err != nil {
+			return fmt.Errorf("failed to decode PolicyTemplate %s: %w", obj.GetName(), err)
+		}
+		templates = append(templates, template)
+	}
+
+	if len(templates) == 0 {
+		return errors.New("no PolicyTemplates found")
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	// Kinds of the polyfill are mapped along with the builtin ones. Kinds of
+	// custom resources cannot be resolved without their CRDs
+	scheme.AddToScheme(clientsetscheme.Scheme)
+	options := convert.Options{RESTMapper: evaluate.NewRESTMapper(nil)}
+	var output []interface{}
+	var issues []convert.Issue
+	for _, template := range templates {
+		gvk := controllerv0alpha2.InstanceGroupVersionKind(template)
+		result, err := convert.PolicyTemplate(template, instances[gvk], options)
+		if err != nil {
+			return err
+		}
+
+		output = append(output, result.Policy)
+		for _, binding := range result.Bindings {
+			output = append(output, binding)
+		}
+		issues = append(issues, result.Issues...)
+	}
+
+	if err := writeYAML(os.Stdout, output); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue.String())
+	}
+
+	if *strict && len(issues) > 0 {
+		return fmt.Errorf("%d constructs could not be translated", len(issues))
+	}
+	return nil
+}
+
+// Converts ValidationRuleSets read from files into ValidatingAdmissionPolicies
+// and bindings, written to stdout. With -diff, the rules of each rule set are
+// shown alongside their translation instead.
+func runConvertRuleSet(args []string) error {
+	flags := flag.NewFlagSet("convert-ruleset", flag.ContinueOnError)
+
+	var files stringsFlag
+	flags.Var(&files, "f", "file containing ValidationRuleSets. May be repeated")
+	diff := flags.Bool("diff", false, "show each rule alongside its translation rather than the converted objects")
+	strict := flags.Bool("strict", false, "exit with an error if any construct could not be translated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file must be given with -f")
+	}
+
+	ruleSetGK := v0alpha1.SchemeGroupVersion.WithKind("ValidationRuleSet").GroupKind()
+	var results []*convert.ValidationRuleSetResult
+	for _, path := range files {
+		objects, err := decodeObjects(path)
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objects {
+			if obj.GroupVersionKind().GroupKind() != ruleSetGK {
+				continue
+			}
+
+			ruleSet := &v0alpha1.ValidationRuleSet{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ruleSet); err != nil {
+				return fmt.Errorf("failed to decode ValidationRuleSet %s: %w", obj.GetName(), err)
+			}
+			results = append(results, convert.ValidationRuleSet(ruleSet))
+		}
+	}
+
+	if len(results) == 0 {
+		return errors.New("no ValidationRuleSets found")
+	}
+
+	var output []interface{}
+	var issues []convert.Issue
+	for _, result := range results {
+		if *diff {
+			fmt.Fprint(os.Stdout, result.Diff())
+		} else {
+			output = append(output, result.Policy)
+			if result.Binding != nil {
+				output = append(output, result.Binding)
+			}
+		}
+		issues = append(issues, result.Issues...)
+	}
+
+	if err := writeYAML(os.Stdout, output); err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue.String())
+	}
+
+	if *strict && len(issues) > 0 {
+		return fmt.Errorf("%d constructs could not be translated", len(issues))
+	}
+	return nil
+}
+
+// Reads every object from a file of YAML or JSON documents
+func decodeObjects(path string) ([]*unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var objects []*unstructured.Unstructured
+	decoder := yamlutil.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+
+		// Empty documents
+		if len(obj.Object) == 0 {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// Writes objects as a stream of YAML documents
+func writeYAML(w io.Writer, objects []interface{}) error {
+	for i, obj := range objects {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/impact"
+)
+
+// Evaluates the writes recorded in kube-apiserver audit logs against policies
+// loaded from files, reporting what would have been denied or warned of
+func runImpact(args []string) error {
+	flags := flag.NewFlagSet("impact", flag.ContinueOnError)
+
+	var files stringsFlag
+	flags.Var(&files, "f", "file containing policies, bindings, params, namespaces and CRDs. May be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file must be given with -f")
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("at least one audit log must be given")
+	}
+
+	ctx := context.Background()
+	evaluator, err := loadEvaluator(ctx, files)
+	if err != nil {
+		return err
+	}
+	defer evaluator.Close()
+
+	predictor := impact.NewPredictor(evaluator)
+	err = readEach(flags.Args(), func(r io.Reader) error {
+		return predictor.Read(ctx, r)
+	})
+	if err != nil {
+		return err
+	}
+	return impact.WriteReport(os.Stdout, predictor.Report())
+}
@@ -5,37 +5,51 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/alexzielenski/cel_polyfill"
+	"github.com/alexzielenski/cel_polyfill/pkg/audit"
+	"github.com/alexzielenski/cel_polyfill/pkg/authz"
 	"github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
 	controllerv0alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/celadmissionpolyfill.k8s.io/v0alpha1"
 	controllerv0alpha2 "github.com/alexzielenski/cel_polyfill/pkg/controller/celadmissionpolyfill.k8s.io/v0alpha2"
-	"github.com/alexzielenski/cel_polyfill/pkg/controller/schemaresolver"
 	"github.com/alexzielenski/cel_polyfill/pkg/controller/structuralschema"
+	"github.com/alexzielenski/cel_polyfill/pkg/decisionlog"
+	"github.com/alexzielenski/cel_polyfill/pkg/enforcement"
+	"github.com/alexzielenski/cel_polyfill/pkg/events"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/scheme"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions/celadmissionpolyfill.k8s.io/v0alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/namespaced"
+	"github.com/alexzielenski/cel_polyfill/pkg/policyreport"
+	"github.com/alexzielenski/cel_polyfill/pkg/protection"
+	"github.com/alexzielenski/cel_polyfill/pkg/replay"
+	"github.com/alexzielenski/cel_polyfill/pkg/shadow"
 	"github.com/alexzielenski/cel_polyfill/pkg/validator"
 	"github.com/alexzielenski/cel_polyfill/pkg/webhook"
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	apiextensionsclientsetscheme "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/scheme"
 	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	aggregatorclientsetscheme "k8s.io/kube-aggregator/pkg/client/clientset_generated/clientset/scheme"
 
 	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/klog/v2"
 )
 
@@ -43,7 +57,84 @@ import (
 
 var DEBUG = true
 
+// How often existing objects are audited against policies, and the rate of
+// requests made to the apiserver while auditing
+const (
+	auditInterval = 10 * time.Minute
+	auditQPS      = 5
+	auditBurst    = 10
+
+	// How often violations are written to PolicyReports
+	reportInterval = 1 * time.Minute
+
+	// How long a violation seen by admission is reported after it was last
+	// seen
+	reportAdmissionTTL = 1 * time.Hour
+
+	// How often the counts of shadow bindings are written to their status
+	shadowInterval = 30 * time.Second
+
+	// How often expired PolicyExemptions are flagged in their status
+	exemptionInterval = 1 * time.Minute
+
+	// Environment variable naming the sink each admission decision is
+	// written to, one of stdout, file:///path or an http(s) URL. Decisions
+	// are not logged if unset
+	decisionLogEnv = "DECISION_LOG_SINK"
+
+	// Environment variable which, if true, also emits the events of
+	// violations on the objects of requests in their namespace, in addition
+	// to their policies
+	objectEventsEnv = "OBJECT_EVENTS"
+
+	// Environment variable which, if true, writes the violations found by
+	// the audit scanner and by admission to PolicyReports. Requires the
+	// wgpolicyk8s.io CRDs to be installed
+	policyReportsEnv = "POLICY_REPORTS"
+
+	// Environment variable naming the file AdmissionReviews are captured to,
+	// for the replay command. Reviews are not captured if unset
+	captureEnv = "ADMISSION_CAPTURE_FILE"
+
+	// How often the webhook configuration is restored if it has drifted
+	webhookInterval = 30 * time.Second
+
+	// Environment variables listing the comma separated users and groups
+	// which may change the polyfill's own resources. Groups default to
+	// system:masters if unset
+	adminUsersEnv  = "PROTECTION_ADMIN_USERS"
+	adminGroupsEnv = "PROTECTION_ADMIN_GROUPS"
+
+	// Environment variable naming the namespace/name of the Deployment
+	// running the polyfill. It is not protected if unset
+	deploymentEnv = "POLYFILL_DEPLOYMENT"
+
+	// Environment variable naming the namespace/name of the ServiceAccount
+	// the polyfill runs as, whose changes to its own resources are allowed
+	serviceAccountEnv = "POLYFILL_SERVICE_ACCOUNT"
+)
+
+// Commands which run in place of the webhook server when named as the first
+// argument
+var subcommands = map[string]func(args []string) error{
+	"convert-template": runConvertTemplate,
+	"convert-ruleset":  runConvertRuleSet,
+	"test":             runTest,
+	"replay":           runReplay,
+	"impact":           runImpact,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	klog.EnableContextualLogging(true)
 
 	// Create an overarching context which is cancelled if there is ever an
@@ -124,27 +215,100 @@ func main() {
 		Run(context.Context) error
 	}
 
+	adminUsers := splitList(os.Getenv(adminUsersEnv))
+	adminGroups := []string{user.SystemPrivilegedGroup}
+	if groups, ok := os.LookupEnv(adminGroupsEnv); ok {
+		adminGroups = splitList(groups)
+	}
+
+	var deployment types.NamespacedName
+	if key := os.Getenv(deploymentEnv); len(key) > 0 {
+		deployment.Namespace, deployment.Name, err = cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			klog.Errorf("Failed to parse %s: %v", deploymentEnv, err)
+			return
+		}
+	}
+
+	var serviceAccount types.NamespacedName
+	if key := os.Getenv(serviceAccountEnv); len(key) > 0 {
+		serviceAccount.Namespace, serviceAccount.Name, err = cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			klog.Errorf("Failed to parse %s: %v", serviceAccountEnv, err)
+			return
+		}
+	}
+
 	validators := []admission.ValidationInterface{
-		// StartV0Alpha1(serverContext, cleanupWorker, structuralschemaController, customFactory.Celadmissionpolyfill().V0alpha1().ValidationRuleSets()),
-		// StartV0Alpha2(serverContext, cleanupWorker, dynamicClient, apiextensionsClient, structuralschemaController, customFactory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer()),
-		v1alpha1.NewPlugin(factory, kubeClient, restmapper, schemaresolver.New(apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions(), kubeClient.Discovery()), dynamicClient, nil),
+		// StartV0Alpha1(serverContext, cleanupWorker, structuralschemaController, customFactory.Celadmissionpolyfill().V0alpha1().ValidationRuleSets(), factory.Core().V1().Namespaces().Lister(), restmapper, dynamicClient),
+		// StartV0Alpha2(serverContext, cleanupWorker, dynamicClient, customClient, apiextensionsClient, structuralschemaController, customFactory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(), factory.Core().V1().Namespaces().Lister()),
+		protection.NewValidator(adminUsers, adminGroups, deployment, serviceAccount),
+		authz.NewValidator(customFactory, kubeClient, restmapper),
+		enforcement.NewValidator(factory, customFactory, kubeClient, customClient, restmapper, dynamicClient, exemptionInterval),
+		namespaced.NewValidator(factory, customFactory, kubeClient, restmapper, dynamicClient),
 	}
 
+	scanner := audit.NewScanner(factory, kubeClient, restmapper, dynamicClient, flowcontrol.NewTokenBucketRateLimiter(auditQPS, auditBurst), auditInterval)
+	eventRecorder := events.NewRecorder(factory, customFactory, kubeClient, os.Getenv(objectEventsEnv) == "true")
+
+	runnables := []runnable{scanner, eventRecorder}
 	for _, v := range validators {
 		if r, ok := v.(runnable); ok {
-			waitGroup.Add(1)
-			go func() {
-				err := r.Run(serverContext)
-				if err != nil {
-					klog.Errorf("worker stopped due to error: %v", err)
-				}
-				serverCancel()
-				waitGroup.Done()
-			}()
+			runnables = append(runnables, r)
 		}
 	}
 
-	webhook := webhook.New(9091, locateCertificates(), clientsetscheme.Scheme, validator.NewMulti(validators...))
+	observers := []validator.Observer{eventRecorder.RecordAdmission}
+	if os.Getenv(policyReportsEnv) == "true" {
+		reporter := policyreport.NewReporter(dynamicClient, scanner, reportInterval, reportAdmissionTTL)
+		runnables = append(runnables, reporter)
+		observers = append(observers, reporter.RecordAdmission)
+	}
+
+	var admissionValidator admission.ValidationInterface = validator.NewObserved(validator.NewMulti(validators...), observers...)
+
+	shadowEvaluator := shadow.NewEvaluator(admissionValidator, factory, customFactory, kubeClient, customClient, restmapper, dynamicClient, shadowInterval)
+	runnables = append(runnables, shadowEvaluator)
+	admissionValidator = shadowEvaluator
+
+	if spec := os.Getenv(decisionLogEnv); len(spec) > 0 {
+		sink, err := decisionlog.NewSink(spec)
+		if err != nil {
+			klog.Errorf("Failed to create decision log: %v", err)
+			return
+		}
+
+		decisionLogger := decisionlog.NewLogger(admissionValidator, sink)
+		runnables = append(runnables, decisionLogger)
+		admissionValidator = decisionLogger
+	}
+
+	var reviewObservers []webhook.ReviewObserver
+	if path := os.Getenv(captureEnv); len(path) > 0 {
+		captureRecorder, err := replay.NewRecorder(path)
+		if err != nil {
+			klog.Errorf("Failed to create capture file: %v", err)
+			return
+		}
+
+		runnables = append(runnables, captureRecorder)
+		reviewObservers = append(reviewObservers, captureRecorder.Record)
+	}
+
+	for _, r := range runnables {
+		r := r
+		waitGroup.Add(1)
+		go func() {
+			err := r.Run(serverContext)
+			if err != nil {
+				klog.Errorf("worker stopped due to error: %v", err)
+			}
+			serverCancel()
+			waitGroup.Done()
+		}()
+	}
+
+	webhook := webhook.New(9091, locateCertificates(), clientsetscheme.Scheme, admissionValidator, reviewObservers...)
 
 	// Start HTTP REST server for webhook
 	waitGroup.Add(1)
@@ -178,6 +342,22 @@ func main() {
 		}
 	}
 
+	// Restore the webhook configuration if it is deleted or changed. Only the
+	// debug configuration is installed by the polyfill, otherwise it is
+	// deployed with the polyfill.
+	if DEBUG {
+		reconciler := protection.NewReconciler(factory, kubeClient, webhook, webhookInterval)
+		waitGroup.Add(1)
+		go func() {
+			err := reconciler.Run(serverContext)
+			if err != nil {
+				klog.Errorf("worker stopped due to error: %v", err)
+			}
+			serverCancel()
+			waitGroup.Done()
+		}()
+	}
+
 	// Start after informers have been requested from factory
 	factory.Start(serverContext.Done())
 	apiextensionsFactory.Start(serverContext.Done())
@@ -188,6 +368,17 @@ func main() {
 	waitGroup.Wait()
 }
 
+// Splits a comma separated list, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func loadClientConfig() (*rest.Config, error) {
 	// Use KubeConfig to find cluser if debugging, otherwise use the in cluser
 	// configuration
@@ -233,13 +424,16 @@ func StartV0Alpha1(
 	cancelFunc func(),
 	structuralschemaController structuralschema.Controller,
 	informer v0alpha1.ValidationRuleSetInformer,
+	namespaceLister corev1listers.NamespaceLister,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
 ) admission.ValidationInterface {
 
 	if DEBUG {
 		// Install latest CRD definitions
 	}
 
-	validator := controllerv0alpha1.NewValidator(structuralschemaController)
+	validator := controllerv0alpha1.NewValidator(structuralschemaController, namespaceLister, restMapper, dynamicClient)
 
 	// call outside of goroutine so that informer is requested before we start
 	// factory. (for some reason factory doesn't start informers requested
@@ -264,9 +458,11 @@ func StartV0Alpha2(
 	ctx context.Context,
 	cancelFunc func(),
 	dynamicClient dynamic.Interface,
+	customClient versioned.Interface,
 	apiextensionsClient apiextensionsclientset.Interface,
 	structuralschemaController structuralschema.Controller,
 	policyTemplatesInformer cache.SharedIndexInformer,
+	namespaceLister corev1listers.NamespaceLister,
 ) admission.ValidationInterface {
 	if DEBUG {
 		// Install latest CRD definitions
@@ -274,9 +470,11 @@ func StartV0Alpha2(
 
 	controller := controllerv0alpha2.NewPolicyTemplateController(
 		dynamicClient,
+		customClient,
 		policyTemplatesInformer,
 		structuralschemaController,
 		apiextensionsClient,
+		namespaceLister,
 	)
 
 	go func() {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/replay"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Replays captured AdmissionReviews against policies loaded from files,
+// writing the requests whose decisions changed to stdout
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+
+	var files stringsFlag
+	flags.Var(&files, "f", "file containing policies, bindings, params, namespaces and CRDs. May be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file must be given with -f")
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("at least one capture file must be given")
+	}
+
+	ctx := context.Background()
+	evaluator, err := loadEvaluator(ctx, files)
+	if err != nil {
+		return err
+	}
+	defer evaluator.Close()
+
+	result := &replay.Result{}
+	err = readEach(flags.Args(), func(r io.Reader) error {
+		return result.Replay(ctx, evaluator, replay.NewReader(r))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := replay.WriteDiff(os.Stdout, result); err != nil {
+		return err
+	}
+
+	if len(result.Changes) > 0 {
+		return fmt.Errorf("%d decisions changed", len(result.Changes))
+	}
+	return nil
+}
+
+// Creates an evaluator of the policies, bindings, params, namespaces and CRDs
+// read from files
+func loadEvaluator(ctx context.Context, files []string) (*evaluate.Evaluator, error) {
+	var objects []*unstructured.Unstructured
+	for _, path := range files {
+		decoded, err := decodeObjects(path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+
+	resources, err := evaluate.ResourcesFromObjects(objects)
+	if err != nil {
+		return nil, err
+	}
+	return evaluate.New(ctx, resources)
+}
+
+// Reads each file in turn, so that a record is never joined with the first
+// of the next file when a file does not end in a newline
+func readEach(paths []string, read func(r io.Reader) error) error {
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		err = read(file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
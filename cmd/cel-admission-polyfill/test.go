@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/policytest"
+)
+
+// Runs admission cases against policies loaded from files rather than a
+// cluster, reporting the results to stdout
+func runTest(args []string) error {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	format := flags.String("o", "tap", "output format, one of tap or junit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("at least one suite file must be given")
+	}
+
+	write := policytest.WriteTAP
+	switch *format {
+	case "tap":
+	case "junit":
+		write = policytest.WriteJUnit
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	var results []*policytest.SuiteResult
+	failures := 0
+	for _, path := range flags.Args() {
+		suite, err := policytest.LoadSuite(path)
+		if err != nil {
+			return err
+		}
+
+		result, err := policytest.Run(context.Background(), suite)
+		if err != nil {
+			return err
+		}
+		results = append(results, result)
+		failures += result.Failures()
+	}
+
+	if err := write(os.Stdout, results); err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d cases failed", failures)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ require (
 	github.com/google/cel-policy-templates-go v0.1.4
 	github.com/google/go-cmp v0.5.9
 	github.com/mikefarah/yq/v4 v4.32.2
+	google.golang.org/genproto v0.0.0-20220502173005-c8bf987b8c21
 	k8s.io/api v0.27.0-beta.0
 	k8s.io/apiextensions-apiserver v0.27.0-beta.0
 	k8s.io/apimachinery v0.27.0-beta.0
@@ -16,7 +17,9 @@ require (
 	k8s.io/klog/v2 v2.90.1
 	k8s.io/kube-aggregator v0.26.3
 	k8s.io/kube-openapi v0.0.0-20230308215209-15aac26d736a
+	k8s.io/utils v0.0.0-20230209194617-a36077c30491
 	sigs.k8s.io/controller-tools v0.11.3
+	sigs.k8s.io/yaml v1.3.0
 )
 
 require (
@@ -105,7 +108,6 @@ require (
 	golang.org/x/tools v0.7.0 // indirect
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
-	google.golang.org/genproto v0.0.0-20220502173005-c8bf987b8c21 // indirect
 	google.golang.org/grpc v1.51.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/inf.v0 v0.9.1 // indirect
@@ -116,9 +118,7 @@ require (
 	k8s.io/component-base v0.27.0-beta.0 // indirect
 	k8s.io/gengo v0.0.0-20220902162205-c0856e24416d // indirect
 	k8s.io/kms v0.27.0-beta.0 // indirect
-	k8s.io/utils v0.0.0-20230209194617-a36077c30491 // indirect
 	sigs.k8s.io/apiserver-network-proxy/konnectivity-client v0.1.1 // indirect
 	sigs.k8s.io/json v0.0.0-20221116044647-bc3834ca7abd // indirect
 	sigs.k8s.io/structured-merge-diff/v4 v4.2.3 // indirect
-	sigs.k8s.io/yaml v1.3.0 // indirect
 )
@@ -0,0 +1,103 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Policy",type=string,JSONPath=`.spec.policyName`
+// +kubebuilder:printcolumn:name="Binding",type=string,JSONPath=`.spec.bindingName`
+// +kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.spec.expires`
+// +kubebuilder:printcolumn:name="Expired",type=boolean,JSONPath=`.status.expired`
+// PolicyExemption waives the validations of a policy for the requests it
+// matches until it expires. Requests are exempt from a binding when they
+// match every criteria of the exemption.
+type PolicyExemption struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// Specification of the requests which are exempt.
+	Spec PolicyExemptionSpec `json:"spec,omitempty" protobuf:"bytes,2,opt,name=spec"`
+	// The status of the PolicyExemption.
+	// Populated by the system.
+	// Read-only.
+	// +optional
+	Status PolicyExemptionStatus `json:"status,omitempty" protobuf:"bytes,3,opt,name=status"`
+}
+
+// PolicyExemptionSpec is the specification of a PolicyExemption.
+type PolicyExemptionSpec struct {
+	// PolicyName references the ValidatingAdmissionPolicy requests are exempt from.
+	// Required.
+	// +kubebuilder:validation:Required
+	PolicyName string `json:"policyName" protobuf:"bytes,1,opt,name=policyName"`
+
+	// BindingName references the ValidatingAdmissionPolicyBinding of the policy
+	// requests are exempt from. Requests are exempt from every binding of the
+	// policy if unset.
+	// +optional
+	BindingName string `json:"bindingName,omitempty" protobuf:"bytes,2,opt,name=bindingName"`
+
+	// Namespaces of the objects which are exempt. Objects of any namespace,
+	// including cluster scoped objects, are exempt if empty.
+	// +optional
+	// +listType=set
+	Namespaces []string `json:"namespaces,omitempty" protobuf:"bytes,3,rep,name=namespaces"`
+
+	// ObjectSelector selects the objects which are exempt by their labels.
+	// The old object is used for deletes. Every object is exempt if unset.
+	// +optional
+	ObjectSelector *metav1.LabelSelector `json:"objectSelector,omitempty" protobuf:"bytes,4,opt,name=objectSelector"`
+
+	// Users whose requests are exempt. Requests of any user are exempt if
+	// both users and groups are empty.
+	// +optional
+	// +listType=set
+	Users []string `json:"users,omitempty" protobuf:"bytes,5,rep,name=users"`
+
+	// Groups whose members' requests are exempt.
+	// +optional
+	// +listType=set
+	Groups []string `json:"groups,omitempty" protobuf:"bytes,6,rep,name=groups"`
+
+	// Expires is the time after which the exemption no longer applies.
+	// Required.
+	// +kubebuilder:validation:Required
+	Expires metav1.Time `json:"expires" protobuf:"bytes,7,opt,name=expires"`
+
+	// Justification records why the exemption was granted.
+	// Required.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Justification string `json:"justification" protobuf:"bytes,8,opt,name=justification"`
+}
+
+// PolicyExemptionStatus represents the status of a PolicyExemption.
+type PolicyExemptionStatus struct {
+	// Expired is set once the exemption no longer applies.
+	// +optional
+	Expired bool `json:"expired,omitempty" protobuf:"varint,1,opt,name=expired"`
+
+	// The generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty" protobuf:"varint,2,opt,name=observedGeneration"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// PolicyExemptionList is a list of PolicyExemption.
+type PolicyExemptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// List of PolicyExemption.
+	Items []PolicyExemption `json:"items,omitempty" protobuf:"bytes,2,rep,name=items"`
+}
@@ -0,0 +1,105 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+// NamespacedValidatingAdmissionPolicy is a ValidatingAdmissionPolicy authored
+// by the tenant of a namespace. It only validates requests for objects in its
+// own namespace, regardless of its matchConstraints.
+type NamespacedValidatingAdmissionPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// Specification of the desired behavior of the NamespacedValidatingAdmissionPolicy.
+	// The namespaceSelector of matchConstraints is intersected with the
+	// namespace of the policy.
+	Spec ValidatingAdmissionPolicySpec `json:"spec,omitempty" protobuf:"bytes,2,opt,name=spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// NamespacedValidatingAdmissionPolicyList is a list of NamespacedValidatingAdmissionPolicy.
+type NamespacedValidatingAdmissionPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// List of NamespacedValidatingAdmissionPolicy.
+	Items []NamespacedValidatingAdmissionPolicy `json:"items,omitempty" protobuf:"bytes,2,rep,name=items"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced
+// +kubebuilder:printcolumn:name="Policy",type=string,JSONPath=`.spec.policyName`
+// NamespacedValidatingAdmissionPolicyBinding binds a
+// NamespacedValidatingAdmissionPolicy of the same namespace with its params,
+// which are also in the same namespace.
+type NamespacedValidatingAdmissionPolicyBinding struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata; More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// Specification of the desired behavior of the NamespacedValidatingAdmissionPolicyBinding.
+	Spec NamespacedValidatingAdmissionPolicyBindingSpec `json:"spec,omitempty" protobuf:"bytes,2,opt,name=spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// NamespacedValidatingAdmissionPolicyBindingList is a list of NamespacedValidatingAdmissionPolicyBinding.
+type NamespacedValidatingAdmissionPolicyBindingList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#types-kinds
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+	// List of NamespacedValidatingAdmissionPolicyBinding.
+	Items []NamespacedValidatingAdmissionPolicyBinding `json:"items,omitempty" protobuf:"bytes,2,rep,name=items"`
+}
+
+// NamespacedValidatingAdmissionPolicyBindingSpec is the specification of a
+// NamespacedValidatingAdmissionPolicyBinding.
+type NamespacedValidatingAdmissionPolicyBindingSpec struct {
+	// PolicyName references the NamespacedValidatingAdmissionPolicy in the
+	// namespace of the binding which it binds to. If the referenced policy
+	// does not exist, the binding is ignored.
+	// Required.
+	// +kubebuilder:validation:Required
+	PolicyName string `json:"policyName" protobuf:"bytes,1,opt,name=policyName"`
+
+	// ParamRef references the params of the policy in the namespace of the
+	// binding. The paramKind of the policy must be namespaced.
+	// +optional
+	ParamRef *NamespacedParamRef `json:"paramRef,omitempty" protobuf:"bytes,2,opt,name=paramRef"`
+
+	// MatchResources declares what resources match this binding and will be
+	// validated by it. It is intersected with the matchConstraints of the
+	// policy, and its namespaceSelector with the namespace of the binding.
+	// +optional
+	MatchResources *MatchResources `json:"matchResources,omitempty" protobuf:"bytes,3,opt,name=matchResources"`
+
+	// ValidationActions declares how validations of the referenced policy
+	// are enforced. Only Deny and Warn are supported, Audit is ignored.
+	// Required.
+	// +kubebuilder:validation:Required
+	// +listType=set
+	ValidationActions []ValidationAction `json:"validationActions,omitempty" protobuf:"bytes,4,rep,name=validationActions"`
+}
+
+// NamespacedParamRef references a parameter resource in the namespace of the
+// binding
+// +structType=atomic
+type NamespacedParamRef struct {
+	// Name of the resource being referenced.
+	// Required.
+	// +kubebuilder:validation:Required
+	Name string `json:"name" protobuf:"bytes,1,opt,name=name"`
+}
@@ -345,6 +345,7 @@ type AuditAnnotation struct {
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +k8s:prerelease-lifecycle-gen:introduced=1.26
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:metadata:annotations="api-approved.kubernetes.io=unapproved, request not yet submitted"
 // ValidatingAdmissionPolicyBinding binds the ValidatingAdmissionPolicy with paramerized resources.
@@ -356,6 +357,73 @@ type ValidatingAdmissionPolicyBinding struct {
 	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
 	// Specification of the desired behavior of the ValidatingAdmissionPolicyBinding.
 	Spec ValidatingAdmissionPolicyBindingSpec `json:"spec,omitempty" protobuf:"bytes,2,opt,name=spec"`
+	// The status of the ValidatingAdmissionPolicyBinding.
+	// Populated by the system.
+	// Read-only.
+	// +optional
+	Status ValidatingAdmissionPolicyBindingStatus `json:"status,omitempty" protobuf:"bytes,3,opt,name=status"`
+}
+
+// ValidatingAdmissionPolicyBindingStatus represents the status of a ValidatingAdmissionPolicyBinding.
+type ValidatingAdmissionPolicyBindingStatus struct {
+	// Shadow summarizes the requests evaluated by a shadow binding.
+	// Counts are kept when spec.shadow is unset.
+	// +optional
+	Shadow *ShadowStatus `json:"shadow,omitempty" protobuf:"bytes,1,opt,name=shadow"`
+
+	// Exempted is set while an unexpired PolicyExemption applies to the
+	// binding. It is informational: exemptions apply to requests as soon as
+	// they are created.
+	// +optional
+	Exempted bool `json:"exempted,omitempty" protobuf:"varint,2,opt,name=exempted"`
+}
+
+// ShadowStatus counts the requests a shadow binding would have denied or
+// warned of had it not been in shadow mode.
+type ShadowStatus struct {
+	// The number of requests matched by the binding.
+	// +optional
+	Matched int64 `json:"matched" protobuf:"varint,1,opt,name=matched"`
+	// The number of requests which failed validation with the Deny action.
+	// +optional
+	WouldDeny int64 `json:"wouldDeny" protobuf:"varint,2,opt,name=wouldDeny"`
+	// The number of requests which failed validation with the Warn action.
+	// +optional
+	WouldWarn int64 `json:"wouldWarn" protobuf:"varint,3,opt,name=wouldWarn"`
+	// The number of requests the binding failed to evaluate.
+	// +optional
+	Errors int64 `json:"errors" protobuf:"varint,4,opt,name=errors"`
+	// The first time a request failed validation.
+	// +optional
+	FirstSeen *metav1.Time `json:"firstSeen,omitempty" protobuf:"bytes,5,opt,name=firstSeen"`
+	// The last time a request failed validation.
+	// +optional
+	LastSeen *metav1.Time `json:"lastSeen,omitempty" protobuf:"bytes,6,opt,name=lastSeen"`
+	// The most recent requests which failed validation, oldest first.
+	// +optional
+	// +listType=atomic
+	RecentViolations []ShadowViolation `json:"recentViolations,omitempty" protobuf:"bytes,7,rep,name=recentViolations"`
+}
+
+// ShadowViolation describes a request which failed validation by a shadow
+// binding.
+type ShadowViolation struct {
+	// The time the request was evaluated.
+	Time metav1.Time `json:"time" protobuf:"bytes,1,opt,name=time"`
+	// The operation of the request.
+	Operation OperationType `json:"operation" protobuf:"bytes,2,opt,name=operation"`
+	// The resource of the request, e.g. deployments.apps.
+	Resource string `json:"resource" protobuf:"bytes,3,opt,name=resource"`
+	// The namespace of the object of the request.
+	// +optional
+	Namespace string `json:"namespace,omitempty" protobuf:"bytes,4,opt,name=namespace"`
+	// The name of the object of the request.
+	// +optional
+	Name string `json:"name,omitempty" protobuf:"bytes,5,opt,name=name"`
+	// The user who made the request.
+	User string `json:"user" protobuf:"bytes,6,opt,name=user"`
+	// The message the request would have been denied or warned with.
+	Message string `json:"message" protobuf:"bytes,7,opt,name=message"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -436,6 +504,44 @@ type ValidatingAdmissionPolicyBindingSpec struct {
 	// +kubebuilder:validation:Required
 	// +listType=set
 	ValidationActions []ValidationAction `json:"validationActions,omitempty" protobuf:"bytes,4,rep,name=validationActions"`
+
+	// Shadow bindings are evaluated against live requests without affecting
+	// the responses to them. Requests which fail validation are counted in
+	// status.shadow according to validationActions, so the effect of a binding
+	// can be measured before it is enforced.
+	// +optional
+	Shadow bool `json:"shadow,omitempty" protobuf:"varint,5,opt,name=shadow"`
+
+	// Rollout phases in the Deny action of the binding. Requests outside of
+	// the rollout fail validation with the Warn action instead of Deny.
+	// Deny is enforced for every request if unset.
+	// +optional
+	Rollout *BindingRollout `json:"rollout,omitempty" protobuf:"bytes,6,opt,name=rollout"`
+}
+
+// BindingRollout selects the requests for which a binding enforces its Deny
+// action. Deny is enforced in a namespace selected by either percentage or
+// namespaceSelector, or in every namespace if both are unset, once
+// enforceAfter has passed.
+type BindingRollout struct {
+	// Percentage of namespaces in which Deny is enforced. Namespaces are
+	// chosen by a hash of their name and the binding's, so raising the
+	// percentage only adds namespaces. Cluster scoped objects are chosen
+	// the same way by their own name.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	Percentage *int32 `json:"percentage,omitempty" protobuf:"varint,1,opt,name=percentage"`
+
+	// NamespaceSelector selects early adopter namespaces in which Deny is
+	// enforced regardless of percentage.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty" protobuf:"bytes,2,opt,name=namespaceSelector"`
+
+	// EnforceAfter is the time before which violations only warn, in every
+	// namespace.
+	// +optional
+	EnforceAfter *metav1.Time `json:"enforceAfter,omitempty" protobuf:"bytes,3,opt,name=enforceAfter"`
 }
 
 // ParamRef references a parameter resource
@@ -58,6 +58,12 @@ func init() {
 // Adds the list of known types to Scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&NamespacedValidatingAdmissionPolicy{},
+		&NamespacedValidatingAdmissionPolicyBinding{},
+		&NamespacedValidatingAdmissionPolicyBindingList{},
+		&NamespacedValidatingAdmissionPolicyList{},
+		&PolicyExemption{},
+		&PolicyExemptionList{},
 		&ValidatingAdmissionPolicy{},
 		&ValidatingAdmissionPolicyBinding{},
 		&ValidatingAdmissionPolicyBindingList{},
@@ -36,6 +36,42 @@ type ValidationRuleSetSpec struct {
 
 	// +listType=atomic
 	Match []admissionregistrationv1.RuleWithOperations `json:"match"`
+
+	// Environment selects which variables are available to the rules.
+	// Defaults to Schema, where rules only see `self` and `oldSelf`.
+	// Admission additionally exposes `request`, `namespaceObject` and
+	// `params`.
+	// +optional
+	// +kubebuilder:validation:Enum=Schema;Admission
+	Environment RuleEnvironment `json:"environment,omitempty"`
+
+	// ParamRef points to the object exposed to rules as `params`. Only used
+	// by the Admission environment. If unset, `params` is null.
+	// +optional
+	ParamRef *ParamRef `json:"paramRef,omitempty"`
+}
+
+type RuleEnvironment string
+
+const (
+	// Rules are evaluated against the object schema with only `self` and
+	// `oldSelf` in scope
+	SchemaEnvironment RuleEnvironment = "Schema"
+
+	// Rules are additionally given the admission request, the namespace of
+	// the object, and the referenced params
+	AdmissionEnvironment RuleEnvironment = "Admission"
+)
+
+type ParamRef struct {
+	// APIVersion of the referenced object in the form "group/version"
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+
+	// Empty for cluster-scoped params
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type ValidationRuleSetStatus struct {
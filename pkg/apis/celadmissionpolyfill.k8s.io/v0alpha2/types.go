@@ -10,18 +10,51 @@ import (
 // +geninformer
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:metadata:annotations="api-approved.kubernetes.io=unapproved, request not yet submitted"
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="CRD",type=string,JSONPath=`.status.conditions[?(@.type=="CRDCreated")].status`
+// +kubebuilder:printcolumn:name="Compiled",type=string,JSONPath=`.status.conditions[?(@.type=="Compiled")].status`
+// +kubebuilder:printcolumn:name="Instances",type=integer,JSONPath=`.status.instanceCount`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type PolicyTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
 	// +optional
 	PolicyTemplateSpec `json:"spec,omitempty"`
+
+	// +optional
+	Status PolicyTemplateStatus `json:"status,omitempty"`
 }
 
 type PolicyTemplateSpec struct {
+	// API group of the CRD generated for instances of this template.
+	// Defaults to policy.acme.co
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind of instances of this template. Defaults to the template name
+	// +optional
+	InstanceKind string `json:"instanceKind,omitempty"`
+
+	// Plural resource name of instances of this template. Defaults to the
+	// lowercased instance kind
 	// +optional
 	PluralName string `json:"pluralName,omitempty"`
 
+	// +optional
+	// +listType=set
+	ShortNames []string `json:"shortNames,omitempty"`
+
+	// Scope of instances of this template. Defaults to Cluster
+	// +optional
+	// +kubebuilder:validation:Enum=Cluster;Namespaced
+	Scope apiextensionsv1.ResourceScope `json:"scope,omitempty"`
+
+	// Additional columns shown by kubectl for instances of this template
+	// +optional
+	// +listType=atomic
+	AdditionalPrinterColumns []apiextensionsv1.CustomResourceColumnDefinition `json:"additionalPrinterColumns,omitempty"`
+
 	//TODO: Schemaless required because for some reason JSONSchemaProps is not compatible
 	// with controller-gen. super unfortunate
 	// +required
@@ -36,6 +69,32 @@ type PolicyTemplateSpec struct {
 	*Validator `json:"validator,omitempty"`
 }
 
+const (
+	// Condition reporting whether the CRD for instances of the template
+	// has been created
+	PolicyTemplateCRDCreated = "CRDCreated"
+
+	// Condition reporting whether the latest version of the template
+	// compiled. If it did not, the last version which compiled remains
+	// enforced
+	PolicyTemplateCompiled = "Compiled"
+)
+
+type PolicyTemplateStatus struct {
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Number of instances of the template, including those which failed to
+	// compile
+	// +optional
+	InstanceCount int32 `json:"instanceCount,omitempty"`
+
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type PolicyTemplateList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -43,6 +102,50 @@ type PolicyTemplateList struct {
 	Items           []PolicyTemplate `json:"items"`
 }
 
+// Match restricts which objects an instance of a PolicyTemplate applies to.
+// Instances without a match block apply to every object.
+//
+// Instances declare it under the `match` key of the generated CRD.
+type Match struct {
+	// Resource kinds the instance applies to. Empty matches all kinds
+	// +optional
+	// +listType=atomic
+	Kinds []MatchKinds `json:"kinds,omitempty"`
+
+	// Namespaces the instance applies to. Empty matches all namespaces.
+	// Ignored for cluster-scoped objects other than Namespaces
+	// +optional
+	// +listType=set
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Namespaces the instance never applies to. Takes precedence over
+	// Namespaces and NamespaceSelector
+	// +optional
+	// +listType=set
+	ExcludedNamespaces []string `json:"excludedNamespaces,omitempty"`
+
+	// Selects objects by their labels
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+
+	// Selects objects by the labels of their namespace. For Namespaces the
+	// labels of the object itself are used
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+type MatchKinds struct {
+	// "*" matches all groups
+	// +optional
+	// +listType=set
+	APIGroups []string `json:"apiGroups,omitempty"`
+
+	// "*" matches all kinds
+	// +optional
+	// +listType=set
+	Kinds []string `json:"kinds,omitempty"`
+}
+
 type Validator struct {
 	// +optional
 	Description string `json:"description,omitempty"`
@@ -61,6 +164,9 @@ type Evaluator struct {
 	// +optional
 	Description string `json:"description,omitempty"`
 
+	// Environment may name a resource as <resource>.<version>.<group>, in
+	// which case object and oldObject are typed by the schema of the
+	// resource and the template only evaluates requests for it.
 	// +optional
 	Environment string `json:"environment,omitempty"`
 
@@ -112,7 +218,7 @@ type ValidatorProduction struct {
 	// +optional
 	// +kubebuilder:validation:Schemaless
 	// +kubebuilder:pruning:PreserveUnknownFields
-	Details runtime.RawExtension `json:"details,omitempty"`
+	Details *runtime.RawExtension `json:"details,omitempty"`
 }
 
 type Production struct {
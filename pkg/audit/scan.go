@@ -0,0 +1,469 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/pager"
+)
+
+// Outcome of a scan
+type Report struct {
+	Started   time.Time
+	Completed time.Time
+
+	// Number of times an object was evaluated against a policy
+	Scanned int
+
+	// Bindings violated by at least one object, ordered by policy then
+	// binding name
+	Violations []Violations
+
+	// Resources matched by policies which existing objects were not
+	// evaluated for, ordered by policy
+	Skipped []SkippedResource
+}
+
+// Resource of a rule whose objects could not be listed
+type SkippedResource struct {
+	Policy string
+
+	// Resource as written in the rule, where "*" matches any
+	Resource schema.GroupVersionResource
+
+	Reason string
+}
+
+// Existing objects which fail the validations of a binding
+type Violations struct {
+	Policy  string
+	Binding string
+
+	// Actions the binding takes for failed validations
+	Actions []admissionregistrationv1alpha1.ValidationAction
+
+	// Number of objects which failed at least one validation
+	Count int
+
+	// Number of objects which failed at least one validation in each
+	// namespace. Cluster scoped objects are counted under the empty namespace
+	Namespaces map[string]int
+
+	// Number of objects the binding could not be evaluated for
+	Errors int
+
+	// Number of objects the binding could not be evaluated for in each
+	// namespace
+	ErrorNamespaces map[string]int
+
+	// The first objects found, up to samplesPerBinding
+	Samples []Sample
+}
+
+type Sample struct {
+	Object corev1.ObjectReference
+
+	// Messages of each failed validation
+	Messages []string
+
+	// Errors evaluating the binding or its validations for the object
+	Errors []string
+}
+
+type bindingKey struct {
+	policy  string
+	binding string
+}
+
+type paramKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+type paramResult struct {
+	param runtime.Object
+	err   error
+}
+
+// Resource listed for a policy, and the operation requests for its objects
+// are synthesized with
+type auditedResource struct {
+	gvr       schema.GroupVersionResource
+	operation admission.Operation
+}
+
+// State of a single pass over the cluster
+type scan struct {
+	*Scanner
+
+	// Params are read while explaining, which has no context of its own
+	ctx context.Context
+
+	explainer  *evaluate.Explainer
+	report     *Report
+	violations map[bindingKey]*Violations
+
+	// Params are read at most once per scan
+	params map[paramKey]paramResult
+
+	// Resources served by the apiserver are discovered at most once per
+	// scan, and only for rules which match every resource
+	discovered   bool
+	served       []schema.GroupVersionResource
+	discoveryErr error
+}
+
+func newScan(ctx context.Context, s *Scanner) *scan {
+	result := &scan{
+		Scanner:    s,
+		ctx:        ctx,
+		report:     &Report{Started: time.Now()},
+		violations: map[bindingKey]*Violations{},
+		params:     map[paramKey]paramResult{},
+	}
+	result.explainer = evaluate.NewExplainer(s.matcher, result.findParam)
+	return result
+}
+
+// Evaluates every object of the resources matched by policy against its
+// bindings
+func (sc *scan) policy(
+	ctx context.Context,
+	policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy,
+	bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding,
+) error {
+	policies := []*admissionregistrationv1alpha1.ValidatingAdmissionPolicy{policy}
+
+	for _, resource := range sc.resources(policy) {
+		resource := resource
+		listPager := pager.New(func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			if err := sc.limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			return sc.dynamicClient.Resource(resource.gvr).List(ctx, opts)
+		})
+		listPager.PageSize = pageSize
+
+		err := listPager.EachListItem(ctx, metav1.ListOptions{}, func(item runtime.Object) error {
+			obj, ok := item.(*unstructured.Unstructured)
+			if !ok {
+				return fmt.Errorf("unexpected list item %T", item)
+			}
+
+			// An UPDATE which changes nothing, so transition rules compare
+			// the object against itself
+			var oldObject runtime.Object
+			if resource.operation == admission.Update {
+				oldObject = obj.DeepCopy()
+			}
+
+			attrs := admission.NewAttributesRecord(
+				obj,
+				oldObject,
+				obj.GroupVersionKind(),
+				obj.GetNamespace(),
+				obj.GetName(),
+				resource.gvr,
+				"",
+				resource.operation,
+				nil,
+				false,
+				auditUser,
+			)
+
+			sc.report.Scanned++
+			for _, result := range sc.explainer.Explain(ctx, attrs, policies, bindings) {
+				sc.record(obj, result)
+			}
+			return nil
+		})
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to audit %v for ValidatingAdmissionPolicy '%s': %w", resource.gvr, policy.Name, err))
+		}
+	}
+	return nil
+}
+
+// Resolves the resources matched by the resourceRules of policy. Rules which
+// only match operations that existing objects cannot be evaluated for are
+// ignored, and resources which cannot be listed are recorded as skipped.
+func (sc *scan) resources(policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy) []auditedResource {
+	if policy.Spec.MatchConstraints == nil {
+		return nil
+	}
+
+	// Each resource is listed at a single version. The matcher converts
+	// objects to the version a policy asks for
+	var resources []auditedResource
+	indices := map[schema.GroupResource]int{}
+
+	add := func(gvr schema.GroupVersionResource, operation admission.Operation) {
+		if i, found := indices[gvr.GroupResource()]; found {
+			if operation == admission.Update {
+				resources[i].operation = operation
+			}
+			return
+		}
+
+		indices[gvr.GroupResource()] = len(resources)
+		resources = append(resources, auditedResource{gvr: gvr, operation: operation})
+	}
+
+	for _, rule := range policy.Spec.MatchConstraints.ResourceRules {
+		operation, ok := auditOperation(rule.Operations)
+		if !ok {
+			continue
+		}
+
+		for _, group := range rule.APIGroups {
+			for _, version := range rule.APIVersions {
+				for _, resource := range rule.Resources {
+					pattern := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+					if strings.Contains(resource, "/") {
+						sc.skip(policy, pattern, "subresources cannot be listed")
+						continue
+					}
+
+					gvrs, err := sc.matchingResources(pattern)
+					if err != nil {
+						sc.skip(policy, pattern, err.Error())
+						continue
+					}
+
+					for _, gvr := range gvrs {
+						add(gvr, operation)
+					}
+				}
+			}
+		}
+	}
+	return resources
+}
+
+// Returns the listable resources matched by the group, version and resource
+// of a rule, any of which may be "*"
+func (sc *scan) matchingResources(pattern schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	var candidates []schema.GroupVersionResource
+	if pattern.Resource == "*" {
+		served, err := sc.discover()
+		if err != nil {
+			return nil, err
+		}
+		candidates = served
+	} else {
+		query := schema.GroupVersionResource{Resource: pattern.Resource}
+		if pattern.Group != "*" {
+			query.Group = pattern.Group
+		}
+		if pattern.Version != "*" {
+			query.Version = pattern.Version
+		}
+
+		gvrs, err := sc.restMapper.ResourcesFor(query)
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		candidates = gvrs
+	}
+
+	// The RESTMapper treats an empty group as any group, but rules use it
+	// for the core group
+	var result []schema.GroupVersionResource
+	for _, gvr := range candidates {
+		if pattern.Group != "*" && gvr.Group != pattern.Group {
+			continue
+		} else if pattern.Version != "*" && gvr.Version != pattern.Version {
+			continue
+		}
+		result = append(result, gvr)
+	}
+	return result, nil
+}
+
+// Returns every resource served by the apiserver which can be listed.
+// Subresources are excluded.
+func (sc *scan) discover() ([]schema.GroupVersionResource, error) {
+	if sc.discovered {
+		return sc.served, sc.discoveryErr
+	}
+	sc.discovered = true
+
+	if err := sc.limiter.Wait(sc.ctx); err != nil {
+		sc.discoveryErr = err
+		return nil, err
+	}
+
+	// Groups which fail discovery are reported, and the resources of the
+	// remaining groups are still scanned
+	_, lists, err := sc.discovery.ServerGroupsAndResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			sc.discoveryErr = fmt.Errorf("failed to discover resources: %w", err)
+			return nil, sc.discoveryErr
+		}
+		utilruntime.HandleError(fmt.Errorf("failed to discover resources of some groups: %w", err))
+	}
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+			sc.served = append(sc.served, gv.WithResource(resource.Name))
+		}
+	}
+	return sc.served, nil
+}
+
+func (sc *scan) skip(policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy, resource schema.GroupVersionResource, reason string) {
+	sc.report.Skipped = append(sc.report.Skipped, SkippedResource{
+		Policy:   policy.Name,
+		Resource: resource,
+		Reason:   reason,
+	})
+}
+
+// Returns the operation requests for existing objects matched by a rule with
+// operations are synthesized with. An UPDATE which changes nothing is
+// preferred.
+func auditOperation(operations []admissionregistrationv1alpha1.OperationType) (admission.Operation, bool) {
+	create := false
+	for _, operation := range operations {
+		switch operation {
+		case admissionregistrationv1alpha1.OperationAll, admissionregistrationv1alpha1.Update:
+			return admission.Update, true
+		case admissionregistrationv1alpha1.Create:
+			create = true
+		}
+	}
+	return admission.Create, create
+}
+
+// Records the failed validations of a binding for obj
+func (sc *scan) record(obj *unstructured.Unstructured, result evaluate.BindingResult) {
+	var messages, errs []string
+	if len(result.Error) > 0 {
+		errs = append(errs, result.Error)
+	}
+
+	for _, validation := range result.Validations {
+		switch validation.Outcome {
+		case evaluate.ValidationDeny:
+			messages = append(messages, validation.Message)
+		case evaluate.ValidationError:
+			errs = append(errs, validation.Error)
+		}
+	}
+
+	if len(messages) == 0 && len(errs) == 0 {
+		return
+	}
+
+	key := bindingKey{policy: result.Policy, binding: result.Binding}
+	violations, found := sc.violations[key]
+	if !found {
+		violations = &Violations{
+			Policy:          result.Policy,
+			Binding:         result.Binding,
+			Actions:         result.Actions,
+			Namespaces:      map[string]int{},
+			ErrorNamespaces: map[string]int{},
+		}
+		sc.violations[key] = violations
+	}
+
+	if len(messages) > 0 {
+		violations.Count++
+		violations.Namespaces[obj.GetNamespace()]++
+	}
+	if len(errs) > 0 {
+		violations.Errors++
+		violations.ErrorNamespaces[obj.GetNamespace()]++
+	}
+
+	if len(violations.Samples) < samplesPerBinding {
+		violations.Samples = append(violations.Samples, Sample{
+			Object: corev1.ObjectReference{
+				APIVersion:      obj.GetAPIVersion(),
+				Kind:            obj.GetKind(),
+				Namespace:       obj.GetNamespace(),
+				Name:            obj.GetName(),
+				UID:             obj.GetUID(),
+				ResourceVersion: obj.GetResourceVersion(),
+			},
+			Messages: messages,
+			Errors:   errs,
+		})
+	}
+}
+
+// Reads the param a binding refers to through the dynamic client
+func (sc *scan) findParam(paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error) {
+	gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key := paramKey{gvk: gv.WithKind(paramKind.Kind), namespace: ref.Namespace, name: ref.Name}
+	if cached, found := sc.params[key]; found {
+		return cached.param, cached.err
+	}
+
+	result := paramResult{}
+	if mapping, err := sc.restMapper.RESTMapping(key.gvk.GroupKind(), key.gvk.Version); err != nil {
+		result.err = err
+	} else if err := sc.limiter.Wait(sc.ctx); err != nil {
+		return nil, err
+	} else if param, err := sc.dynamicClient.Resource(mapping.Resource).Namespace(ref.Namespace).Get(sc.ctx, ref.Name, metav1.GetOptions{}); err != nil {
+		result.err = err
+	} else {
+		result.param = param
+	}
+
+	sc.params[key] = result
+	return result.param, result.err
+}
+
+// Completes the report of the scan
+func (sc *scan) finish() *Report {
+	for _, violations := range sc.violations {
+		sc.report.Violations = append(sc.report.Violations, *violations)
+	}
+
+	sort.Slice(sc.report.Violations, func(i, j int) bool {
+		if sc.report.Violations[i].Policy != sc.report.Violations[j].Policy {
+			return sc.report.Violations[i].Policy < sc.report.Violations[j].Policy
+		}
+		return sc.report.Violations[i].Binding < sc.report.Violations[j].Binding
+	})
+
+	sc.report.Completed = time.Now()
+	return sc.report
+}
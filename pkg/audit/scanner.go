@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/matching"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	admissionregistrationv1alpha1listers "k8s.io/client-go/listers/admissionregistration/v1alpha1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+// Number of objects listed per request
+const pageSize = 250
+
+// Number of violating objects kept for each binding
+const samplesPerBinding = 10
+
+// User the requests synthesized for existing objects are made by
+var auditUser = &user.DefaultInfo{
+	Name:   "system:cel-admission-polyfill:audit",
+	Groups: []string{user.AllAuthenticated},
+}
+
+// Periodically evaluates the objects which already exist in the cluster
+// against the policies with active bindings. Admission only sees writes, so
+// objects created before a policy would otherwise violate it unnoticed.
+type Scanner struct {
+	policyLister  admissionregistrationv1alpha1listers.ValidatingAdmissionPolicyLister
+	bindingLister admissionregistrationv1alpha1listers.ValidatingAdmissionPolicyBindingLister
+	hasSynced     []cache.InformerSynced
+
+	restMapper    meta.RESTMapper
+	discovery     discovery.DiscoveryInterface
+	dynamicClient dynamic.Interface
+	matcher       validatingadmissionpolicy.Matcher
+
+	// Every request made to the apiserver while scanning waits on the limiter
+	limiter  flowcontrol.RateLimiter
+	interval time.Duration
+
+	lock   sync.RWMutex
+	report *Report
+}
+
+func NewScanner(
+	factory informers.SharedInformerFactory,
+	client kubernetes.Interface,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
+	limiter flowcontrol.RateLimiter,
+	interval time.Duration,
+) *Scanner {
+	policies := factory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicies()
+	bindings := factory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicyBindings()
+	namespaces := factory.Core().V1().Namespaces()
+
+	return &Scanner{
+		policyLister:  policies.Lister(),
+		bindingLister: bindings.Lister(),
+		hasSynced: []cache.InformerSynced{
+			policies.Informer().HasSynced,
+			bindings.Informer().HasSynced,
+			namespaces.Informer().HasSynced,
+		},
+		restMapper:    restMapper,
+		discovery:     client.Discovery(),
+		dynamicClient: dynamicClient,
+		matcher:       validatingadmissionpolicy.NewMatcher(matching.NewMatcher(namespaces.Lister(), client)),
+		limiter:       limiter,
+		interval:      interval,
+	}
+}
+
+// Scans the cluster every interval until ctx is cancelled
+func (s *Scanner) Run(ctx context.Context) error {
+	if !cache.WaitForNamedCacheSync("audit", ctx.Done(), s.hasSynced...) {
+		return ctx.Err()
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		report, err := s.Scan(ctx)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("audit scan failed: %w", err))
+			return
+		}
+
+		klog.Infof("audit scanned %d objects in %v", report.Scanned, report.Completed.Sub(report.Started))
+		for _, v := range report.Violations {
+			klog.Warningf("ValidatingAdmissionPolicy '%s' with binding '%s' is violated by %d existing objects", v.Policy, v.Binding, v.Count)
+		}
+		for _, skipped := range report.Skipped {
+			klog.Warningf("audit skipped %v for ValidatingAdmissionPolicy '%s': %s", skipped.Resource, skipped.Policy, skipped.Reason)
+		}
+	}, s.interval)
+	return nil
+}
+
+// Returns the report of the last completed scan, or nil if none has
+// completed yet
+func (s *Scanner) Report() *Report {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.report
+}
+
+// Evaluates every object matched by a policy with bindings. Objects which
+// cannot be evaluated are reported rather than failing the scan.
+func (s *Scanner) Scan(ctx context.Context) (*Report, error) {
+	policies, err := s.policyLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	allBindings, err := s.bindingLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+
+	scan := newScan(ctx, s)
+	for _, policy := range policies {
+		var bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+		for _, binding := range allBindings {
+			if binding.Spec.PolicyName == policy.Name {
+				bindings = append(bindings, binding)
+			}
+		}
+
+		if len(bindings) == 0 {
+			continue
+		}
+
+		if err := scan.policy(ctx, policy, bindings); err != nil {
+			return nil, err
+		}
+	}
+
+	report := scan.finish()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.report = report
+	return report, nil
+}
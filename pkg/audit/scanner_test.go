@@ -0,0 +1,275 @@
+package audit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/audit"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Counts the requests made by the scanner
+type countingLimiter struct {
+	flowcontrol.RateLimiter
+
+	lock  sync.Mutex
+	waits int
+}
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.waits++
+	return nil
+}
+
+func namespace(name, env string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{"env": env, corev1.LabelMetadataName: name},
+	}}
+}
+
+func TestScan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fail := admissionregistrationv1alpha1.Fail
+	equivalent := admissionregistrationv1alpha1.Equivalent
+
+	policy := &admissionregistrationv1alpha1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"},
+		Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: &fail,
+			ParamKind:     &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+			MatchConstraints: &admissionregistrationv1alpha1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				MatchPolicy:       &equivalent,
+				ResourceRules: []admissionregistrationv1alpha1.NamedRuleWithOperations{{
+					RuleWithOperations: admissionregistrationv1alpha1.RuleWithOperations{
+						Operations: []admissionregistrationv1alpha1.OperationType{"CREATE", "UPDATE"},
+						Rule: admissionregistrationv1alpha1.Rule{
+							APIGroups:   []string{"apps"},
+							APIVersions: []string{"*"},
+							Resources:   []string{"deployments"},
+						},
+					},
+				}},
+			},
+			Validations: []admissionregistrationv1alpha1.Validation{{
+				Expression: "object.spec.replicas <= int(params.data.maxReplicas)",
+				Message:    "too many replicas",
+			}},
+		},
+	}
+
+	// Policies without bindings are not scanned
+	unbound := policy.DeepCopy()
+	unbound.Name = "unbound"
+
+	binding := &admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit-prod"},
+		Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: "replica-limit",
+			ParamRef:   &admissionregistrationv1alpha1.ParamRef{Name: "limits", Namespace: "default"},
+			MatchResources: &admissionregistrationv1alpha1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+				ObjectSelector:    &metav1.LabelSelector{},
+				MatchPolicy:       &equivalent,
+			},
+			ValidationActions: []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+		},
+	}
+
+	client := fake.NewSimpleClientset(policy, unbound, binding, namespace("prod", "prod"), namespace("dev", "dev"))
+
+	limits := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "limits", "namespace": "default"},
+		"data":       map[string]interface{}{"maxReplicas": "3"},
+	}}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+			{Version: "v1", Resource: "configmaps"}:                 "ConfigMapList",
+		},
+		limits,
+		evaluatetest.Deployment("prod", "small", 2, nil),
+		evaluatetest.Deployment("prod", "big", 5, nil),
+		evaluatetest.Deployment("prod", "huge", 9, nil),
+		evaluatetest.Deployment("dev", "big", 7, nil),
+	)
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}, {Version: "v1"}})
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	limiter := &countingLimiter{}
+	factory := informers.NewSharedInformerFactory(client, 0)
+	scanner := audit.NewScanner(factory, client, restMapper, dynamicClient, limiter, time.Hour)
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	if scanner.Report() != nil {
+		t.Fatal("expected no report before the first scan")
+	}
+
+	report, err := scanner.Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Scanned != 4 {
+		t.Errorf("expected 4 objects to be scanned, got %d", report.Scanned)
+	}
+
+	if len(report.Violations) != 1 {
+		t.Fatalf("expected one binding to be violated, got: %+v", report.Violations)
+	}
+
+	violations := report.Violations[0]
+	if violations.Policy != "replica-limit" || violations.Binding != "replica-limit-prod" {
+		t.Errorf("unexpected binding %s/%s", violations.Policy, violations.Binding)
+	}
+
+	if violations.Count != 2 || violations.Errors != 0 {
+		t.Errorf("expected 2 violations and no errors, got %d and %d", violations.Count, violations.Errors)
+	}
+
+	if len(violations.Namespaces) != 1 || violations.Namespaces["prod"] != 2 {
+		t.Errorf("expected 2 violations in prod, got: %v", violations.Namespaces)
+	}
+
+	violators := map[string]bool{}
+	for _, sample := range violations.Samples {
+		violators[sample.Object.Namespace+"/"+sample.Object.Name] = true
+		if len(sample.Messages) != 1 || sample.Messages[0] != "too many replicas" {
+			t.Errorf("unexpected messages for %s: %q", sample.Object.Name, sample.Messages)
+		}
+	}
+
+	if len(violators) != 2 || !violators["prod/big"] || !violators["prod/huge"] {
+		t.Errorf("expected prod/big and prod/huge to be sampled, got: %v", violators)
+	}
+
+	// One list of deployments, and one read of the param
+	if limiter.waits != 2 {
+		t.Errorf("expected 2 rate limited requests, got %d", limiter.waits)
+	}
+
+	if scanner.Report() != report {
+		t.Error("expected the report of the scan to be kept")
+	}
+}
+
+func TestScanWildcard(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fail := admissionregistrationv1alpha1.Fail
+	equivalent := admissionregistrationv1alpha1.Equivalent
+
+	policy := &admissionregistrationv1alpha1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"},
+		Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: &fail,
+			MatchConstraints: &admissionregistrationv1alpha1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				MatchPolicy:       &equivalent,
+				ResourceRules: []admissionregistrationv1alpha1.NamedRuleWithOperations{{
+					RuleWithOperations: admissionregistrationv1alpha1.RuleWithOperations{
+						Operations: []admissionregistrationv1alpha1.OperationType{"UPDATE"},
+						Rule: admissionregistrationv1alpha1.Rule{
+							APIGroups:   []string{"apps"},
+							APIVersions: []string{"*"},
+							Resources:   []string{"*", "deployments/scale"},
+						},
+					},
+				}},
+			},
+			Validations: []admissionregistrationv1alpha1.Validation{{
+				Expression: "object.spec.replicas <= 3",
+				Message:    "too many replicas",
+			}},
+		},
+	}
+
+	binding := &admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"},
+		Spec: admissionregistrationv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "replica-limit",
+			ValidationActions: []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+		},
+	}
+
+	client := fake.NewSimpleClientset(policy, binding, namespace("prod", "prod"))
+	client.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "apps/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "deployments", Namespaced: true, Kind: "Deployment", Verbs: metav1.Verbs{"get", "list"}},
+				{Name: "deployments/scale", Namespaced: true, Kind: "Scale", Verbs: metav1.Verbs{"get", "update"}},
+			},
+		},
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{
+				{Name: "configmaps", Namespaced: true, Kind: "ConfigMap", Verbs: metav1.Verbs{"get", "list"}},
+			},
+		},
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+		},
+		evaluatetest.Deployment("prod", "small", 2, nil),
+		evaluatetest.Deployment("prod", "big", 5, nil),
+	)
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}})
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	scanner := audit.NewScanner(factory, client, restMapper, dynamicClient, &countingLimiter{}, time.Hour)
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+
+	report, err := scanner.Scan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the deployments of the apps group are discovered for the wildcard
+	if report.Scanned != 2 {
+		t.Errorf("expected 2 objects to be scanned, got %d", report.Scanned)
+	}
+
+	if len(report.Violations) != 1 || report.Violations[0].Count != 1 {
+		t.Errorf("expected one violation, got: %+v", report.Violations)
+	}
+
+	expected := schema.GroupVersionResource{Group: "apps", Version: "*", Resource: "deployments/scale"}
+	if len(report.Skipped) != 1 || report.Skipped[0].Resource != expected || report.Skipped[0].Policy != "replica-limit" {
+		t.Errorf("expected %v to be skipped, got: %+v", expected, report.Skipped)
+	}
+}
@@ -0,0 +1,371 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	polyfilllisters "github.com/alexzielenski/cel_polyfill/pkg/generated/listers/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Access which the author of a policy or binding must have
+type access struct {
+	namespace   string
+	group       string
+	resource    string
+	subresource string
+	name        string
+}
+
+func (a access) String() string {
+	resource := a.resource
+	if len(a.group) > 0 {
+		resource += "." + a.group
+	}
+	if len(a.subresource) > 0 {
+		resource += "/" + a.subresource
+	}
+	if len(a.name) > 0 {
+		resource += " " + a.name
+	}
+	if len(a.namespace) > 0 {
+		resource += " in namespace " + a.namespace
+	}
+	return resource
+}
+
+// Validates the authoring of the policies and bindings of the polyfill,
+// which the apiserver does not authorize beyond the verbs on the CRDs
+// themselves. Like the RBAC checks of upstream, the author of a policy must
+// be able to get its paramKind, and the author of a binding the object of
+// its paramRef. Both must also be able to get the resources they validate.
+// Access is checked with SubjectAccessReviews for the user of the request.
+// Updates only check the access which the old object did not already need.
+type Validator struct {
+	policyLister           polyfilllisters.ValidatingAdmissionPolicyLister
+	namespacedPolicyLister polyfilllisters.NamespacedValidatingAdmissionPolicyLister
+	hasSynced              []cache.InformerSynced
+
+	client     kubernetes.Interface
+	restMapper meta.RESTMapper
+}
+
+func NewValidator(
+	customFactory externalversions.SharedInformerFactory,
+	client kubernetes.Interface,
+	restMapper meta.RESTMapper,
+) *Validator {
+	policies := customFactory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicies()
+	namespacedPolicies := customFactory.Admissionregistration().V1alpha1().NamespacedValidatingAdmissionPolicies()
+
+	return &Validator{
+		policyLister:           policies.Lister(),
+		namespacedPolicyLister: namespacedPolicies.Lister(),
+		hasSynced: []cache.InformerSynced{
+			policies.Informer().HasSynced,
+			namespacedPolicies.Informer().HasSynced,
+		},
+		client:     client,
+		restMapper: restMapper,
+	}
+}
+
+func (v *Validator) HasSynced() bool {
+	for _, hasSynced := range v.hasSynced {
+		if !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Validator) Handles(operation admission.Operation) bool {
+	return operation == admission.Create || operation == admission.Update
+}
+
+func (v *Validator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	resource := a.GetResource()
+	if resource.Group != polyfillv1alpha1.GroupName || len(a.GetSubresource()) > 0 {
+		return nil
+	}
+
+	var required func(obj runtime.Object) ([]access, error)
+	switch resource.Resource {
+	case "validatingadmissionpolicies":
+		required = v.policyAccess
+	case "validatingadmissionpolicybindings":
+		required = v.bindingAccess
+	case "namespacedvalidatingadmissionpolicies":
+		required = v.namespacedPolicyAccess
+	case "namespacedvalidatingadmissionpolicybindings":
+		required = v.namespacedBindingAccess
+	default:
+		return nil
+	}
+
+	// Bindings of policies missing from the listers would be denied
+	if err := wait.PollImmediateWithContext(ctx, 100*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return v.HasSynced(), nil
+	}); err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("not yet ready to handle request"))
+	}
+
+	accesses, err := required(a.GetObject())
+	if err != nil {
+		return admission.NewForbidden(a, err)
+	}
+
+	var oldAccesses []access
+	if a.GetOperation() == admission.Update && a.GetOldObject() != nil {
+		// Failing to work out the access of the old object only checks more
+		oldAccesses, _ = required(a.GetOldObject())
+	}
+	accesses = subtract(accesses, oldAccesses)
+
+	var denied []string
+	for _, access := range accesses {
+		allowed, err := v.authorize(ctx, a.GetUserInfo(), access)
+		if err != nil {
+			return admission.NewForbidden(a, fmt.Errorf("authorizing get of %s: %w", access, err))
+		} else if !allowed {
+			denied = append(denied, access.String())
+		}
+	}
+
+	if len(denied) > 0 {
+		return admission.NewForbidden(a, fmt.Errorf("user %q cannot get %s", a.GetUserInfo().GetName(), strings.Join(denied, ", ")))
+	}
+	return nil
+}
+
+// Returns whether a user may get the resource of access
+func (v *Validator) authorize(ctx context.Context, userInfo user.Info, access access) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range userInfo.GetExtra() {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	review, err := v.client.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   userInfo.GetName(),
+			Groups: userInfo.GetGroups(),
+			UID:    userInfo.GetUID(),
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace:   access.namespace,
+				Verb:        "get",
+				Group:       access.group,
+				Resource:    access.resource,
+				Subresource: access.subresource,
+				Name:        access.name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+func (v *Validator) policyAccess(obj runtime.Object) ([]access, error) {
+	policy, err := decode[polyfillv1alpha1.ValidatingAdmissionPolicy](obj)
+	if err != nil {
+		return nil, err
+	}
+	return v.specAccess(&policy.Spec, "")
+}
+
+func (v *Validator) namespacedPolicyAccess(obj runtime.Object) ([]access, error) {
+	policy, err := decode[polyfillv1alpha1.NamespacedValidatingAdmissionPolicy](obj)
+	if err != nil {
+		return nil, err
+	}
+	return v.specAccess(&policy.Spec, policy.Namespace)
+}
+
+// Returns the access needed to author a policy in namespace, which is empty
+// for cluster scoped policies
+func (v *Validator) specAccess(spec *polyfillv1alpha1.ValidatingAdmissionPolicySpec, namespace string) ([]access, error) {
+	var accesses []access
+	if spec.ParamKind != nil {
+		mapping, err := v.paramMapping(spec.ParamKind)
+		if err != nil {
+			return nil, err
+		}
+
+		paramAccess := access{group: mapping.Resource.Group, resource: mapping.Resource.Resource}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			paramAccess.namespace = namespace
+		}
+		accesses = append(accesses, paramAccess)
+	}
+
+	if spec.MatchConstraints != nil {
+		accesses = append(accesses, ruleAccess(spec.MatchConstraints.ResourceRules, namespace)...)
+	}
+	return accesses, nil
+}
+
+func (v *Validator) bindingAccess(obj runtime.Object) ([]access, error) {
+	binding, err := decode[polyfillv1alpha1.ValidatingAdmissionPolicyBinding](obj)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := v.policyLister.Get(binding.Spec.PolicyName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var policySpec *polyfillv1alpha1.ValidatingAdmissionPolicySpec
+	if policy != nil {
+		policySpec = &policy.Spec
+	}
+
+	return v.bindingSpecAccess(binding.Spec.PolicyName, policySpec, binding.Spec.ParamRef, binding.Spec.MatchResources, "")
+}
+
+func (v *Validator) namespacedBindingAccess(obj runtime.Object) ([]access, error) {
+	binding, err := decode[polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBinding](obj)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := v.namespacedPolicyLister.NamespacedValidatingAdmissionPolicies(binding.Namespace).Get(binding.Spec.PolicyName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var policySpec *polyfillv1alpha1.ValidatingAdmissionPolicySpec
+	if policy != nil {
+		policySpec = &policy.Spec
+	}
+
+	var ref *polyfillv1alpha1.ParamRef
+	if binding.Spec.ParamRef != nil {
+		ref = &polyfillv1alpha1.ParamRef{Name: binding.Spec.ParamRef.Name, Namespace: binding.Namespace}
+	}
+	return v.bindingSpecAccess(binding.Spec.PolicyName, policySpec, ref, binding.Spec.MatchResources, binding.Namespace)
+}
+
+// Returns the access needed to author a binding of a policy in namespace,
+// which is empty for cluster scoped bindings. policy is nil if it does not
+// exist yet.
+func (v *Validator) bindingSpecAccess(
+	policyName string,
+	policy *polyfillv1alpha1.ValidatingAdmissionPolicySpec,
+	ref *polyfillv1alpha1.ParamRef,
+	match *polyfillv1alpha1.MatchResources,
+	namespace string,
+) ([]access, error) {
+	var accesses []access
+	if ref != nil {
+		// The kind of the param is only known from the policy. Otherwise a
+		// policy created later could expose any object the binding names
+		if policy == nil {
+			return nil, fmt.Errorf("policy %s must exist before a binding references params", policyName)
+		} else if policy.ParamKind == nil {
+			return nil, fmt.Errorf("policy %s has no paramKind for paramRef", policyName)
+		}
+
+		mapping, err := v.paramMapping(policy.ParamKind)
+		if err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, access{namespace: ref.Namespace, group: mapping.Resource.Group, resource: mapping.Resource.Resource, name: ref.Name})
+	}
+
+	// Bindings validate every resource of the policy unless they select
+	// their own
+	if match != nil && len(match.ResourceRules) > 0 {
+		accesses = append(accesses, ruleAccess(match.ResourceRules, namespace)...)
+	} else if policy != nil && policy.MatchConstraints != nil {
+		accesses = append(accesses, ruleAccess(policy.MatchConstraints.ResourceRules, namespace)...)
+	}
+	return accesses, nil
+}
+
+func (v *Validator) paramMapping(paramKind *polyfillv1alpha1.ParamKind) (*meta.RESTMapping, error) {
+	gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("paramKind: %w", err)
+	}
+
+	mapping, err := v.restMapper.RESTMapping(gv.WithKind(paramKind.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, fmt.Errorf("paramKind: %w", err)
+	}
+	return mapping, nil
+}
+
+// Returns the access needed to validate the resources of rules in namespace
+func ruleAccess(rules []polyfillv1alpha1.NamedRuleWithOperations, namespace string) []access {
+	var accesses []access
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				resource, subresource, _ := strings.Cut(resource, "/")
+
+				if len(rule.ResourceNames) == 0 {
+					accesses = append(accesses, access{namespace: namespace, group: group, resource: resource, subresource: subresource})
+				}
+				for _, name := range rule.ResourceNames {
+					accesses = append(accesses, access{namespace: namespace, group: group, resource: resource, subresource: subresource, name: name})
+				}
+			}
+		}
+	}
+	return accesses
+}
+
+// Returns the accesses which are not in old, without duplicates
+func subtract(accesses, old []access) []access {
+	seen := map[access]bool{}
+	for _, a := range old {
+		seen[a] = true
+	}
+
+	var result []access
+	for _, a := range accesses {
+		if !seen[a] {
+			seen[a] = true
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// Returns the object of a request as T. Objects of the polyfill are decoded
+// into their types by the webhook, or left unstructured if it has no scheme
+// for them
+func decode[T any](obj runtime.Object) (*T, error) {
+	if typed, ok := any(obj).(*T); ok {
+		return typed, nil
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T", obj)
+	}
+
+	var result T
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
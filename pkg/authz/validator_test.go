@@ -0,0 +1,191 @@
+package authz_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/authz"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+// The resources each user may get, as namespace/group/resource/name
+var permissions = map[string]sets.String{
+	"alice": sets.NewString(
+		"default//configmaps/limits",
+		"/apps/deployments/",
+		"team-a//configmaps/limits",
+		"team-a/apps/deployments/",
+	),
+	"bob": sets.NewString(
+		"/apps/deployments/",
+	),
+}
+
+func deployments() []polyfillv1alpha1.NamedRuleWithOperations {
+	return []polyfillv1alpha1.NamedRuleWithOperations{{
+		RuleWithOperations: polyfillv1alpha1.RuleWithOperations{
+			Operations: []polyfillv1alpha1.OperationType{"CREATE"},
+			Rule: polyfillv1alpha1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"deployments"},
+			},
+		},
+	}}
+}
+
+func policySpec() polyfillv1alpha1.ValidatingAdmissionPolicySpec {
+	return polyfillv1alpha1.ValidatingAdmissionPolicySpec{
+		ParamKind:        &polyfillv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+		MatchConstraints: &polyfillv1alpha1.MatchResources{ResourceRules: deployments()},
+		Validations:      []polyfillv1alpha1.Validation{{Expression: "object.spec.replicas <= int(params.data.maxReplicas)"}},
+	}
+}
+
+func binding(policyName, param string) *polyfillv1alpha1.ValidatingAdmissionPolicyBinding {
+	return &polyfillv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: policyName + "-" + param},
+		Spec: polyfillv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        policyName,
+			ParamRef:          &polyfillv1alpha1.ParamRef{Name: param, Namespace: "default"},
+			ValidationActions: []polyfillv1alpha1.ValidationAction{polyfillv1alpha1.Deny},
+		},
+	}
+}
+
+func attributes(resource string, obj, oldObj runtime.Object, username string) admission.Attributes {
+	operation := admission.Create
+	if oldObj != nil {
+		operation = admission.Update
+	}
+
+	accessor, _ := meta.Accessor(obj)
+	return admission.NewAttributesRecord(
+		obj,
+		oldObj,
+		schema.GroupVersionKind{},
+		accessor.GetNamespace(),
+		accessor.GetName(),
+		polyfillv1alpha1.SchemeGroupVersion.WithResource(resource),
+		"",
+		operation,
+		nil,
+		false,
+		&user.DefaultInfo{Name: username},
+	)
+}
+
+func TestValidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		if attrs.Verb != "get" {
+			t.Errorf("unexpected verb %s", attrs.Verb)
+		}
+
+		key := strings.Join([]string{attrs.Namespace, attrs.Group, attrs.Resource, attrs.Name}, "/")
+		review.Status.Allowed = permissions[review.Spec.User].Has(key)
+		return true, review, nil
+	})
+
+	customClient := polyfillfake.NewSimpleClientset(
+		&polyfillv1alpha1.ValidatingAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"}, Spec: policySpec()},
+		&polyfillv1alpha1.NamespacedValidatingAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "replica-limit"}, Spec: policySpec()},
+	)
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	validator := authz.NewValidator(customFactory, client, restMapper)
+	customFactory.Start(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+
+	otherParam := binding("replica-limit", "limits")
+	otherParam.Spec.ParamRef.Name = "secret-limits"
+
+	namespacedBinding := &polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "replica-limit"},
+		Spec: polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "replica-limit",
+			ParamRef:          &polyfillv1alpha1.NamespacedParamRef{Name: "limits"},
+			ValidationActions: []polyfillv1alpha1.ValidationAction{polyfillv1alpha1.Deny},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		attrs    admission.Attributes
+		expected string
+	}{
+		{
+			name:  "binding",
+			attrs: attributes("validatingadmissionpolicybindings", binding("replica-limit", "limits"), nil, "alice"),
+		},
+		{
+			name:     "binding without access to param",
+			attrs:    attributes("validatingadmissionpolicybindings", binding("replica-limit", "limits"), nil, "bob"),
+			expected: `validatingadmissionpolicybindings.admissionregistration.polyfill.sigs.k8s.io "replica-limit-limits" is forbidden: user "bob" cannot get configmaps limits in namespace default`,
+		},
+		{
+			name:     "binding of missing policy",
+			attrs:    attributes("validatingadmissionpolicybindings", binding("missing", "limits"), nil, "alice"),
+			expected: `validatingadmissionpolicybindings.admissionregistration.polyfill.sigs.k8s.io "missing-limits" is forbidden: policy missing must exist before a binding references params`,
+		},
+		{
+			name:  "update keeping param",
+			attrs: attributes("validatingadmissionpolicybindings", binding("replica-limit", "limits"), binding("replica-limit", "limits"), "bob"),
+		},
+		{
+			name:     "update changing param",
+			attrs:    attributes("validatingadmissionpolicybindings", otherParam, binding("replica-limit", "limits"), "alice"),
+			expected: `validatingadmissionpolicybindings.admissionregistration.polyfill.sigs.k8s.io "replica-limit-limits" is forbidden: user "alice" cannot get configmaps secret-limits in namespace default`,
+		},
+		{
+			name:     "policy without access to paramKind",
+			attrs:    attributes("validatingadmissionpolicies", &polyfillv1alpha1.ValidatingAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"}, Spec: policySpec()}, nil, "bob"),
+			expected: `validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io "replica-limit" is forbidden: user "bob" cannot get configmaps`,
+		},
+		{
+			name:  "namespaced binding",
+			attrs: attributes("namespacedvalidatingadmissionpolicybindings", namespacedBinding, nil, "alice"),
+		},
+		{
+			name:     "namespaced policy without access to resources",
+			attrs:    attributes("namespacedvalidatingadmissionpolicies", &polyfillv1alpha1.NamespacedValidatingAdmissionPolicy{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "replica-limit"}, Spec: policySpec()}, nil, "bob"),
+			expected: `namespacedvalidatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io "replica-limit" is forbidden: user "bob" cannot get configmaps in namespace team-a, deployments.apps in namespace team-a`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validator.Validate(ctx, c.attrs, nil)
+
+			message := ""
+			if err != nil {
+				message = err.Error()
+			}
+
+			if message != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, message)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ import (
 type replacedClient struct {
 	admissionregistrationv1alpha1.AdmissionregistrationV1alpha1Interface
 	replacement admissionregistrationpolyfillclient.AdmissionregistrationV1alpha1Interface
+	keep        func(*v1alpha1.ValidatingAdmissionPolicyBinding) bool
 }
 
 func (r replacedClient) ValidatingAdmissionPolicies() admissionregistrationv1alpha1.ValidatingAdmissionPolicyInterface {
@@ -38,18 +39,28 @@ func (r replacedClient) ValidatingAdmissionPolicyBindings() admissionregistratio
 		ReplacementClient: r.replacement.ValidatingAdmissionPolicyBindings(),
 		To:                CRDToNativePolicyBinding,
 		From:              NativeToCRDPolicyBinding,
+		Keep:              r.keep,
 	}
 }
 
+// Shadow bindings are hidden from enforcement, so they never affect
+// responses
+func IsEnforced(binding *v1alpha1.ValidatingAdmissionPolicyBinding) bool {
+	return !binding.Spec.Shadow
+}
+
 type wrappedClient struct {
 	kubernetes.Interface
 	replacement admissionregistrationpolyfillclient.AdmissionregistrationV1alpha1Interface
+	keep        func(*v1alpha1.ValidatingAdmissionPolicyBinding) bool
 }
 
+// Returns a client whose bindings are the enforced polyfill bindings
 func NewWrappedClient(client kubernetes.Interface, customClient versioned.Interface) kubernetes.Interface {
 	return wrappedClient{
 		Interface:   client,
 		replacement: customClient.AdmissionregistrationV1alpha1(),
+		keep:        IsEnforced,
 	}
 }
 
@@ -57,6 +68,7 @@ func (w wrappedClient) AdmissionregistrationV1alpha1() admissionregistrationv1al
 	return replacedClient{
 		replacement:                            w.replacement,
 		AdmissionregistrationV1alpha1Interface: w.Interface.AdmissionregistrationV1alpha1(),
+		keep:                                   w.keep,
 	}
 }
 
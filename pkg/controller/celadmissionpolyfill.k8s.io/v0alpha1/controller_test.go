@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"io/ioutil"
+	"reflect"
 	"testing"
 	"time"
 
@@ -13,14 +14,24 @@ import (
 	"github.com/alexzielenski/cel_polyfill/pkg/controller/structuralschema"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 )
 
 func TestBasic(t *testing.T) {
@@ -38,7 +49,7 @@ func TestBasic(t *testing.T) {
 	structuralschemaController := structuralschema.NewController(
 		apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
 	)
-	vald := controllerv0alpha1.NewValidator(structuralschemaController)
+	vald := controllerv0alpha1.NewValidator(structuralschemaController, nil, nil, nil)
 
 	// Populates the validator with rule sets depending upon the CRD definition
 	controller := controllerv0alpha1.NewAdmissionRulesController(
@@ -90,6 +101,14 @@ func TestBasic(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
+	// Wait until the rules are picked up by the validator
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		return vald.Validate2(basicUnionsGVR, nil, &BasicUnion{}) != nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	// Run test cases
 	type testCase struct {
 		filename      string
@@ -130,18 +149,14 @@ func TestBasic(t *testing.T) {
 			t.Fatalf(err.Error())
 		}
 
-		err := vald.Validate2(schema.GroupVersionResource{
-			Group:    "stable.example.com",
-			Version:  "v1",
-			Resource: "basicunions",
-		}, prev, obj)
+		err := vald.Validate2(basicUnionsGVR, prev, obj)
 
 		var returnedErrs []error
 
 		if err != nil {
 			if list, ok := err.(utilerrors.Aggregate); ok {
 				returnedErrs = list.Errors()
-			} else if err.Error != nil {
+			} else if err.Error() != "" {
 				returnedErrs = append(returnedErrs, err)
 			} else {
 				panic("status not OK but error nil?")
@@ -180,6 +195,163 @@ func TestBasic(t *testing.T) {
 	}
 }
 
+func TestAdmissionEnvironment(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	file, err := ioutil.ReadFile("testdata/stable.example.com_basicunions.yaml")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24).Decode(crd)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(configMapGVK, meta.RESTScopeNamespace)
+
+	scheme := runtime.NewScheme()
+	corev1.AddToScheme(scheme)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme, &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "allowed-env", Namespace: "default"},
+		Data:       map[string]string{"env": "prod"},
+	})
+	kubeClient := kubefake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}},
+	}, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "staging", Labels: map[string]string{"env": "staging"}},
+	})
+	fakeext := apiextensionsfake.NewSimpleClientset(crd)
+
+	kubeFactory := informers.NewSharedInformerFactory(kubeClient, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+
+	structuralschemaController := structuralschema.NewController(
+		apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+	)
+	namespaceInformer := kubeFactory.Core().V1().Namespaces()
+	vald := controllerv0alpha1.NewValidator(structuralschemaController, namespaceInformer.Lister(), restMapper, dynamicClient)
+
+	kubeFactory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+	kubeFactory.WaitForCacheSync(ctx.Done())
+	apiextensionsFactory.WaitForCacheSync(ctx.Done())
+
+	file, err = ioutil.ReadFile("testdata/admission_environment_rules.yaml")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	rules := &v0alpha1.ValidationRuleSet{}
+	err = yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24).Decode(rules)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	vald.AddRuleSet(rules)
+
+	newObject := func(namespace, value string) *unstructured.Unstructured {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&BasicUnion{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "stable.example.com/v1", Kind: "BasicUnion"},
+			ObjectMeta: metav1.ObjectMeta{Name: "testobject", Namespace: namespace},
+			Spec:       BasicUnionSpec{Discriminator: "mode1", Mode1: value, Value: value},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: obj}
+	}
+
+	cases := []struct {
+		name          string
+		operation     admission.Operation
+		username      string
+		object        *unstructured.Unstructured
+		oldObject     *unstructured.Unstructured
+		errorExpected []string
+	}{
+		{
+			name:      "allowed",
+			operation: admission.Create,
+			username:  "alice",
+			object:    newObject("default", "hello"),
+		},
+		{
+			name:          "blocked user",
+			operation:     admission.Create,
+			username:      "mallory",
+			object:        newObject("default", "hello"),
+			errorExpected: []string{"user is not allowed to create basicunions"},
+		},
+		{
+			name:          "namespace does not match params",
+			operation:     admission.Create,
+			username:      "alice",
+			object:        newObject("staging", "hello"),
+			errorExpected: []string{"namespace env label must match params"},
+		},
+		{
+			name:      "update without change",
+			operation: admission.Update,
+			username:  "alice",
+			object:    newObject("default", "hello"),
+			oldObject: newObject("default", "hello"),
+		},
+		{
+			name:          "update changing immutable value",
+			operation:     admission.Update,
+			username:      "alice",
+			object:        newObject("default", "world"),
+			oldObject:     newObject("default", "hello"),
+			errorExpected: []string{"value is immutable"},
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.name, func(t *testing.T) {
+			var oldObject runtime.Object
+			if cs.oldObject != nil {
+				oldObject = cs.oldObject
+			}
+
+			attrs := admission.NewAttributesRecord(
+				cs.object,
+				oldObject,
+				schema.GroupVersionKind{Group: "stable.example.com", Version: "v1", Kind: "BasicUnion"},
+				cs.object.GetNamespace(),
+				cs.object.GetName(),
+				basicUnionsGVR,
+				"",
+				cs.operation,
+				nil,
+				false,
+				&user.DefaultInfo{Name: cs.username},
+			)
+
+			err := vald.Validate(ctx, attrs, nil)
+
+			var details []string
+			if err != nil {
+				for _, e := range err.(utilerrors.Aggregate).Errors() {
+					details = append(details, e.(*field.Error).Detail)
+				}
+			}
+
+			if !reflect.DeepEqual(cs.errorExpected, details) {
+				t.Fatalf("unexpected errors:\n\texpected: %v\n\tactual: %v", cs.errorExpected, details)
+			}
+		})
+	}
+}
+
+var basicUnionsGVR = schema.GroupVersionResource{
+	Group:    "stable.example.com",
+	Version:  "v1",
+	Resource: "basicunions",
+}
+
 type BasicUnion struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,321 @@
+package v0alpha1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+
+	polyfillv0 "github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha1"
+	kcel "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
+	celmodel "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel/model"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/library"
+)
+
+const (
+	selfVarName            = "self"
+	oldSelfVarName         = "oldSelf"
+	requestVarName         = "request"
+	namespaceObjectVarName = "namespaceObject"
+	paramsVarName          = "params"
+)
+
+var (
+	admissionEnvOnce sync.Once
+	admissionEnv     *cel.Env
+	admissionEnvErr  error
+)
+
+// Environment used for rule sets which opt into the Admission environment.
+// Shared between all rule sets since the variables are not typed by the
+// schema of the object under validation.
+func getAdmissionEnv() (*cel.Env, error) {
+	admissionEnvOnce.Do(func() {
+		var opts []cel.EnvOption
+		opts = append(opts, cel.HomogeneousAggregateLiterals())
+		opts = append(opts, cel.EagerlyValidateDeclarations(true), cel.DefaultUTCTimeZone(true))
+		opts = append(opts, library.ExtensionLibs...)
+
+		baseEnv, err := cel.NewEnv(opts...)
+		if err != nil {
+			admissionEnvErr = err
+			return
+		}
+
+		requestType := plugincel.BuildRequestType()
+		rt, err := apiservercel.NewRuleTypes(requestType.TypeName(), requestType, apiservercel.NewRegistry(baseEnv))
+		if err != nil {
+			admissionEnvErr = err
+			return
+		}
+
+		typeOpts, err := rt.EnvOptions(baseEnv.TypeProvider())
+		if err != nil {
+			admissionEnvErr = err
+			return
+		}
+
+		typeOpts = append(typeOpts,
+			cel.Variable(selfVarName, cel.DynType),
+			cel.Variable(oldSelfVarName, cel.DynType),
+			cel.Variable(requestVarName, requestType.CelType()),
+			cel.Variable(namespaceObjectVarName, cel.DynType),
+			cel.Variable(paramsVarName, cel.DynType),
+		)
+		admissionEnv, admissionEnvErr = baseEnv.Extend(typeOpts...)
+	})
+	return admissionEnv, admissionEnvErr
+}
+
+type admissionProgram struct {
+	rule    polyfillv0.ValidationRule
+	program cel.Program
+
+	// True if the rule references oldSelf. Such rules are skipped if there
+	// is no old object, matching the behavior of CRD transition rules
+	isTransition bool
+
+	// Populated if the rule failed to compile
+	err error
+}
+
+func compileAdmissionRules(rules []polyfillv0.ValidationRule) []admissionProgram {
+	env, envErr := getAdmissionEnv()
+
+	var result []admissionProgram
+	for _, rule := range rules {
+		compiled := admissionProgram{rule: rule}
+		if envErr != nil {
+			compiled.err = fmt.Errorf("compiler initialization failed: %w", envErr)
+			result = append(result, compiled)
+			continue
+		}
+
+		ast, issues := env.Compile(rule.Rule)
+		if issues != nil && issues.Err() != nil {
+			compiled.err = fmt.Errorf("compilation failed: %v", issues.String())
+			result = append(result, compiled)
+			continue
+		}
+
+		if ast.OutputType() != cel.BoolType {
+			compiled.err = fmt.Errorf("must evaluate to %v", cel.BoolType)
+			result = append(result, compiled)
+			continue
+		}
+
+		checked, err := cel.AstToCheckedExpr(ast)
+		if err != nil {
+			compiled.err = fmt.Errorf("unexpected compilation error: %w", err)
+			result = append(result, compiled)
+			continue
+		}
+
+		for _, ref := range checked.ReferenceMap {
+			if ref.Name == oldSelfVarName {
+				compiled.isTransition = true
+				break
+			}
+		}
+
+		compiled.program, err = env.Program(ast,
+			cel.EvalOptions(cel.OptOptimize, cel.OptTrackCost),
+			cel.OptimizeRegex(library.ExtensionLibRegexOptimizations...),
+			cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+			cel.CostLimit(celconfig.PerCallLimit),
+		)
+		if err != nil {
+			compiled.err = fmt.Errorf("program instantiation failed: %w", err)
+		}
+		result = append(result, compiled)
+	}
+	return result
+}
+
+// Evaluates the rules of a rule set in the Admission environment. attrs may
+// be nil, in which case `request` and `namespaceObject` are null.
+func (v *ruleValidator) validateAdmission(
+	ctx context.Context,
+	entry ruleSetCacheEntry,
+	compiled compileRule,
+	attrs admission.Attributes,
+	obj, oldObj map[string]interface{},
+	celBudget int64,
+) (field.ErrorList, int64) {
+	var errs field.ErrorList
+
+	structural := celmodel.WithTypeAndObjectMeta(compiled.structural)
+	activation := map[string]interface{}{
+		selfVarName:            kcel.UnstructuredToVal(obj, structural),
+		oldSelfVarName:         nil,
+		requestVarName:         nil,
+		namespaceObjectVarName: nil,
+		paramsVarName:          nil,
+	}
+
+	if oldObj != nil {
+		activation[oldSelfVarName] = kcel.UnstructuredToVal(oldObj, structural)
+	}
+
+	if attrs != nil {
+		request, err := runtime.DefaultUnstructuredConverter.ToUnstructured(plugincel.CreateAdmissionRequest(attrs))
+		if err != nil {
+			return append(errs, field.InternalError(nil, err)), celBudget
+		}
+		activation[requestVarName] = request
+
+		namespaceObject, err := v.getNamespaceObject(attrs.GetNamespace())
+		if err != nil {
+			return append(errs, field.InternalError(nil, fmt.Errorf("failed to fetch namespace %q: %w", attrs.GetNamespace(), err))), celBudget
+		}
+		activation[namespaceObjectVarName] = namespaceObject
+	}
+
+	if ref := entry.source.Spec.ParamRef; ref != nil {
+		params, err := v.getParams(ctx, ref)
+		if err != nil {
+			return append(errs, field.InternalError(nil, fmt.Errorf("failed to resolve params %s %s/%s: %w", ref.Kind, ref.Namespace, ref.Name, err))), celBudget
+		}
+		activation[paramsVarName] = params
+	}
+
+	for _, program := range compiled.programs {
+		if program.err != nil {
+			errs = append(errs, field.Invalid(nil, compiled.structural.Type, fmt.Sprintf("rule %q: %v", program.rule.Name, program.err)))
+			continue
+		}
+
+		if program.isTransition && oldObj == nil {
+			continue
+		}
+
+		result, details, err := program.program.ContextEval(ctx, activation)
+		if details == nil || details.ActualCost() == nil {
+			errs = append(errs, field.Invalid(nil, compiled.structural.Type, fmt.Sprintf("runtime cost could not be calculated for rule %q, no further rules will be run", program.rule.Name)))
+			return errs, -1
+		}
+
+		rtCost := *details.ActualCost()
+		if rtCost > math.MaxInt64 || int64(rtCost) > celBudget {
+			errs = append(errs, field.Invalid(nil, compiled.structural.Type, "validation failed due to running out of cost budget, no further validation rules will be run"))
+			return errs, -1
+		}
+		celBudget -= int64(rtCost)
+
+		if err != nil {
+			errs = append(errs, field.Invalid(nil, compiled.structural.Type, fmt.Sprintf("rule %q resulted in error: %v", program.rule.Name, err)))
+		} else if result != types.True {
+			errs = append(errs, field.Invalid(nil, compiled.structural.Type, ruleMessageOrDefault(program.rule)))
+		}
+	}
+
+	return errs, celBudget
+}
+
+func (v *ruleValidator) getNamespaceObject(namespace string) (map[string]interface{}, error) {
+	if len(namespace) == 0 || v.namespaceLister == nil {
+		return nil, nil
+	}
+
+	ns, err := v.namespaceLister.Get(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(ns)
+}
+
+// Params are fetched from the apiserver rather than watched, and each is
+// reused for the requests validated within paramTTL
+const (
+	paramTTL       = 10 * time.Second
+	paramCacheSize = 100
+)
+
+type paramKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+// Params which were found, or the NotFound error of those which were not
+type paramResult struct {
+	params map[string]interface{}
+	err    error
+}
+
+func (v *ruleValidator) getParams(ctx context.Context, ref *polyfillv0.ParamRef) (map[string]interface{}, error) {
+	if v.restMapper == nil || v.dynamicClient == nil {
+		return nil, fmt.Errorf("params are not supported by this validator")
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key := paramKey{gvk: gv.WithKind(ref.Kind), namespace: ref.Namespace, name: ref.Name}
+	if cached, found := v.params.Get(key); found {
+		result := cached.(paramResult)
+		return result.params, result.err
+	}
+
+	mapping, err := v.restMapper.RESTMapping(key.gvk.GroupKind(), key.gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var params runtime.Object
+	if len(ref.Namespace) > 0 {
+		params, err = v.dynamicClient.Resource(mapping.Resource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	} else {
+		params, err = v.dynamicClient.Resource(mapping.Resource).Get(ctx, ref.Name, metav1.GetOptions{})
+	}
+
+	// Other errors, e.g. of the context of the request, are not cached so
+	// they do not fail the requests which follow
+	if k8serrors.IsNotFound(err) {
+		v.params.Add(key, paramResult{err: err}, paramTTL)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	result, err := runtime.DefaultUnstructuredConverter.ToUnstructured(params)
+	if err != nil {
+		return nil, err
+	}
+
+	v.params.Add(key, paramResult{params: result}, paramTTL)
+	return result, nil
+}
+
+func ruleMessageOrDefault(rule polyfillv0.ValidationRule) string {
+	if len(rule.Message) == 0 {
+		return fmt.Sprintf("failed rule: %s", rule.Rule)
+	}
+	return rule.Message
+}
+
+// Converts an object passed to the validator into its unstructured form.
+// Returns nil for nil objects, including typed nils.
+func toUnstructured(obj interface{}) (map[string]interface{}, error) {
+	if obj == nil || (reflect.ValueOf(obj).Kind() == reflect.Pointer && reflect.ValueOf(obj).IsNil()) {
+		return nil, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
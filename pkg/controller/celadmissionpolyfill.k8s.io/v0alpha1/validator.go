@@ -10,12 +10,15 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiserverschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
 	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/admission"
 	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/client-go/dynamic"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
 )
 
@@ -39,6 +42,13 @@ type RuleSetValidator interface {
 type ruleValidator struct {
 	structuralSchemaController structuralschema.Controller
 
+	// Used to populate `namespaceObject` and `params` for rule sets in the
+	// Admission environment. May be nil if no such rule sets are in use
+	namespaceLister corev1listers.NamespaceLister
+	restMapper      meta.RESTMapper
+	dynamicClient   dynamic.Interface
+	params          *utilcache.LRUExpireCache
+
 	//!TODO: refactor Validate and change to RWMutex
 	lock               sync.Mutex
 	registeredRuleSets map[string]ruleSetCacheEntry
@@ -47,6 +57,9 @@ type ruleValidator struct {
 type compileRule struct {
 	validator  *cel.Validator
 	structural *apiserverschema.Structural
+
+	// Used instead of validator for rule sets in the Admission environment
+	programs []admissionProgram
 }
 
 type ruleSetCacheEntry struct {
@@ -100,10 +113,17 @@ func (r ruleSetCacheEntry) Matches(gvr metav1.GroupVersionResource) bool {
 
 func NewValidator(
 	structuralSchemaController structuralschema.Controller,
+	namespaceLister corev1listers.NamespaceLister,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
 ) RuleSetValidator {
 	return &ruleValidator{
 		registeredRuleSets:         make(map[string]ruleSetCacheEntry),
 		structuralSchemaController: structuralSchemaController,
+		namespaceLister:            namespaceLister,
+		restMapper:                 restMapper,
+		dynamicClient:              dynamicClient,
+		params:                     utilcache.NewLRUExpireCache(paramCacheSize),
 	}
 }
 
@@ -112,10 +132,14 @@ func (v *ruleValidator) Handles(operation admission.Operation) bool {
 }
 
 func (v *ruleValidator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
-	return v.Validate2(a.GetResource(), a.GetObject(), a.GetOldObject())
+	return v.validate(ctx, a.GetResource(), a.GetOldObject(), a.GetObject(), a)
 }
 
 func (v *ruleValidator) Validate2(gvr schema.GroupVersionResource, oldObj, obj interface{}) error {
+	return v.validate(context.TODO(), gvr, oldObj, obj, nil)
+}
+
+func (v *ruleValidator) validate(ctx context.Context, gvr schema.GroupVersionResource, oldObj, obj interface{}, attrs admission.Attributes) error {
 	// 1. Find rules which match against this object
 	// 2. Find compiled CEL rules for this object's type. If not yet
 	//	seen, compile for this type and save.
@@ -173,39 +197,57 @@ func (v *ruleValidator) Validate2(gvr schema.GroupVersionResource, oldObj, obj i
 					return &newS
 				}
 
-				var xvalidations apiextensionsv1.ValidationRules
-				for _, rule := range entry.source.Spec.Rules {
-					xvalidations = append(xvalidations, apiextensionsv1.ValidationRule{
-						Rule:    rule.Rule,
-						Message: rule.Message,
-					})
-				}
-				copied := wipeOutXValidations(structural)
-
-				// Imbue our validations unto the schema
-				//!TODO: allow different locations that choose field paths to
-				// apply stuff to?
-				copied.XValidations = xvalidations
-				compiled = compileRule{
-					validator:  cel.NewValidator(copied, true, celconfig.PerCallLimit),
-					structural: copied,
+				if entry.source.Spec.Environment == polyfillv0.AdmissionEnvironment {
+					compiled = compileRule{
+						structural: structural,
+						programs:   compileAdmissionRules(entry.source.Spec.Rules),
+					}
+					entry.compiledRules[metav1.GroupVersionResource(gvr)] = compiled
+				} else {
+					var xvalidations apiextensionsv1.ValidationRules
+					for _, rule := range entry.source.Spec.Rules {
+						xvalidations = append(xvalidations, apiextensionsv1.ValidationRule{
+							Rule:    rule.Rule,
+							Message: rule.Message,
+						})
+					}
+					copied := wipeOutXValidations(structural)
+
+					// Imbue our validations unto the schema
+					//!TODO: allow different locations that choose field paths to
+					// apply stuff to?
+					copied.XValidations = xvalidations
+					compiled = compileRule{
+						validator:  cel.NewValidator(copied, true, celconfig.PerCallLimit),
+						structural: copied,
+					}
+					entry.compiledRules[metav1.GroupVersionResource(gvr)] = compiled
 				}
-				entry.compiledRules[metav1.GroupVersionResource(gvr)] = compiled
 			}
 
 			var errorList field.ErrorList
 
-			o, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
-			old, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(oldObj)
-
-			errorList, celBudget = compiled.validator.Validate(
-				context.TODO(),
-				nil,
-				compiled.structural,
-				o,
-				old,
-				celBudget,
-			)
+			o, err := toUnstructured(obj)
+			if err != nil {
+				return err
+			}
+			old, err := toUnstructured(oldObj)
+			if err != nil {
+				return err
+			}
+
+			if compiled.validator == nil {
+				errorList, celBudget = v.validateAdmission(ctx, entry, compiled, attrs, o, old, celBudget)
+			} else {
+				errorList, celBudget = compiled.validator.Validate(
+					ctx,
+					nil,
+					compiled.structural,
+					o,
+					old,
+					celBudget,
+				)
+			}
 
 			if len(errorList) > 0 {
 				failures = append(failures, errorList...)
@@ -5,36 +5,43 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
 	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
 	"github.com/google/cel-policy-templates-go/policy"
+	"github.com/google/cel-policy-templates-go/policy/limits"
 	"github.com/google/cel-policy-templates-go/policy/model"
+	policyruntime "github.com/google/cel-policy-templates-go/policy/runtime"
 
 	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
 	"github.com/alexzielenski/cel_polyfill/pkg/controller"
 	"github.com/alexzielenski/cel_polyfill/pkg/controller/structuralschema"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	structuralschemas "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
 	kcel "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel"
 	celmodel "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel/model"
 	"k8s.io/apiserver/pkg/admission"
-	"k8s.io/apiserver/pkg/cel/library"
+	"k8s.io/apiserver/pkg/warning"
 	"k8s.io/klog/v2"
 
 	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	runtimeschema "k8s.io/apimachinery/pkg/runtime/schema"
 	patchtypes "k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -48,26 +55,13 @@ type PolicyTemplateController interface {
 
 func NewPolicyTemplateController(
 	dynamicClient dynamic.Interface,
+	client versioned.Interface,
 	policyTemplatesInformer cache.SharedIndexInformer,
 	structuralSchemaController structuralschema.Controller,
 	crdClient apiextensionsclientset.Interface,
+	namespaceLister corev1listers.NamespaceLister,
 ) PolicyTemplateController {
-	// Resource type is determined at runtime rather than compile time
-	var opts []cel.EnvOption
-	opts = append(opts, cel.Variable("resource", cel.DynType))
-
-	// cel.HomogeneousAggregateLiterals() makes `output.details.data` type
-	// validation fail since sibling `output.message` key is a string (and details is a map)
-	// opts = append(opts, cel.HomogeneousAggregateLiterals())
-	opts = append(opts, library.ExtensionLibs...)
-
-	env, err := cel.NewEnv(opts...)
-	if err != nil {
-		// why can this return a nerr
-		panic(err)
-	}
-
-	engine, err := policy.NewEngine(policy.StandardExprEnv(env))
+	engine, err := newPolicyEngine("", nil)
 	if err != nil {
 		// why can this return a nerr
 		panic(err)
@@ -77,7 +71,9 @@ func NewPolicyTemplateController(
 		structuralSchemaController: structuralSchemaController,
 		crdClient:                  crdClient,
 		dynamicClient:              dynamicClient,
-		policyEngine:               engine,
+		client:                     client,
+		namespaceLister:            namespaceLister,
+		policyEngines:              map[string]*policy.Engine{"": engine},
 		templates:                  make(map[string]templateInfo),
 	}
 	result.policyTemplatesController = controller.New(
@@ -93,13 +89,17 @@ type templateController struct {
 	structuralSchemaController structuralschema.Controller
 	crdClient                  apiextensionsclientset.Interface
 	dynamicClient              dynamic.Interface
+	client                     versioned.Interface
+	namespaceLister            corev1listers.NamespaceLister
 
 	lock sync.RWMutex
 
 	// map of policy template name
 	templates map[string]templateInfo
 
-	policyEngine *policy.Engine
+	// Policy engines by the evaluator environment of the templates they
+	// compile
+	policyEngines map[string]*policy.Engine
 
 	runningContext context.Context
 }
@@ -117,15 +117,33 @@ func (t *templateController) GetNumberInstances(templateName string) int {
 
 type templateInfo struct {
 	template  *v0alpha2.PolicyTemplate
+	compiled  *model.Template
+	runtime   *policyruntime.Template
 	instances map[string]instanceInfo
 
+	// Engine the template was compiled by, which compiles its instances
+	engine *policy.Engine
+
+	// Name of the CRD generated for instances of this template
+	crdName string
+
+	// Resource of the instances of this template
+	instanceGVR schema.GroupVersionResource
+
 	// Stops this template watching for instances
 	cancelFunc func()
 }
 
 type instanceInfo struct {
-	raw      string
+	raw    string
+	object *unstructured.Unstructured
+
+	// nil if the instance failed to compile, in which case err is set
 	compiled *model.Instance
+	err      error
+
+	// Restricts which objects the instance applies to
+	matcher *matcher
 }
 
 func (c *templateController) Run(ctx context.Context) error {
@@ -146,98 +164,305 @@ func (c *templateController) reconcilePolicyTemplate(
 	template *v0alpha2.PolicyTemplate,
 ) error {
 	if template == nil {
-		// Delete CRD with same name owned by this guy
-		// k8s garbage collection via owner references might work
-
-		//!TODO: Remove this template from the policy engine as well
-		// as any of its instances
-		panic("deletion not implemented")
+		return c.removePolicyTemplate(name)
 	}
 
-	// Regenerate the CRD
-	// 1. Each policy template in turn owns a CRD
-	crdGVR := metav1.GroupVersionResource{
-		//!TODO: decide policy for which group to use
-		//	example: OPA gatekeeper uses specialized constraints group for all
-		//		constraints
-		Group:    "policy.acme.co",
-		Version:  "v1",
-		Resource: template.Name,
-	}
+	// Informer cache must not be mutated
+	template = template.DeepCopy()
 
 	// Sometimes these are empty???
 	template.APIVersion = "celadmissionpolyfill.k8s.io/v0alpha2"
 	template.Kind = "PolicyTemplate"
 
-	crd := apiextensionsv1.CustomResourceDefinition{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "apiextensions.k8s.io/v1",
-			Kind:       "CustomResourceDefinition",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			//TODO: too naive. Need enforcement from apiserver of uniqueness
-			// constraint that follows from CRD names through to policy template names
-			Name: template.Name + "." + crdGVR.Group,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: template.APIVersion,
-					Kind:       template.Kind,
-					Name:       name,
-					UID:        template.GetUID(),
-				},
-			},
+	// Regenerate the CRD
+	// 1. Each policy template in turn owns a CRD
+	names := instanceNamesForTemplate(template)
+	if errs := validateInstanceNames(names); len(errs) > 0 {
+		return c.setTemplateCondition(template, v0alpha2.PolicyTemplateCRDCreated, metav1.ConditionFalse, "InvalidNames", errs.ToAggregate().Error())
+	}
+
+	crd := crdForTemplate(template, names)
+
+	// 2. Refuse to take over CRDs which belong to something else
+	conflict, err := c.checkCRDConflict(template, crd.Name)
+	if err != nil {
+		return err
+	} else if conflict != nil {
+		return c.setTemplateCondition(template, v0alpha2.PolicyTemplateCRDCreated, metav1.ConditionFalse, "Conflict", conflict.Error())
+	}
+
+	// 3. If the names of the CRD changed, the old one is stale
+	c.lock.RLock()
+	info, exists := c.templates[template.Name]
+	c.lock.RUnlock()
+	if exists && info.crdName != crd.Name {
+		if err := c.removePolicyTemplate(template.Name); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}
+
+	err = c.applyCRD(crd)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return c.setTemplateCondition(template, v0alpha2.PolicyTemplateCRDCreated, metav1.ConditionFalse, "ApplyFailed", err.Error())
+	}
+
+	if err := c.setTemplateCondition(template, v0alpha2.PolicyTemplateCRDCreated, metav1.ConditionTrue, "Created", ""); err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	klog.Infof("created crd for policy: %s", crd.Name)
+
+	// Compile template and throw it into the env. If it fails to compile,
+	// the last version which did compile remains enforced
+	gvr := schema.GroupVersionResource{
+		Group:    names.group,
+		Version:  instanceVersion,
+		Resource: names.plural,
+	}
+
+	recompiled, err := c.compilePolicyTemplate(template, crd.Name, gvr)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return c.setTemplateCondition(template, v0alpha2.PolicyTemplateCompiled, metav1.ConditionFalse, "CompilationFailed", err.Error())
+	}
+
+	if err := c.setTemplateCondition(template, v0alpha2.PolicyTemplateCompiled, metav1.ConditionTrue, "Compiled", ""); err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	// Instances compiled against the previous version of the template may
+	// have changed state
+	for key, instance := range recompiled {
+		if err := c.updateInstanceStatus(gvr, instance.object.GetNamespace(), instance.object.GetName(), instanceStatus(instance, true)); err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to update status of instance %s of %s: %w", key, template.Name, err))
+		}
+	}
+
+	return nil
+}
+
+// Compiles the template and registers it with the policy engine, starting a
+// watch for its instances if there is not one already. If the spec of a
+// template which is already tracked changed, its instances are recompiled
+// and returned.
+func (c *templateController) compilePolicyTemplate(
+	template *v0alpha2.PolicyTemplate,
+	crdName string,
+	gvr schema.GroupVersionResource,
+) (map[string]instanceInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	existing, exists := c.templates[template.Name]
+	if exists && existing.compiled != nil && reflect.DeepEqual(existing.template.PolicyTemplateSpec, template.PolicyTemplateSpec) {
+		existing.template = template
+		c.templates[template.Name] = existing
+		return nil, nil
+	}
+
+	source, _, err := v0alpha2.PolicyTemplateToCELPolicyTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := c.engineFor(template.Evaluator.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	compiledTemplate, issues := engine.CompileTemplate(source)
+	if issues != nil {
+		return nil, issues.Err()
+	}
+
+	templateRuntime, err := c.setTemplate(engine, template.Name, compiledTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure we have an instance watcher for this CRD
+	if exists {
+		existing.template = template
+		existing.compiled = compiledTemplate
+		existing.runtime = templateRuntime
+		existing.engine = engine
+
+		for key, instance := range existing.instances {
+			existing.instances[key] = c.compileInstance(engine, template.Name, instance.object)
+		}
+
+		c.templates[template.Name] = existing
+		return existing.instances, nil
+	}
+
+	instanceContext, instanceCancel := context.WithCancel(c.runningContext)
+	c.templates[template.Name] = templateInfo{
+		cancelFunc:  instanceCancel,
+		instances:   make(map[string]instanceInfo),
+		template:    template,
+		compiled:    compiledTemplate,
+		runtime:     templateRuntime,
+		engine:      engine,
+		crdName:     crdName,
+		instanceGVR: gvr,
+	}
+
+	// Watch for new instances of this policy
+	informer := dynamicinformer.NewFilteredDynamicInformer(c.dynamicClient, gvr, corev1.NamespaceAll, 30*time.Second, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+
+	controller := controller.New(
+		controller.NewInformer[*unstructured.Unstructured](informer.Informer()),
+		func(namespace, name string, newObj *unstructured.Unstructured) error {
+			return c.reconcileInstance(template.Name, namespace, name, newObj)
 		},
-		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
-			Group: crdGVR.Group,
-			Names: apiextensionsv1.CustomResourceDefinitionNames{
-				//!TOOD: Validation that requires template name to be lowercase
-				// required for uniqueness (since all names are lowercase)
-				Plural:     template.Name,
-				Singular:   template.Name,
-				ShortNames: []string{},
-				Kind:       template.Name,
-				ListKind:   template.Name + "List",
-				Categories: []string{"policy"},
-			},
-			Scope:                 apiextensionsv1.ClusterScoped,
-			PreserveUnknownFields: false,
-			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
-				{
-					Name:                     crdGVR.Version,
-					Served:                   true,
-					Storage:                  true,
-					Deprecated:               false,
-					Subresources:             nil,
-					AdditionalPrinterColumns: nil,
-
-					Schema: &apiextensionsv1.CustomResourceValidation{
-						OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
-							Type:     "object",
-							Required: []string{"apiVersion", "kind", "metadata"},
-							Properties: map[string]apiextensionsv1.JSONSchemaProps{
-								"apiVersion":  {Type: "string"},
-								"kind":        {Type: "string"},
-								"metadata":    {Type: "object"},
-								"description": {Type: "string"},
-								"selector":    {Type: "object", Properties: map[string]apiextensionsv1.JSONSchemaProps{}},
-								"rule":        template.Schema,
-								"rules":       {Type: "array", Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &template.Schema}},
-							},
-						},
-					},
-				},
-			},
+		controller.ControllerOptions{
+			Name: fmt.Sprintf("%s.%s-instance-controller", template.GroupVersionKind().Version, template.Name),
 		},
+	)
+	go informer.Informer().Run(instanceContext.Done())
+	go controller.Run(instanceContext)
+
+	return nil, nil
+}
+
+// Keeps the instances of a template enforced by the policy engine in sync
+// with the instances in the cluster, and reports whether they compiled on
+// their status
+func (c *templateController) reconcileInstance(
+	templateName string,
+	namespace, name string,
+	instance *unstructured.Unstructured,
+) error {
+	c.lock.Lock()
+
+	info, exists := c.templates[templateName]
+	if !exists {
+		// Template was removed. Its instances are no longer enforced
+		c.lock.Unlock()
+		return nil
+	}
+
+	key := name
+	if len(namespace) > 0 {
+		key = namespace + "/" + name
 	}
 
+	var compiled instanceInfo
+	if instance == nil {
+		// Instance was removed
+		delete(info.instances, key)
+	} else {
+		// Instance was added/updated
+		compiled = c.compileInstance(info.engine, templateName, instance)
+		if existing, hasExisting := info.instances[key]; hasExisting && existing.raw == compiled.raw {
+			c.lock.Unlock()
+			return nil
+		}
+
+		// Instances which fail to compile are kept so they are counted, but
+		// are not enforced
+		info.instances[key] = compiled
+	}
+
+	instanceCount := int32(len(info.instances))
+	c.lock.Unlock()
+
+	if instance != nil {
+		if compiled.err != nil {
+			utilruntime.HandleError(fmt.Errorf("instance %s of %s: %w", key, templateName, compiled.err))
+		}
+
+		if err := c.updateInstanceStatus(info.instanceGVR, namespace, name, instanceStatus(compiled, info.runtime != nil)); err != nil {
+			return err
+		}
+	}
+
+	return c.updateTemplateStatus(info.template.Namespace, templateName, func(s *v0alpha2.PolicyTemplateStatus) {
+		s.InstanceCount = instanceCount
+	})
+}
+
+// Compiles an instance against the template last registered with the
+// engine. Errors are recorded on the returned instanceInfo.
+//
+// Must be called with lock held for reading
+func (c *templateController) compileInstance(engine *policy.Engine, templateName string, object *unstructured.Unstructured) instanceInfo {
+	// The policy engine looks up the template by the kind of the instance,
+	// which may differ from the template name
+	instance := object.DeepCopy()
+	instance.SetKind(templateName)
+
+	// Changes to these do not affect what is enforced. Status is written
+	// by this controller
+	unstructured.RemoveNestedField(instance.Object, "status")
+	unstructured.RemoveNestedField(instance.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(instance.Object, "metadata", "managedFields")
+
+	result := instanceInfo{object: object}
+
+	yamled, err := json.MarshalIndent(instance, "", "    ")
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.raw = string(yamled)
+
+	result.matcher, err = newMatcher(instance)
+	if err != nil {
+		result.err = field.Invalid(field.NewPath(matchField), field.OmitValueType{}, err.Error())
+		return result
+	}
+
+	// Policy template compiler rejects fields it does not know about.
+	// Indented so issues can be traced back to the field they refer to
+	unstructured.RemoveNestedField(instance.Object, matchField)
+	compilable, err := json.MarshalIndent(instance, "", "    ")
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	instanceSource := model.ByteSource(compilable, "")
+	compiled, issues := engine.CompileInstance(instanceSource)
+	if issues != nil {
+		result.err = instanceCompileError{source: compilable, issues: issues}
+		return result
+	}
+
+	result.compiled = compiled
+	return result
+}
+
+// Returns a non-nil conflict if the CRD with the given name is claimed by
+// another template or was not generated by this controller
+func (c *templateController) checkCRDConflict(template *v0alpha2.PolicyTemplate, crdName string) (conflict error, err error) {
+	c.lock.RLock()
+	for name, info := range c.templates {
+		if name != template.Name && info.crdName == crdName {
+			c.lock.RUnlock()
+			return fmt.Errorf("crd %s is already generated by policy template %s", crdName, name), nil
+		}
+	}
+	c.lock.RUnlock()
+
+	existing, err := c.crdClient.ApiextensionsV1().CustomResourceDefinitions().Get(c.runningContext, crdName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return checkCRDOwnership(existing, template), nil
+}
+
+// Patches the CRD into the cluster, creating it if it does not yet exist
+func (c *templateController) applyCRD(crd *apiextensionsv1.CustomResourceDefinition) error {
 	crdJSON, err := json.Marshal(crd)
 	if err != nil {
-		utilruntime.HandleError(err)
-		return nil
+		return err
 	}
 
-	//!TODO: dont use create if object exists. Unfortunately Patch can't
-	// be used to create object if it doesnt already exist?
 	_, err = c.crdClient.ApiextensionsV1().CustomResourceDefinitions().Patch(
 		c.runningContext,
 		crd.Name,
@@ -248,110 +473,158 @@ func (c *templateController) reconcilePolicyTemplate(
 		},
 	)
 
-	// _, err = c.crdClient.
-	// 	ApiextensionsV1().
-	// 	CustomResourceDefinitions().Create(context.TODO(), &crd, metav1.CreateOptions{
-	// 	FieldManager: "template-controller",
-	// })
+	// Not all clients support creating objects via apply
+	if errors.IsNotFound(err) {
+		_, err = c.crdClient.ApiextensionsV1().CustomResourceDefinitions().Create(
+			c.runningContext,
+			crd,
+			metav1.CreateOptions{
+				FieldManager: "cel-polyfill-controller",
+			},
+		)
+	}
 
-	if err != nil {
-		utilruntime.HandleError(err)
+	return err
+}
+
+// Stops enforcing the template and its instances, and cleans up the CRD that
+// was generated for it
+func (c *templateController) removePolicyTemplate(name string) error {
+	info, exists := c.forgetPolicyTemplate(name)
+	if !exists {
 		return nil
 	}
 
-	klog.Infof("created crd for policy: %s", crd.Name)
-
-	// Compile template and throw it into the env
-	source, _, err := v0alpha2.PolicyTemplateToCELPolicyTemplate(template)
-	if err != nil {
-		utilruntime.HandleError(err)
+	// The owner reference on the CRD can't be relied upon for cleanup since
+	// cluster-scoped objects may not be owned by namespaced objects.
+	crd, err := c.crdClient.ApiextensionsV1().CustomResourceDefinitions().Get(c.runningContext, info.crdName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
 		return nil
+	} else if err != nil {
+		return err
 	}
-	compiledTemplate, issues := c.policyEngine.CompileTemplate(source)
-	if issues != nil {
-		utilruntime.HandleError(issues.Err())
+
+	owned := false
+	for _, ref := range crd.OwnerReferences {
+		if ref.UID == info.template.UID {
+			owned = true
+			break
+		}
+	}
+
+	if !owned {
+		klog.Infof("not deleting crd %s: not owned by policy template %s", info.crdName, name)
 		return nil
 	}
 
+	err = c.crdClient.ApiextensionsV1().CustomResourceDefinitions().Delete(c.runningContext, info.crdName, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &crd.UID},
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	klog.Infof("deleted crd for policy: %s", info.crdName)
+	return nil
+}
+
+// Stops enforcing the template and returns what was tracked for it, so its
+// CRD can be cleaned up without holding the lock
+func (c *templateController) forgetPolicyTemplate(name string) (templateInfo, bool) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	err = c.policyEngine.SetTemplate(template.Name, compiledTemplate)
-	if err != nil {
+	info, exists := c.templates[name]
+	if !exists {
+		return info, false
+	}
+
+	// Stop watching for instances of this template
+	info.cancelFunc()
+	delete(c.templates, name)
+
+	if err := c.rebuildEngine(); err != nil {
 		utilruntime.HandleError(err)
-		return nil
 	}
+	return info, true
+}
 
-	// Make sure we have an instance watcher for this CRD
-	if _, exists := c.templates[template.Name]; !exists {
-		instanceContext, instanceCancel := context.WithCancel(c.runningContext)
-		c.templates[template.Name] = templateInfo{
-			cancelFunc: instanceCancel,
-			instances:  make(map[string]instanceInfo),
-			template:   template,
-		}
+// Returns the policy engine for templates of the evaluator environment,
+// creating it if there is none yet. Environments naming a resource are typed
+// by the structural schema of the resource when the engine is created.
+//
+// Must be called with lock held
+func (c *templateController) engineFor(environment string) (*policy.Engine, error) {
+	if engine, exists := c.policyEngines[environment]; exists {
+		return engine, nil
+	}
 
-		// Watch for new instances of this policy
-		informer := dynamicinformer.NewFilteredDynamicInformer(c.dynamicClient, runtimeschema.GroupVersionResource{
-			Group:    crdGVR.Group,
-			Version:  crdGVR.Version,
-			Resource: crdGVR.Resource,
-		}, corev1.NamespaceAll, 30*time.Second, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
-
-		controller := controller.New(
-			controller.NewInformer[*unstructured.Unstructured](informer.Informer()),
-			func(namepace, name string, newObj *unstructured.Unstructured) error {
-				c.lock.Lock()
-				defer c.lock.Unlock()
-
-				if newObj == nil {
-					// Instance was removed
-					panic("deletion not implemented")
-				} else {
-					// Instance was added/updated
-					if templateInfo, exists := c.templates[template.Name]; exists {
-						yamled, err := json.MarshalIndent(newObj, "", "    ")
-						if err != nil {
-							// hmm what to do in this case?
-							utilruntime.HandleError(err)
-							return err
-						}
-
-						instanceSource := model.ByteSource(yamled, "")
-						compiled, issues := c.policyEngine.CompileInstance(instanceSource)
-						if issues != nil {
-							utilruntime.HandleError(issues.Err())
-							return nil
-						}
-						err = c.policyEngine.AddInstance(compiled)
-						if err != nil {
-							utilruntime.HandleError(err)
-							return err
-						}
-						templateInfo.instances[name] = instanceInfo{
-							compiled: nil,
-							raw:      string(yamled),
-						}
-					}
-				}
+	gvr := environmentResource(environment)
+	if gvr == nil {
+		// The template compiler reports the environment as missing
+		return c.policyEngines[""], nil
+	}
 
-				println(name + " reconcile")
+	objectSchema, err := c.objectSchema(*gvr)
+	if err != nil {
+		return nil, fmt.Errorf("environment %s: %w", environment, err)
+	}
 
-				return nil
-			},
-			controller.ControllerOptions{
-				Name: fmt.Sprintf("%s.%s-instance-controller", template.GroupVersionKind().Version, template.Name),
-			},
-		)
-		go informer.Informer().Run(instanceContext.Done())
-		go controller.Run(instanceContext)
+	engine, err := newPolicyEngine(environment, objectSchema)
+	if err != nil {
+		return nil, err
 	}
 
-	return err
+	c.policyEngines[environment] = engine
+	return engine, nil
 }
 
-type DecisionError struct {
-	Decisions []model.DecisionValue
+// Registers the template with the policy engine and creates the runtime
+// used to evaluate its instances.
+//
+// Must be called with lock held
+func (c *templateController) setTemplate(engine *policy.Engine, name string, compiled *model.Template) (*policyruntime.Template, error) {
+	if err := engine.SetTemplate(name, compiled); err != nil {
+		return nil, err
+	}
+
+	return policyruntime.NewTemplate(engine.Registry, compiled, policyruntime.Limits(limits.NewLimits()))
+}
+
+// policy.Engine has no way to remove templates, so fresh engines are
+// populated from the compiled templates which are still tracked.
+//
+// Must be called with lock held
+func (c *templateController) rebuildEngine() error {
+	engine, err := newPolicyEngine("", nil)
+	if err != nil {
+		return err
+	}
+
+	previous := c.policyEngines
+	c.policyEngines = map[string]*policy.Engine{"": engine}
+
+	rebuilt := make(map[string]templateInfo, len(c.templates))
+	for name, info := range c.templates {
+		if info.compiled == nil {
+			continue
+		}
+
+		info.engine, err = c.engineFor(info.template.Evaluator.Environment)
+		if err == nil {
+			info.runtime, err = c.setTemplate(info.engine, name, info.compiled)
+		}
+		if err != nil {
+			c.policyEngines = previous
+			return err
+		}
+		rebuilt[name] = info
+	}
+
+	for name, info := range rebuilt {
+		c.templates[name] = info
+	}
+	return nil
 }
 
 func ValToMap(val interface{}) interface{} {
@@ -404,41 +677,55 @@ func ValToMap(val interface{}) interface{} {
 	}
 }
 
-func (de DecisionError) ErrorJSON() []interface{} {
-	vs := []interface{}{}
-	for _, d := range de.Decisions {
-		if l, ok := d.(*model.ListDecisionValue); ok {
-			vals := l.Values()
-			for _, v := range vals {
-				vs = append(vs, ValToMap(v))
-			}
-		}
+func (c *templateController) Handles(operation admission.Operation) bool {
+	return true
+}
+
+func (c *templateController) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if err := c.validateInstance(a); err != nil {
+		return err
 	}
 
-	return vs
+	return c.validate(ctx, a.GetResource(), a.GetOldObject(), a.GetObject(), a)
 }
 
-func (de DecisionError) Error() string {
-	vs := de.ErrorJSON()
-	js, err := json.MarshalIndent(vs, "", "    ")
+func (c *templateController) Validate2(gvr schema.GroupVersionResource, oldObject interface{}, object interface{}) error {
+	return c.validate(context.TODO(), gvr, oldObject, object, nil)
+}
+
+// attrs may be nil, in which case `request` and `namespaceObject` are null.
+// Warnings are added to ctx
+func (c *templateController) validate(ctx context.Context, gvr schema.GroupVersionResource, oldObject interface{}, object interface{}, attrs admission.Attributes) error {
+	obj, err := toUnstructured(object)
 	if err != nil {
-		return fmt.Sprint(vs)
+		utilruntime.HandleError(err)
+		return err
 	}
-	return string(js)
-}
 
-var _ error = DecisionError{}
+	oldObj, err := toUnstructured(oldObject)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return err
+	}
 
-func (c *templateController) Handles(operation admission.Operation) bool {
-	return true
-}
+	// Deletions are matched against the object being removed
+	matchObj := &unstructured.Unstructured{Object: obj}
+	if obj == nil {
+		matchObj.Object = oldObj
+	}
 
-func (c *templateController) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
-	return c.Validate2(a.GetResource(), a.GetObject(), a.GetOldObject())
-}
+	if matchObj.Object == nil {
+		return nil
+	}
 
-func (c *templateController) Validate2(gvr schema.GroupVersionResource, object interface{}, oldObject interface{}) error {
-	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	// Typed objects do not carry their kind, so it is taken from the request
+	// when there is one
+	gvk := matchObj.GroupVersionKind()
+	if attrs != nil {
+		gvk = attrs.GetKind()
+	}
+
+	request, namespaceObject, err := admissionVars(attrs, c.namespaceLister)
 	if err != nil {
 		utilruntime.HandleError(err)
 		return err
@@ -448,12 +735,126 @@ func (c *templateController) Validate2(gvr schema.GroupVersionResource, object i
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	structural, err := c.structuralSchemaController.Get(metav1.GroupVersionResource(gvr))
-	if err != nil {
+	// Created for the first instance which matches, so requests no template
+	// applies to do not need the schema of their resource
+	var activation interpreter.Activation
+	newActivation := func() (interpreter.Activation, error) {
+		// Objects of resources without a structural schema, e.g. builtin
+		// kinds, are evaluated untyped
+		structural, err := c.objectSchema(gvr)
+		if err != nil {
+			klog.V(4).Infof("evaluating %s untyped: %v", gvr, err)
+			structural = nil
+		}
+
+		vars := map[string]interface{}{
+			objectVarName:          nil,
+			oldObjectVarName:       nil,
+			requestVarName:         request,
+			namespaceObjectVarName: namespaceObject,
+			resourceVarName:        nil,
+		}
+
+		if obj != nil {
+			vars[objectVarName] = objectToVal(obj, structural)
+			vars[resourceVarName] = vars[objectVarName]
+		}
+
+		if oldObj != nil {
+			vars[oldObjectVarName] = objectToVal(oldObj, structural)
+		}
+
+		return interpreter.NewActivation(vars)
+	}
+
+	var decisions []model.DecisionValue
+	var violations []Violation
+
+	// Sorted so decisions are reported in a stable order
+	templateNames := make([]string, 0, len(c.templates))
+	for name := range c.templates {
+		templateNames = append(templateNames, name)
+	}
+	sort.Strings(templateNames)
+
+	for _, templateName := range templateNames {
+		info := c.templates[templateName]
+		if info.runtime == nil {
+			continue
+		}
+
+		// Templates typed for another resource cannot evaluate its objects
+		if environment := environmentResource(info.template.Evaluator.Environment); environment != nil && *environment != gvr {
+			continue
+		}
+
+		instanceKeys := make([]string, 0, len(info.instances))
+		for key := range info.instances {
+			instanceKeys = append(instanceKeys, key)
+		}
+		sort.Strings(instanceKeys)
+
+		for _, key := range instanceKeys {
+			instance := info.instances[key]
+			if instance.compiled == nil {
+				continue
+			}
+
+			matches, err := instance.matcher.matches(gvk, matchObj, c.namespaceLister)
+			if err != nil {
+				err = fmt.Errorf("failed to match instance %s of %s: %w", key, templateName, err)
+				utilruntime.HandleError(err)
+				return err
+			} else if !matches {
+				continue
+			}
+
+			if activation == nil {
+				activation, err = newActivation()
+				if err != nil {
+					utilruntime.HandleError(err)
+					return err
+				}
+			}
+
+			instanceDecisions, err := info.runtime.Eval(instance.compiled, activation, nil)
+			if err != nil {
+				utilruntime.HandleError(err)
+				return err
+			}
+
+			deny, warn := partitionDecisions(instanceDecisions)
+			for _, v := range violationsForDecisions(templateName, key, warn) {
+				warning.AddWarning(ctx, "", v.String())
+			}
+
+			decisions = append(decisions, deny...)
+			violations = append(violations, violationsForDecisions(templateName, key, deny)...)
+		}
+	}
+
+	if len(decisions) > 0 {
+		err := DecisionError{
+			Decisions:  decisions,
+			Violations: violations,
+			Kind:       gvk.GroupKind(),
+			Name:       matchObj.GetName(),
+		}
 		utilruntime.HandleError(err)
 		return err
 	}
 
+	return nil
+}
+
+// Returns the structural schema of objects of the resource, including their
+// type and object metadata
+func (c *templateController) objectSchema(gvr schema.GroupVersionResource) (*structuralschemas.Structural, error) {
+	structural, err := c.structuralSchemaController.Get(metav1.GroupVersionResource(gvr))
+	if err != nil {
+		return nil, err
+	}
+
 	structural = celmodel.WithTypeAndObjectMeta(structural)
 
 	// 	// WithTypeAndObjectMeta does not include labels
@@ -467,20 +868,23 @@ func (c *templateController) Validate2(gvr schema.GroupVersionResource, object i
 				},
 			},
 		}
+	return structural, nil
+}
 
-	decisions, err := c.policyEngine.EvalAll(map[string]interface{}{
-		"resource": kcel.UnstructuredToVal(obj, structural),
-	})
-	if err != nil {
-		utilruntime.HandleError(err)
-		return err
+// Converts an unstructured object into a CEL value typed by structural, or
+// into an untyped value if structural is nil
+func objectToVal(obj map[string]interface{}, structural *structuralschemas.Structural) ref.Val {
+	if structural == nil {
+		return types.DefaultTypeAdapter.NativeToValue(obj)
 	}
+	return kcel.UnstructuredToVal(obj, structural)
+}
 
-	if len(decisions) > 0 {
-		err := DecisionError{Decisions: decisions}
-		utilruntime.HandleError(err)
-		return err
+// Converts an object passed to the validator into its unstructured form.
+// Returns nil for nil objects, including typed nils.
+func toUnstructured(obj interface{}) (map[string]interface{}, error) {
+	if obj == nil || (reflect.ValueOf(obj).Kind() == reflect.Pointer && reflect.ValueOf(obj).IsNil()) {
+		return nil, nil
 	}
-
-	return nil
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 }
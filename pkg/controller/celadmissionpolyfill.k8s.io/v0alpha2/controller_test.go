@@ -5,6 +5,7 @@ import (
 	"context"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,18 +17,25 @@ import (
 	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
 	"github.com/google/go-cmp/cmp"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/warning"
 
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
 	apiextensionsinformers "k8s.io/apiextensions-apiserver/pkg/client/informers/externalversions"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 )
 
 type IntrospectableController interface {
@@ -58,7 +66,7 @@ func TestBasic(t *testing.T) {
 			Group:    "policy.acme.co",
 			Version:  "v1",
 			Resource: "requiredlabels",
-		}: "required_labelsList",
+		}: "requiredlabelsList",
 	})
 	client := fake.NewSimpleClientset()
 	fakeext := apiextensionsfake.NewSimpleClientset(crd)
@@ -72,9 +80,11 @@ func TestBasic(t *testing.T) {
 
 	controller := controllerv0alpha2.NewPolicyTemplateController(
 		dynamicClient,
+		client,
 		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
 		structuralschemaController,
 		fakeext,
+		nil,
 	).(IntrospectableController)
 
 	factory.Start(ctx.Done())
@@ -108,7 +118,7 @@ func TestBasic(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 
-	err = wait.PollWithContext(ctx, 30*time.Millisecond, 2*time.Hour, func(ctx context.Context) (done bool, err error) {
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
 		// Wait until CRD pops up
 		obj, err := fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(
 			ctx,
@@ -147,7 +157,7 @@ func TestBasic(t *testing.T) {
 	_, err = dynamicClient.Resource(schema.GroupVersionResource{
 		Group:    instance.GroupVersionKind().Group,
 		Version:  instance.GroupVersionKind().Version,
-		Resource: "required_labels",
+		Resource: "requiredlabels",
 	}).Namespace(instance.GetNamespace()).Create(ctx, instance, metav1.CreateOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
@@ -284,4 +294,1311 @@ func TestBasic(t *testing.T) {
 	if !reflect.DeepEqual(expected, actual) {
 		t.Fatalf("%s", cmp.Diff(expected, actual))
 	}
+
+	// Add a second instance and check both are enforced
+	file, err = ioutil.ReadFile("testdata/required_labels/instance2.yaml")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	instance2 := &unstructured.Unstructured{}
+	decoder = yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24)
+	err = decoder.Decode(instance2)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	instanceClient := dynamicClient.Resource(schema.GroupVersionResource{
+		Group:    instance2.GroupVersionKind().Group,
+		Version:  instance2.GroupVersionKind().Version,
+		Resource: "requiredlabels",
+	}).Namespace(instance2.GetNamespace())
+
+	instance2, err = instanceClient.Create(ctx, instance2, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("requiredlabels") == 2, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prodObject := &testdata.BasicUnion{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "stable.example.com/v1",
+			Kind:       "BasicUnion",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testobject",
+			Namespace: "default",
+			Labels: map[string]string{
+				"ssh":      "enabled",
+				"env":      "prod",
+				"verified": "true",
+				// second instance expects 'tls': 'required'
+			},
+		},
+	}
+
+	verr = controller.Validate2(schema.GroupVersionResource(gvr), nil, prodObject)
+	expected = []any{
+		map[string]any{
+			"details": map[string]any{
+				"data": []any{
+					"tls",
+				},
+			},
+			"message": "missing one or more required labels",
+		},
+	}
+	if verr == nil {
+		t.Fatal("expected second instance to be enforced")
+	}
+	actual = verr.(controllerv0alpha2.DecisionError).ErrorJSON()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("%s", cmp.Diff(expected, actual))
+	}
+
+	// Update the second instance and check the previous version is no longer
+	// enforced
+	err = unstructured.SetNestedSlice(instance2.Object, []any{
+		map[string]any{
+			"labels": map[string]any{
+				"verified": "true",
+			},
+		},
+	}, "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fake client does not bump resource version, which is needed for the
+	// informer to report the update
+	instance2.SetResourceVersion("2")
+	_, err = instanceClient.Update(ctx, instance2, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.Validate2(schema.GroupVersionResource(gvr), nil, prodObject) == nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := controller.GetNumberInstances("requiredlabels"); n != 2 {
+		t.Fatalf("expected 2 instances after update, got %d", n)
+	}
+
+	// Delete the second instance and check the first is still enforced
+	err = instanceClient.Delete(ctx, instance2.GetName(), metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("requiredlabels") == 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verr = controller.Validate2(schema.GroupVersionResource(gvr), nil, prodObject)
+	if verr != nil {
+		t.Fatal(verr)
+	}
+
+	delete(prodObject.Labels, "ssh")
+	verr = controller.Validate2(schema.GroupVersionResource(gvr), nil, prodObject)
+	if verr == nil {
+		t.Fatal("expected first instance to still be enforced")
+	}
+
+	// Delete the template and check that it is no longer enforced
+	err = client.CeladmissionpolyfillV0alpha2().
+		PolicyTemplates(policy.Namespace).
+		Delete(ctx, policy.Name, metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("requiredlabels") == 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Generated CRD is cleaned up
+	_, err = fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(
+		ctx,
+		"requiredlabels.policy.acme.co",
+		metav1.GetOptions{},
+	)
+	if !errors.IsNotFound(err) {
+		t.Fatalf("expected crd to be deleted, got: %v", err)
+	}
+
+	verr = controller.Validate2(schema.GroupVersionResource(gvr), nil, &testdata.BasicUnion{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "stable.example.com/v1",
+			Kind:       "BasicUnion",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testobject",
+			Namespace: "default",
+			Labels: map[string]string{
+				"env": "incorrect",
+			},
+		},
+	})
+	if verr != nil {
+		t.Fatal(verr)
+	}
+}
+
+func TestCRDNames(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	file, err := ioutil.ReadFile("testdata/required_labels/policy.yaml")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	basePolicy := &v0alpha2.PolicyTemplate{}
+	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24)
+	err = decoder.Decode(basePolicy)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// CRD which was not generated by the controller
+	existing := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "widgets.example.com",
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset(existing)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		{
+			Group:    "policies.example.com",
+			Version:  "v1",
+			Resource: "requiredlabelsets",
+		}: "RequiredLabelsList",
+	})
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschema.NewController(
+			apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+		),
+		fakeext,
+		nil,
+	)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	waitForCondition := func(name string, status metav1.ConditionStatus, reason string) {
+		t.Helper()
+		var template *v0alpha2.PolicyTemplate
+		err := wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+			template, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(basePolicy.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			condition := meta.FindStatusCondition(template.Status.Conditions, v0alpha2.PolicyTemplateCRDCreated)
+			return condition != nil && condition.Status == status && condition.Reason == reason, nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v: %v", name, err, template.Status.Conditions)
+		}
+	}
+
+	// Custom names are used for the generated CRD
+	custom := basePolicy.DeepCopy()
+	custom.Name = "custom"
+	custom.Group = "policies.example.com"
+	custom.InstanceKind = "RequiredLabels"
+	custom.PluralName = "requiredlabelsets"
+	custom.ShortNames = []string{"rl"}
+	custom.Scope = apiextensionsv1.NamespaceScoped
+	custom.AdditionalPrinterColumns = []apiextensionsv1.CustomResourceColumnDefinition{
+		{Name: "Description", Type: "string", JSONPath: ".description"},
+	}
+	_, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(custom.Namespace).Create(ctx, custom, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForCondition(custom.Name, metav1.ConditionTrue, "Created")
+
+	crd, err := fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "requiredlabelsets.policies.example.com", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedNames := apiextensionsv1.CustomResourceDefinitionNames{
+		Plural:     "requiredlabelsets",
+		Singular:   "requiredlabels",
+		ShortNames: []string{"rl"},
+		Kind:       "RequiredLabels",
+		ListKind:   "RequiredLabelsList",
+		Categories: []string{"policy"},
+	}
+	if !reflect.DeepEqual(expectedNames, crd.Spec.Names) {
+		t.Fatalf("%s", cmp.Diff(expectedNames, crd.Spec.Names))
+	}
+	if crd.Spec.Group != "policies.example.com" || crd.Spec.Scope != apiextensionsv1.NamespaceScoped {
+		t.Fatalf("unexpected group or scope: %s %s", crd.Spec.Group, crd.Spec.Scope)
+	}
+	if !reflect.DeepEqual(custom.AdditionalPrinterColumns, crd.Spec.Versions[0].AdditionalPrinterColumns) {
+		t.Fatalf("%s", cmp.Diff(custom.AdditionalPrinterColumns, crd.Spec.Versions[0].AdditionalPrinterColumns))
+	}
+
+	// Names which are not DNS-safe are reported
+	invalid := basePolicy.DeepCopy()
+	invalid.Name = "invalid"
+	invalid.PluralName = "Required_Labels"
+	_, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(invalid.Namespace).Create(ctx, invalid, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForCondition(invalid.Name, metav1.ConditionFalse, "InvalidNames")
+
+	// Existing CRDs are not taken over
+	conflicting := basePolicy.DeepCopy()
+	conflicting.Name = "conflicting"
+	conflicting.Group = "example.com"
+	conflicting.InstanceKind = "Widget"
+	conflicting.PluralName = "widgets"
+	_, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(conflicting.Namespace).Create(ctx, conflicting, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForCondition(conflicting.Name, metav1.ConditionFalse, "Conflict")
+
+	crd, err = fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "widgets.example.com", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(existing, crd) {
+		t.Fatalf("%s", cmp.Diff(existing, crd))
+	}
+
+	// CRDs generated for other templates are not taken over
+	duplicate := custom.DeepCopy()
+	duplicate.Name = "duplicate"
+	duplicate.ResourceVersion = ""
+	_, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(duplicate.Namespace).Create(ctx, duplicate, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForCondition(duplicate.Name, metav1.ConditionFalse, "Conflict")
+}
+
+func TestInstanceMatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	decodeFile(t, "testdata/stable.example.com_basicunions.yaml", crd)
+
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/required_labels/policy.yaml", policy)
+
+	instance := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/required_labels/instance_match.yaml", instance)
+
+	instancesGVR := schema.GroupVersionResource{
+		Group:    "policy.acme.co",
+		Version:  "v1",
+		Resource: "requiredlabels",
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		instancesGVR: "requiredlabelsList",
+	})
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset(crd)
+	kubeClient := kubefake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging", Labels: map[string]string{"env": "staging"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", Labels: map[string]string{"env": "prod"}}},
+	)
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+	kubeFactory := informers.NewSharedInformerFactory(kubeClient, 30*time.Second)
+	namespaceLister := kubeFactory.Core().V1().Namespaces().Lister()
+
+	structuralschemaController := structuralschema.NewController(
+		apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+	)
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschemaController,
+		fakeext,
+		namespaceLister,
+	).(IntrospectableController)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+	kubeFactory.Start(ctx.Done())
+	kubeFactory.WaitForCacheSync(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		_, err = fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "requiredlabels.policy.acme.co", metav1.GetOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dynamicClient.Resource(instancesGVR).Namespace(instance.GetNamespace()).Create(ctx, instance, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("requiredlabels") == 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    "stable.example.com",
+		Version:  "v1",
+		Resource: "basicunions",
+	}
+
+	cases := []struct {
+		name      string
+		kind      string
+		namespace string
+		labels    map[string]string
+		denied    bool
+	}{
+		{
+			name:      "matching object missing label",
+			kind:      "BasicUnion",
+			namespace: "default",
+			denied:    true,
+		},
+		{
+			name:      "matching object with label",
+			kind:      "BasicUnion",
+			namespace: "default",
+			labels:    map[string]string{"team": "payments"},
+		},
+		{
+			name:      "object excluded by label selector",
+			kind:      "BasicUnion",
+			namespace: "default",
+			labels:    map[string]string{"exempt": "true"},
+		},
+		{
+			name:      "namespace not selected",
+			kind:      "BasicUnion",
+			namespace: "staging",
+		},
+		{
+			name:      "excluded namespace",
+			kind:      "BasicUnion",
+			namespace: "kube-system",
+		},
+		{
+			name:      "namespace does not exist",
+			kind:      "BasicUnion",
+			namespace: "missing",
+			denied:    true,
+		},
+		{
+			name:      "kind not matched",
+			kind:      "OtherUnion",
+			namespace: "default",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			labels := tc.labels
+			if labels == nil {
+				labels = map[string]string{}
+			}
+
+			verr := controller.Validate2(gvr, nil, &testdata.BasicUnion{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "stable.example.com/v1",
+					Kind:       tc.kind,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "testobject",
+					Namespace: tc.namespace,
+					Labels:    labels,
+				},
+			})
+
+			if tc.denied && verr == nil {
+				t.Fatal("expected object to be denied")
+			} else if !tc.denied && verr != nil {
+				t.Fatal(verr)
+			}
+
+			// Objects decoded by the webhook may have no apiVersion and
+			// kind, so their kind is matched from the request
+			object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			object.SetName("testobject")
+			object.SetNamespace(tc.namespace)
+			object.SetLabels(labels)
+
+			attrs := admission.NewAttributesRecord(
+				object,
+				nil,
+				gvr.GroupVersion().WithKind(tc.kind),
+				tc.namespace,
+				object.GetName(),
+				gvr,
+				"",
+				admission.Create,
+				nil,
+				false,
+				&user.DefaultInfo{Name: "alice"},
+			)
+
+			verr = controller.Validate(ctx, attrs, nil)
+			if tc.denied && verr == nil {
+				t.Fatal("expected request to be denied")
+			} else if !tc.denied && verr != nil {
+				t.Fatal(verr)
+			}
+		})
+	}
+
+	// Resources without a structural schema are only looked up when an
+	// instance matches, and their objects are evaluated untyped
+	untyped := func(gvr schema.GroupVersionResource, kind string, labels map[string]string) error {
+		object := &unstructured.Unstructured{}
+		object.SetAPIVersion(gvr.GroupVersion().String())
+		object.SetKind(kind)
+		object.SetName("testobject")
+		object.SetNamespace("default")
+		object.SetLabels(labels)
+		return controller.Validate2(gvr, nil, object)
+	}
+
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	if verr := untyped(configMaps, "ConfigMap", nil); verr != nil {
+		t.Fatal(verr)
+	}
+
+	unknownUnions := schema.GroupVersionResource{Group: "stable.example.com", Version: "v2", Resource: "basicunions"}
+	if verr := untyped(unknownUnions, "BasicUnion", nil); verr == nil {
+		t.Fatal("expected untyped object missing label to be denied")
+	}
+	if verr := untyped(unknownUnions, "BasicUnion", map[string]string{"team": "payments"}); verr != nil {
+		t.Fatal(verr)
+	}
+}
+
+func decodeFile(t *testing.T, path string, into interface{}) {
+	t.Helper()
+
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24).Decode(into)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+}
+
+func TestRequestEnvironment(t *testing.T) {
+	testRequestEnvironment(t, "")
+}
+
+// Objects are typed by the schema of the resource named by the environment
+func TestTypedRequestEnvironment(t *testing.T) {
+	ctx, client := testRequestEnvironment(t, "basicunions.v1.stable.example.com")
+
+	// Fields missing from the schema are caught when compiling
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/immutable_labels/policy.yaml", policy)
+	policy.Name = "undefinedfield"
+	policy.Evaluator.Environment = "basicunions.v1.stable.example.com"
+	policy.Evaluator.Productions[0].Match = "object.spec.discriminatr == 'a'"
+
+	_, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		template, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Get(ctx, policy.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		condition := meta.FindStatusCondition(template.Status.Conditions, v0alpha2.PolicyTemplateCompiled)
+		return condition != nil && condition.Status == metav1.ConditionFalse && strings.Contains(condition.Message, "undefined field 'discriminatr'"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRequestEnvironment(t *testing.T, environment string) (context.Context, *fake.Clientset) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	decodeFile(t, "testdata/stable.example.com_basicunions.yaml", crd)
+
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/immutable_labels/policy.yaml", policy)
+	policy.Evaluator.Environment = environment
+
+	instance := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/immutable_labels/instance.yaml", instance)
+
+	instancesGVR := schema.GroupVersionResource{
+		Group:    "policy.acme.co",
+		Version:  "v1",
+		Resource: "immutablelabels",
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		instancesGVR: "immutablelabelsList",
+	})
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset(crd)
+	kubeClient := kubefake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "archive", Labels: map[string]string{"frozen": "true"}}},
+	)
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+	kubeFactory := informers.NewSharedInformerFactory(kubeClient, 30*time.Second)
+	namespaceLister := kubeFactory.Core().V1().Namespaces().Lister()
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschema.NewController(
+			apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+		),
+		fakeext,
+		namespaceLister,
+	).(IntrospectableController)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+	kubeFactory.Start(ctx.Done())
+	kubeFactory.WaitForCacheSync(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		_, err = fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "immutablelabels.policy.acme.co", metav1.GetOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dynamicClient.Resource(instancesGVR).Create(ctx, instance, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("immutablelabels") == 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    "stable.example.com",
+		Version:  "v1",
+		Resource: "basicunions",
+	}
+
+	newObject := func(namespace string, labels map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "stable.example.com/v1",
+			"kind":       "BasicUnion",
+			"metadata": map[string]interface{}{
+				"name":      "testobject",
+				"namespace": namespace,
+				"labels":    labels,
+			},
+		}}
+	}
+
+	cases := []struct {
+		name      string
+		operation admission.Operation
+		object    *unstructured.Unstructured
+		oldObject *unstructured.Unstructured
+		user      string
+		expected  []any
+	}{
+		{
+			name:      "create",
+			operation: admission.Create,
+			object:    newObject("default", map[string]interface{}{"team": "a"}),
+			user:      "alice",
+		},
+		{
+			name:      "update keeping label",
+			operation: admission.Update,
+			object:    newObject("default", map[string]interface{}{"team": "a", "other": "b"}),
+			oldObject: newObject("default", map[string]interface{}{"team": "a"}),
+			user:      "alice",
+		},
+		{
+			name:      "update changing label",
+			operation: admission.Update,
+			object:    newObject("default", map[string]interface{}{"team": "b"}),
+			oldObject: newObject("default", map[string]interface{}{"team": "a"}),
+			user:      "alice",
+			expected: []any{
+				map[string]any{"message": "labels may not be changed"},
+			},
+		},
+		{
+			name:      "denied user",
+			operation: admission.Create,
+			object:    newObject("default", map[string]interface{}{"team": "a"}),
+			user:      "mallory",
+			expected: []any{
+				map[string]any{"message": "user may not modify this object"},
+			},
+		},
+		{
+			name:      "frozen namespace",
+			operation: admission.Create,
+			object:    newObject("archive", map[string]interface{}{"team": "a"}),
+			user:      "alice",
+			expected: []any{
+				map[string]any{"message": "namespace is frozen"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var oldObject runtime.Object
+			if tc.oldObject != nil {
+				oldObject = tc.oldObject
+			}
+
+			attrs := admission.NewAttributesRecord(
+				tc.object,
+				oldObject,
+				tc.object.GroupVersionKind(),
+				tc.object.GetNamespace(),
+				tc.object.GetName(),
+				gvr,
+				"",
+				tc.operation,
+				nil,
+				false,
+				&user.DefaultInfo{Name: tc.user},
+			)
+
+			verr := controller.Validate(ctx, attrs, nil)
+			if tc.expected == nil {
+				if verr != nil {
+					t.Fatal(verr)
+				}
+				return
+			}
+
+			if verr == nil {
+				t.Fatal("expected object to be denied")
+			}
+
+			actual := verr.(controllerv0alpha2.DecisionError).ErrorJSON()
+			if !reflect.DeepEqual(tc.expected, actual) {
+				t.Fatalf("%s", cmp.Diff(tc.expected, actual))
+			}
+		})
+	}
+
+	return ctx, client
+}
+
+func TestStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	decodeFile(t, "testdata/stable.example.com_basicunions.yaml", crd)
+
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/required_labels/policy.yaml", policy)
+
+	valid := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/required_labels/instance.yaml", valid)
+
+	invalid := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/required_labels/instance_invalid.yaml", invalid)
+
+	instancesGVR := schema.GroupVersionResource{
+		Group:    "policy.acme.co",
+		Version:  "v1",
+		Resource: "requiredlabels",
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		instancesGVR: "requiredlabelsList",
+	})
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset(crd)
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschema.NewController(
+			apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+		),
+		fakeext,
+		nil,
+	)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	waitForTemplate := func(description string, check func(v0alpha2.PolicyTemplateStatus) bool) {
+		t.Helper()
+		var template *v0alpha2.PolicyTemplate
+		err := wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+			template, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Get(ctx, policy.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return check(template.Status), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v: %+v", description, err, template.Status)
+		}
+	}
+
+	hasCondition := func(conditionType string, status metav1.ConditionStatus, reason string) func(v0alpha2.PolicyTemplateStatus) bool {
+		return func(s v0alpha2.PolicyTemplateStatus) bool {
+			condition := meta.FindStatusCondition(s.Conditions, conditionType)
+			return condition != nil && condition.Status == status && condition.Reason == reason
+		}
+	}
+
+	waitForInstance := func(name string, check func(status map[string]interface{}) bool) {
+		t.Helper()
+		var status map[string]interface{}
+		err := wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+			instance, err := dynamicClient.Resource(instancesGVR).Namespace("default").Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			status, _, _ = unstructured.NestedMap(instance.Object, "status")
+			return check(status), nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v: %v", name, err, status)
+		}
+	}
+
+	policy, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForTemplate("crd created", hasCondition(v0alpha2.PolicyTemplateCRDCreated, metav1.ConditionTrue, "Created"))
+	waitForTemplate("compiled", hasCondition(v0alpha2.PolicyTemplateCompiled, metav1.ConditionTrue, "Compiled"))
+
+	generated, err := fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "requiredlabels.policy.acme.co", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generated.Spec.Versions[0].Subresources == nil || generated.Spec.Versions[0].Subresources.Status == nil {
+		t.Fatalf("expected status subresource on generated crd")
+	}
+
+	for _, instance := range []*unstructured.Unstructured{valid, invalid} {
+		_, err = dynamicClient.Resource(instancesGVR).Namespace("default").Create(ctx, instance, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both instances are counted, but only the valid one is enforced
+	waitForTemplate("instance count", func(s v0alpha2.PolicyTemplateStatus) bool {
+		return s.InstanceCount == 2
+	})
+
+	waitForInstance(valid.GetName(), func(status map[string]interface{}) bool {
+		return status["compiled"] == true && status["enforced"] == true && status["lastError"] == nil
+	})
+
+	waitForInstance(invalid.GetName(), func(status map[string]interface{}) bool {
+		lastError, _ := status["lastError"].(string)
+		return status["compiled"] == false && status["enforced"] == false && len(lastError) > 0
+	})
+
+	gvr := schema.GroupVersionResource{
+		Group:    "stable.example.com",
+		Version:  "v1",
+		Resource: "basicunions",
+	}
+	object := &testdata.BasicUnion{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "stable.example.com/v1",
+			Kind:       "BasicUnion",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "testobject",
+			Namespace: "default",
+			Labels: map[string]string{
+				"env": "prod",
+			},
+		},
+	}
+
+	if err := controller.Validate2(gvr, nil, object); err == nil {
+		t.Fatalf("expected valid instance to be enforced")
+	}
+
+	// Template which no longer compiles leaves the last version enforced
+	broken := policy.DeepCopy()
+	broken.Generation = 2
+	broken.ResourceVersion = "2"
+	broken.Evaluator.Terms[0].Value = "rule.labels.("
+	_, err = client.CeladmissionpolyfillV0alpha2().PolicyTemplates(broken.Namespace).Update(ctx, broken, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForTemplate("compilation failed", func(s v0alpha2.PolicyTemplateStatus) bool {
+		condition := meta.FindStatusCondition(s.Conditions, v0alpha2.PolicyTemplateCompiled)
+		return s.ObservedGeneration == 2 &&
+			condition != nil &&
+			condition.Status == metav1.ConditionFalse &&
+			condition.Reason == "CompilationFailed" &&
+			len(condition.Message) > 0
+	})
+
+	if err := controller.Validate2(gvr, nil, object); err == nil {
+		t.Fatalf("expected last compiled version of template to be enforced")
+	}
+
+	// Removed instances are no longer counted
+	err = dynamicClient.Resource(instancesGVR).Namespace("default").Delete(ctx, invalid.GetName(), metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForTemplate("instance removed", func(s v0alpha2.PolicyTemplateStatus) bool {
+		return s.InstanceCount == 1
+	})
+}
+
+type recordedWarnings []string
+
+func (r *recordedWarnings) AddWarning(agent, text string) {
+	*r = append(*r, text)
+}
+
+func TestDecisionStatus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	decodeFile(t, "testdata/stable.example.com_basicunions.yaml", crd)
+
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/label_hygiene/policy.yaml", policy)
+
+	instance := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/label_hygiene/instance.yaml", instance)
+
+	instancesGVR := schema.GroupVersionResource{
+		Group:    "policy.acme.co",
+		Version:  "v1",
+		Resource: "labelhygiene",
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		instancesGVR: "labelhygieneList",
+	})
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset(crd)
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschema.NewController(
+			apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+		),
+		fakeext,
+		nil,
+	).(IntrospectableController)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		_, err = fakeext.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, "labelhygiene.policy.acme.co", metav1.GetOptions{})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = dynamicClient.Resource(instancesGVR).Create(ctx, instance, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return controller.GetNumberInstances("labelhygiene") == 1, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gvr := schema.GroupVersionResource{
+		Group:    "stable.example.com",
+		Version:  "v1",
+		Resource: "basicunions",
+	}
+
+	validate := func(labels map[string]interface{}) (error, []string) {
+		object := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "stable.example.com/v1",
+			"kind":       "BasicUnion",
+			"metadata": map[string]interface{}{
+				"name":      "testobject",
+				"namespace": "default",
+			},
+		}}
+		if labels != nil {
+			unstructured.SetNestedMap(object.Object, labels, "metadata", "labels")
+		}
+
+		attrs := admission.NewAttributesRecord(
+			object,
+			nil,
+			object.GroupVersionKind(),
+			object.GetNamespace(),
+			object.GetName(),
+			gvr,
+			"",
+			admission.Create,
+			nil,
+			false,
+			&user.DefaultInfo{Name: "alice"},
+		)
+
+		var warnings recordedWarnings
+		err := controller.Validate(warning.WithWarningRecorder(ctx, &warnings), attrs, nil)
+		return err, warnings
+	}
+
+	// Warnings do not deny the request
+	verr, warnings := validate(map[string]interface{}{"owner": "a"})
+	if verr != nil {
+		t.Fatal(verr)
+	}
+
+	expectedWarnings := []string{"metadata.labels: deprecated labels are in use (labelhygiene/team-labels)"}
+	if !reflect.DeepEqual(expectedWarnings, []string(warnings)) {
+		t.Fatalf("%s", cmp.Diff(expectedWarnings, []string(warnings)))
+	}
+
+	// Denials carry a cause per violation
+	verr, warnings = validate(map[string]interface{}{"owner": "a", "tmp": "b"})
+	if verr == nil {
+		t.Fatal("expected object to be denied")
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected warning alongside denial: %v", warnings)
+	}
+
+	status, ok := verr.(errors.APIStatus)
+	if !ok {
+		t.Fatalf("expected APIStatus, got %T", verr)
+	}
+
+	expected := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    422,
+		Reason:  metav1.StatusReasonInvalid,
+		Message: `BasicUnion.stable.example.com "testobject" is invalid: metadata.labels: forbidden labels are in use (labelhygiene/team-labels)`,
+		Details: &metav1.StatusDetails{
+			Group: "stable.example.com",
+			Kind:  "BasicUnion",
+			Name:  "testobject",
+			Causes: []metav1.StatusCause{
+				{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   "metadata.labels",
+					Message: "forbidden labels are in use (labelhygiene/team-labels)",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, status.Status()) {
+		t.Fatalf("%s", cmp.Diff(expected, status.Status()))
+	}
+
+	// Violations without a field have no field path
+	verr, _ = validate(nil)
+	if verr == nil {
+		t.Fatal("expected object to be denied")
+	}
+
+	causes := verr.(errors.APIStatus).Status().Details.Causes
+	if len(causes) != 1 || causes[0].Field != "" || causes[0].Message != "labels are required (labelhygiene/team-labels)" {
+		t.Fatalf("unexpected causes: %v", causes)
+	}
+}
+
+func TestInstanceValidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	policy := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/allowed_values/policy.yaml", policy)
+
+	instancesGVR := schema.GroupVersionResource{
+		Group:    "policy.acme.co",
+		Version:  "v1",
+		Resource: "allowedvalues",
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		instancesGVR: "allowedvaluesList",
+	})
+	client := fake.NewSimpleClientset()
+	fakeext := apiextensionsfake.NewSimpleClientset()
+
+	factory := externalversions.NewSharedInformerFactory(client, 30*time.Second)
+	apiextensionsFactory := apiextensionsinformers.NewSharedInformerFactory(fakeext, 30*time.Second)
+
+	controller := controllerv0alpha2.NewPolicyTemplateController(
+		dynamicClient,
+		client,
+		factory.Celadmissionpolyfill().V0alpha2().PolicyTemplates().Informer(),
+		structuralschema.NewController(
+			apiextensionsFactory.Apiextensions().V1().CustomResourceDefinitions().Informer(),
+		),
+		fakeext,
+		nil,
+	)
+
+	factory.Start(ctx.Done())
+	apiextensionsFactory.Start(ctx.Done())
+
+	go func() {
+		err := controller.Run(ctx)
+
+		if ctx.Err() == nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err := client.CeladmissionpolyfillV0alpha2().PolicyTemplates(policy.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newInstance := func(fields map[string]interface{}) *unstructured.Unstructured {
+		instance := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "policy.acme.co/v1",
+			"kind":       "allowedvalues",
+			"metadata": map[string]interface{}{
+				"name": "env-values",
+			},
+		}}
+		for k, v := range fields {
+			instance.Object[k] = v
+		}
+		return instance
+	}
+
+	validate := func(instance *unstructured.Unstructured) error {
+		attrs := admission.NewAttributesRecord(
+			instance,
+			nil,
+			instance.GroupVersionKind(),
+			"",
+			instance.GetName(),
+			instancesGVR,
+			"",
+			admission.Create,
+			nil,
+			false,
+			&user.DefaultInfo{Name: "alice"},
+		)
+		return controller.Validate(ctx, attrs, nil)
+	}
+
+	// Wait until the template is tracked and invalid instances are rejected
+	invalid := newInstance(map[string]interface{}{
+		"rule": map[string]interface{}{"label": "env", "values": "prod"},
+	})
+	err = wait.PollWithContext(ctx, 30*time.Millisecond, 5*time.Second, func(ctx context.Context) (done bool, err error) {
+		return errors.IsInvalid(validate(invalid)), nil
+	})
+	if err != nil {
+		t.Fatal(validate(invalid))
+	}
+
+	valid := newInstance(map[string]interface{}{
+		"rule": map[string]interface{}{"label": "env", "values": []interface{}{"prod"}},
+	})
+	if err := validate(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected []metav1.StatusCause
+	}{
+		{
+			name: "validator",
+			fields: map[string]interface{}{
+				"rule": map[string]interface{}{"label": "env", "values": []interface{}{}},
+			},
+			expected: []metav1.StatusCause{
+				{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   "rule.values",
+					Message: "Invalid value: at least one value is required",
+				},
+			},
+		},
+		{
+			name: "validator on rules",
+			fields: map[string]interface{}{
+				"rules": []interface{}{
+					map[string]interface{}{"label": "env", "values": []interface{}{"prod"}},
+					map[string]interface{}{"label": "", "values": []interface{}{"a"}},
+				},
+			},
+			expected: []metav1.StatusCause{
+				{
+					Type:    metav1.CauseTypeFieldValueInvalid,
+					Field:   "rules[1].label",
+					Message: "Invalid value: label must not be empty",
+				},
+			},
+		},
+		{
+			name: "schema",
+			fields: map[string]interface{}{
+				"rule": map[string]interface{}{"label": "env", "values": "prod"},
+			},
+		},
+		{
+			name: "invalid match",
+			fields: map[string]interface{}{
+				"rule": map[string]interface{}{"label": "env", "values": []interface{}{"prod"}},
+				"match": map[string]interface{}{
+					"labelSelector": map[string]interface{}{
+						"matchExpressions": []interface{}{
+							map[string]interface{}{"key": "env", "operator": "Bogus"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			verr := validate(newInstance(tc.fields))
+			if !errors.IsInvalid(verr) {
+				t.Fatalf("expected invalid error, got %v", verr)
+			}
+
+			causes := verr.(errors.APIStatus).Status().Details.Causes
+			if tc.expected != nil && !reflect.DeepEqual(tc.expected, causes) {
+				t.Fatalf("%s", cmp.Diff(tc.expected, causes))
+			}
+		})
+	}
 }
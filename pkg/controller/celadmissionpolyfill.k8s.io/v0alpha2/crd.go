@@ -0,0 +1,208 @@
+package v0alpha2
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	//!TODO: decide policy for which group to use
+	//	example: OPA gatekeeper uses specialized constraints group for all
+	//		constraints
+	defaultInstanceGroup = "policy.acme.co"
+	instanceVersion      = "v1"
+)
+
+// Names of the CRD generated for instances of a template, with defaults
+// applied
+type instanceNames struct {
+	group      string
+	kind       string
+	plural     string
+	shortNames []string
+	scope      apiextensionsv1.ResourceScope
+}
+
+func (n instanceNames) crdName() string {
+	return n.plural + "." + n.group
+}
+
+func instanceNamesForTemplate(template *v0alpha2.PolicyTemplate) instanceNames {
+	names := instanceNames{
+		group:      template.Group,
+		kind:       template.InstanceKind,
+		plural:     template.PluralName,
+		shortNames: template.ShortNames,
+		scope:      template.Scope,
+	}
+
+	if len(names.group) == 0 {
+		names.group = defaultInstanceGroup
+	}
+
+	if len(names.kind) == 0 {
+		names.kind = template.Name
+	}
+
+	if len(names.plural) == 0 {
+		names.plural = strings.ToLower(names.kind)
+	}
+
+	if len(names.scope) == 0 {
+		names.scope = apiextensionsv1.ClusterScoped
+	}
+
+	return names
+}
+
+// Returns the kind of the instances of the template, which is served by the
+// CRD generated for it
+func InstanceGroupVersionKind(template *v0alpha2.PolicyTemplate) schema.GroupVersionKind {
+	names := instanceNamesForTemplate(template)
+	return schema.GroupVersionKind{
+		Group:   names.group,
+		Version: instanceVersion,
+		Kind:    names.kind,
+	}
+}
+
+// Checks the names of the generated CRD would be accepted by the apiserver
+func validateInstanceNames(names instanceNames) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if msgs := validation.IsDNS1123Subdomain(names.group); len(msgs) > 0 {
+		errs = append(errs, field.Invalid(specPath.Child("group"), names.group, strings.Join(msgs, ",")))
+	} else if len(strings.Split(names.group, ".")) < 2 {
+		errs = append(errs, field.Invalid(specPath.Child("group"), names.group, "should be a domain with at least one dot"))
+	}
+
+	if msgs := validation.IsDNS1035Label(strings.ToLower(names.kind)); len(msgs) > 0 {
+		errs = append(errs, field.Invalid(specPath.Child("instanceKind"), names.kind, strings.Join(msgs, ",")))
+	}
+
+	if msgs := validation.IsDNS1035Label(names.plural); len(msgs) > 0 {
+		errs = append(errs, field.Invalid(specPath.Child("pluralName"), names.plural, strings.Join(msgs, ",")))
+	}
+
+	seen := map[string]struct{}{}
+	for i, shortName := range names.shortNames {
+		if msgs := validation.IsDNS1035Label(shortName); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(specPath.Child("shortNames").Index(i), shortName, strings.Join(msgs, ",")))
+		}
+		if _, exists := seen[shortName]; exists {
+			errs = append(errs, field.Duplicate(specPath.Child("shortNames").Index(i), shortName))
+		}
+		seen[shortName] = struct{}{}
+	}
+
+	switch names.scope {
+	case apiextensionsv1.ClusterScoped, apiextensionsv1.NamespaceScoped:
+	default:
+		errs = append(errs, field.NotSupported(specPath.Child("scope"), names.scope, []string{
+			string(apiextensionsv1.ClusterScoped),
+			string(apiextensionsv1.NamespaceScoped),
+		}))
+	}
+
+	return errs
+}
+
+// Generates the CRD for instances of the given template
+func crdForTemplate(template *v0alpha2.PolicyTemplate, names instanceNames) *apiextensionsv1.CustomResourceDefinition {
+	shortNames := names.shortNames
+	if shortNames == nil {
+		shortNames = []string{}
+	}
+
+	columns := template.AdditionalPrinterColumns
+	if len(columns) == 0 {
+		columns = defaultInstanceColumns()
+	}
+
+	return &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "apiextensions.k8s.io/v1",
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: names.crdName(),
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: template.APIVersion,
+					Kind:       template.Kind,
+					Name:       template.Name,
+					UID:        template.GetUID(),
+				},
+			},
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: names.group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:     names.plural,
+				Singular:   strings.ToLower(names.kind),
+				ShortNames: shortNames,
+				Kind:       names.kind,
+				ListKind:   names.kind + "List",
+				Categories: []string{"policy"},
+			},
+			Scope:                 names.scope,
+			PreserveUnknownFields: false,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:                     instanceVersion,
+					Served:                   true,
+					Storage:                  true,
+					Deprecated:               false,
+					AdditionalPrinterColumns: columns,
+					Subresources: &apiextensionsv1.CustomResourceSubresources{
+						Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
+					},
+
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+							Type:     "object",
+							Required: []string{"apiVersion", "kind", "metadata"},
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"apiVersion":  {Type: "string"},
+								"kind":        {Type: "string"},
+								"metadata":    {Type: "object"},
+								"description": {Type: "string"},
+								matchField:    matchSchema(),
+								"rule":        template.Schema,
+								"rules":       {Type: "array", Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &template.Schema}},
+								"status":      instanceStatusSchema(),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Columns shown for instances of templates which do not specify their own
+func defaultInstanceColumns() []apiextensionsv1.CustomResourceColumnDefinition {
+	return []apiextensionsv1.CustomResourceColumnDefinition{
+		{Name: "Enforced", Type: "boolean", JSONPath: ".status.enforced"},
+		{Name: "Error", Type: "string", JSONPath: ".status.lastError"},
+		{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
+	}
+}
+
+// Returns an error if the existing CRD was not generated for the template
+func checkCRDOwnership(existing *apiextensionsv1.CustomResourceDefinition, template *v0alpha2.PolicyTemplate) error {
+	for _, ref := range existing.OwnerReferences {
+		if ref.UID == template.UID {
+			return nil
+		}
+	}
+	return fmt.Errorf("crd %s already exists and is not owned by policy template %s", existing.Name, template.Name)
+}
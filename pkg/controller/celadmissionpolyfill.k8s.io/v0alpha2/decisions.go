@@ -0,0 +1,183 @@
+package v0alpha2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/cel-policy-templates-go/policy/model"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Decisions produced by template evaluators which are reported as warnings
+// rather than denying the request. All other decisions deny it.
+const warningDecision = "policy.warning"
+
+// Keys of production outputs which are surfaced in denials
+const (
+	outputMessageKey = "message"
+	outputFieldKey   = "field"
+)
+
+// A single output of a decision made by an instance of a template
+type Violation struct {
+	Template string
+	Instance string
+
+	// Name of the decision, e.g. policy.violation
+	Decision string
+
+	// Path of the field the violation applies to. Empty if the output of the
+	// production did not specify one
+	Field string
+
+	Message string
+}
+
+// Message of the violation qualified by the instance which produced it
+func (v Violation) causeMessage() string {
+	return fmt.Sprintf("%s (%s/%s)", v.Message, v.Template, v.Instance)
+}
+
+func (v Violation) String() string {
+	if len(v.Field) == 0 {
+		return v.causeMessage()
+	}
+	return v.Field + ": " + v.causeMessage()
+}
+
+// Converts the decisions made by an instance into violations
+func violationsForDecisions(templateName, instanceKey string, decisions []model.DecisionValue) []Violation {
+	var violations []Violation
+	for _, d := range decisions {
+		var outputs []interface{}
+		switch d := d.(type) {
+		case model.MultiDecisionValue:
+			for _, v := range d.Values() {
+				outputs = append(outputs, ValToMap(v))
+			}
+		case model.SingleDecisionValue:
+			outputs = append(outputs, ValToMap(d.Value()))
+		}
+
+		for _, output := range outputs {
+			violation := Violation{
+				Template: templateName,
+				Instance: instanceKey,
+				Decision: d.Name(),
+			}
+
+			switch output := output.(type) {
+			case string:
+				violation.Message = output
+			case map[string]interface{}:
+				violation.Message, _ = output[outputMessageKey].(string)
+				violation.Field, _ = output[outputFieldKey].(string)
+			}
+
+			if len(violation.Message) == 0 {
+				// Output with no message is shown as-is
+				js, err := json.Marshal(output)
+				if err != nil {
+					violation.Message = fmt.Sprint(output)
+				} else {
+					violation.Message = string(js)
+				}
+			}
+
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+// Splits decisions into those which deny the request and those which only
+// warn
+func partitionDecisions(decisions []model.DecisionValue) (deny, warn []model.DecisionValue) {
+	for _, d := range decisions {
+		if d.Name() == warningDecision {
+			warn = append(warn, d)
+		} else {
+			deny = append(deny, d)
+		}
+	}
+	return deny, warn
+}
+
+// Returned when an object is denied by instances of policy templates. Reads
+// like a native validation error when returned from admission.
+type DecisionError struct {
+	Decisions  []model.DecisionValue
+	Violations []Violation
+
+	// Object which was denied
+	Kind schema.GroupKind
+	Name string
+}
+
+func (de DecisionError) ErrorJSON() []interface{} {
+	vs := []interface{}{}
+	for _, d := range de.Decisions {
+		if l, ok := d.(*model.ListDecisionValue); ok {
+			vals := l.Values()
+			for _, v := range vals {
+				vs = append(vs, ValToMap(v))
+			}
+		}
+	}
+
+	return vs
+}
+
+func (de DecisionError) Error() string {
+	kind := de.Kind.String()
+	if len(de.Kind.Kind) == 0 {
+		kind = "object"
+	}
+
+	message := fmt.Sprintf("%s %q is invalid", kind, de.Name)
+
+	switch len(de.Violations) {
+	case 0:
+		return message
+	case 1:
+		return message + ": " + de.Violations[0].String()
+	}
+
+	reasons := make([]string, 0, len(de.Violations))
+	for _, v := range de.Violations {
+		reasons = append(reasons, v.String())
+	}
+	return message + ": [" + strings.Join(reasons, ", ") + "]"
+}
+
+// Status implements errors.APIStatus so denials carry a cause per violation
+func (de DecisionError) Status() metav1.Status {
+	causes := make([]metav1.StatusCause, 0, len(de.Violations))
+	for _, v := range de.Violations {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   v.Field,
+			Message: v.causeMessage(),
+		})
+	}
+
+	return metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusUnprocessableEntity,
+		Reason:  metav1.StatusReasonInvalid,
+		Message: de.Error(),
+		Details: &metav1.StatusDetails{
+			Group:  de.Kind.Group,
+			Kind:   de.Kind.Kind,
+			Name:   de.Name,
+			Causes: causes,
+		},
+	}
+}
+
+var _ error = DecisionError{}
+var _ errors.APIStatus = DecisionError{}
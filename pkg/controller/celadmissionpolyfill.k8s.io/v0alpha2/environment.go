@@ -0,0 +1,159 @@
+package v0alpha2
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-policy-templates-go/policy"
+	"github.com/google/cel-policy-templates-go/policy/model"
+
+	structuralschemas "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	celmodel "k8s.io/apiextensions-apiserver/pkg/apiserver/schema/cel/model"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	plugincel "k8s.io/apiserver/pkg/admission/plugin/cel"
+	apiservercel "k8s.io/apiserver/pkg/cel"
+	"k8s.io/apiserver/pkg/cel/library"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// Variables available to template evaluators and validators in addition to
+// `rule`, which the policy template compiler declares with the type of the
+// template's schema.
+const (
+	objectVarName          = "object"
+	oldObjectVarName       = "oldObject"
+	requestVarName         = "request"
+	namespaceObjectVarName = "namespaceObject"
+
+	// Same as object. Kept for templates written before object was available
+	resourceVarName = "resource"
+)
+
+// Name of the type of object and oldObject in typed environments
+const objectTypeName = "kubernetes.Object"
+
+// Returns the resource named by an evaluator environment, or nil if the
+// environment does not name one. Resources are named as
+// <resource>.<version>.<group>
+func environmentResource(environment string) *schema.GroupVersionResource {
+	gvr, _ := schema.ParseResourceArg(environment)
+	return gvr
+}
+
+// Returns the policy engine for templates of the given evaluator environment.
+// If objectSchema is nil, `object`, `oldObject` and `resource` are dyn so the
+// template may apply to any resource. Otherwise they are typed by the
+// structural schema of the resource named by the environment.
+func newPolicyEngine(environment string, objectSchema *structuralschemas.Structural) (*policy.Engine, error) {
+	var opts []cel.EnvOption
+
+	// cel.HomogeneousAggregateLiterals() makes `output.details.data` type
+	// validation fail since sibling `output.message` key is a string (and details is a map)
+	// opts = append(opts, cel.HomogeneousAggregateLiterals())
+	opts = append(opts, library.ExtensionLibs...)
+
+	baseEnv, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := apiservercel.NewRegistry(baseEnv)
+	requestType := plugincel.BuildRequestType()
+	rt, err := apiservercel.NewRuleTypes(requestType.TypeName(), requestType, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	// rt.EnvOptions would also declare `rule`, which clashes with the
+	// variable declared by the policy template compiler
+	rtWithTypes, err := rt.WithTypeProvider(baseEnv.TypeProvider())
+	if err != nil {
+		return nil, err
+	}
+
+	objectType := cel.DynType
+	if objectSchema != nil {
+		declType := celmodel.SchemaDeclType(objectSchema, true)
+		if declType == nil {
+			return nil, fmt.Errorf("schema of %s cannot be typed", environment)
+		}
+		declType = declType.MaybeAssignTypeName(objectTypeName)
+
+		objectTypes, err := apiservercel.NewRuleTypes(objectTypeName, declType, registry)
+		if err != nil {
+			return nil, err
+		}
+
+		rtWithTypes, err = objectTypes.WithTypeProvider(rtWithTypes)
+		if err != nil {
+			return nil, err
+		}
+		objectType = declType.CelType()
+	}
+
+	typeOpts := []cel.EnvOption{
+		cel.CustomTypeProvider(rtWithTypes),
+		cel.CustomTypeAdapter(rtWithTypes),
+	}
+
+	typeOpts = append(typeOpts,
+		cel.Variable(objectVarName, objectType),
+		cel.Variable(oldObjectVarName, objectType),
+		cel.Variable(requestVarName, requestType.CelType()),
+		cel.Variable(namespaceObjectVarName, cel.DynType),
+		cel.Variable(resourceVarName, objectType),
+	)
+
+	env, err := baseEnv.Extend(typeOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	engine, err := policy.NewEngine(policy.StandardExprEnv(env))
+	if err != nil {
+		return nil, err
+	}
+
+	// The template compiler looks up the environment of the evaluator by name
+	if len(environment) > 0 {
+		if err := engine.Registry.SetEnv(environment, model.NewEnv(environment)); err != nil {
+			return nil, err
+		}
+	}
+	return engine, nil
+}
+
+// Builds the values of `request` and `namespaceObject`. Both are null if
+// there are no admission attributes.
+func admissionVars(attrs admission.Attributes, namespaceLister corev1listers.NamespaceLister) (request, namespaceObject map[string]interface{}, err error) {
+	if attrs == nil {
+		return nil, nil, nil
+	}
+
+	request, err = runtime.DefaultUnstructuredConverter.ToUnstructured(plugincel.CreateAdmissionRequest(attrs))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(attrs.GetNamespace()) == 0 || namespaceLister == nil {
+		return request, nil, nil
+	}
+
+	ns, err := namespaceLister.Get(attrs.GetNamespace())
+	if errors.IsNotFound(err) {
+		// Namespace may be created alongside the object
+		return request, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	namespaceObject, err = runtime.DefaultUnstructuredConverter.ToUnstructured(ns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return request, namespaceObject, nil
+}
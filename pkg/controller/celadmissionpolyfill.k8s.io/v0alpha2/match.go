@@ -0,0 +1,200 @@
+package v0alpha2
+
+import (
+	"fmt"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+)
+
+// Key of the match block in instances of a PolicyTemplate
+const matchField = "match"
+
+// Compiled form of an instance's match block
+type matcher struct {
+	kinds              []v0alpha2.MatchKinds
+	namespaces         sets.String
+	excludedNamespaces sets.String
+	labelSelector      labels.Selector
+	namespaceSelector  labels.Selector
+}
+
+// Parses the match block of an instance. Returns a nil matcher if the
+// instance applies to every object.
+func newMatcher(instance *unstructured.Unstructured) (*matcher, error) {
+	raw, found, err := unstructured.NestedMap(instance.Object, matchField)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, nil
+	}
+
+	var match v0alpha2.Match
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &match); err != nil {
+		return nil, fmt.Errorf("invalid match: %w", err)
+	}
+
+	result := &matcher{
+		kinds:              match.Kinds,
+		namespaces:         sets.NewString(match.Namespaces...),
+		excludedNamespaces: sets.NewString(match.ExcludedNamespaces...),
+	}
+
+	if match.LabelSelector != nil {
+		result.labelSelector, err = metav1.LabelSelectorAsSelector(match.LabelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match.labelSelector: %w", err)
+		}
+	}
+
+	if match.NamespaceSelector != nil {
+		result.namespaceSelector, err = metav1.LabelSelectorAsSelector(match.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match.namespaceSelector: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// Returns true if the object of kind gvk falls within the scope of the
+// instance. A nil matcher matches everything.
+func (m *matcher) matches(gvk schema.GroupVersionKind, obj *unstructured.Unstructured, namespaceLister corev1listers.NamespaceLister) (bool, error) {
+	if m == nil {
+		return true, nil
+	}
+
+	if len(m.kinds) > 0 {
+		matched := false
+		for _, kinds := range m.kinds {
+			if matchesAny(kinds.APIGroups, gvk.Group) && matchesAny(kinds.Kinds, gvk.Kind) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	if m.labelSelector != nil && !m.labelSelector.Matches(labels.Set(obj.GetLabels())) {
+		return false, nil
+	}
+
+	// Namespaces are matched against themselves
+	namespace := obj.GetNamespace()
+	isNamespace := gvk.Group == "" && gvk.Kind == "Namespace"
+	if isNamespace {
+		namespace = obj.GetName()
+	} else if len(namespace) == 0 {
+		// Cluster-scoped objects are not constrained by namespace
+		return true, nil
+	}
+
+	if m.excludedNamespaces.Has(namespace) {
+		return false, nil
+	}
+
+	if m.namespaces.Len() > 0 && !m.namespaces.Has(namespace) {
+		return false, nil
+	}
+
+	if m.namespaceSelector == nil || m.namespaceSelector.Empty() {
+		return true, nil
+	}
+
+	if isNamespace {
+		return m.namespaceSelector.Matches(labels.Set(obj.GetLabels())), nil
+	}
+
+	if namespaceLister == nil {
+		return false, fmt.Errorf("namespaceSelector is not supported by this validator")
+	}
+
+	// A namespace which has not been observed yet cannot be checked against
+	// the selector, so the request is denied rather than let through
+	ns, err := namespaceLister.Get(namespace)
+	if err != nil {
+		return false, err
+	}
+
+	return m.namespaceSelector.Matches(labels.Set(ns.Labels)), nil
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, a := range allowed {
+		if a == "*" || a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Schema of the match block in the CRD generated for a template
+func matchSchema() apiextensionsv1.JSONSchemaProps {
+	stringList := apiextensionsv1.JSONSchemaProps{
+		Type:  "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"}},
+	}
+
+	labelSelector := apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"matchLabels": {
+				Type: "object",
+				AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{
+					Allows: true,
+					Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"},
+				},
+			},
+			"matchExpressions": {
+				Type: "array",
+				Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+					Schema: &apiextensionsv1.JSONSchemaProps{
+						Type:     "object",
+						Required: []string{"key", "operator"},
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"key":      {Type: "string"},
+							"operator": {Type: "string"},
+							"values":   stringList,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"kinds": {
+				Type: "array",
+				Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+					Schema: &apiextensionsv1.JSONSchemaProps{
+						Type: "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"apiGroups": stringList,
+							"kinds":     stringList,
+						},
+					},
+				},
+			},
+			"namespaces":         stringList,
+			"excludedNamespaces": stringList,
+			"labelSelector":      labelSelector,
+			"namespaceSelector":  labelSelector,
+		},
+	}
+}
@@ -0,0 +1,116 @@
+package v0alpha2
+
+import (
+	"reflect"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+)
+
+// Applies mutate to the latest version of the template's status. Skips the
+// write if nothing changed
+func (c *templateController) updateTemplateStatus(namespace, name string, mutate func(*v0alpha2.PolicyTemplateStatus)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := c.client.CeladmissionpolyfillV0alpha2().
+			PolicyTemplates(namespace).
+			Get(c.runningContext, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			// Template was removed. Nothing to report on
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		updated := current.DeepCopy()
+		mutate(&updated.Status)
+		if reflect.DeepEqual(updated.Status, current.Status) {
+			return nil
+		}
+
+		_, err = c.client.CeladmissionpolyfillV0alpha2().
+			PolicyTemplates(namespace).
+			UpdateStatus(c.runningContext, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Records a condition observed for the given generation of the template
+func (c *templateController) setTemplateCondition(
+	template *v0alpha2.PolicyTemplate,
+	conditionType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) error {
+	return c.updateTemplateStatus(template.Namespace, template.Name, func(s *v0alpha2.PolicyTemplateStatus) {
+		s.ObservedGeneration = template.Generation
+		meta.SetStatusCondition(&s.Conditions, metav1.Condition{
+			Type:               conditionType,
+			Status:             status,
+			ObservedGeneration: template.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	})
+}
+
+// Status reported on instances of a template
+func instanceStatus(instance instanceInfo, enforced bool) map[string]interface{} {
+	status := map[string]interface{}{
+		"compiled":           instance.err == nil,
+		"enforced":           enforced && instance.err == nil,
+		"observedGeneration": instance.object.GetGeneration(),
+	}
+
+	if instance.err != nil {
+		status["lastError"] = instance.err.Error()
+	}
+	return status
+}
+
+// Replaces the status of the latest version of the instance. Skips the write
+// if nothing changed
+func (c *templateController) updateInstanceStatus(
+	gvr schema.GroupVersionResource,
+	namespace, name string,
+	status map[string]interface{},
+) error {
+	client := c.dynamicClient.Resource(gvr).Namespace(namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(c.runningContext, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		existing, _, _ := unstructured.NestedFieldNoCopy(current.Object, "status")
+		if reflect.DeepEqual(existing, status) {
+			return nil
+		}
+
+		updated := current.DeepCopy()
+		updated.Object["status"] = status
+		_, err = client.UpdateStatus(c.runningContext, updated, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Schema of the status written to instances of a template
+func instanceStatusSchema() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"compiled":           {Type: "boolean"},
+			"enforced":           {Type: "boolean"},
+			"lastError":          {Type: "string"},
+			"observedGeneration": {Type: "integer", Format: "int64"},
+		},
+	}
+}
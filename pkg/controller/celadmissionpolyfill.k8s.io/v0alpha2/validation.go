@@ -0,0 +1,144 @@
+package v0alpha2
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/cel-policy-templates-go/policy"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/admission"
+)
+
+// Returned when an instance fails to compile against its template, which
+// includes failing the template's validator. Locations of the issues refer
+// to source.
+type instanceCompileError struct {
+	source []byte
+	issues *policy.Issues
+}
+
+func (e instanceCompileError) Error() string {
+	return e.issues.Err().Error()
+}
+
+// Maps each issue to the field of the instance it was reported on
+func (e instanceCompileError) fieldErrors() field.ErrorList {
+	paths := sourcePaths(e.source)
+
+	var errs field.ErrorList
+	for _, issue := range e.issues.Errors() {
+		path, found := paths[issue.Location.Line()]
+		if !found || path == nil {
+			// Issues without a location apply to the instance as a whole
+			path = field.NewPath("rule")
+		}
+		errs = append(errs, field.Invalid(path, field.OmitValueType{}, issue.Message))
+	}
+	return errs
+}
+
+// Returns the path of the field on each line of source, which must be JSON
+// indented one field or element per line. Lines are numbered from 1.
+func sourcePaths(source []byte) map[int]*field.Path {
+	type container struct {
+		path   *field.Path
+		isList bool
+		next   int
+	}
+
+	paths := map[int]*field.Path{}
+
+	// Objects and lists enclosing the current line, outermost first
+	var stack []*container
+	for i, line := range strings.Split(string(source), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "}") || strings.HasPrefix(trimmed, "]") {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		var path *field.Path
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			if parent.isList {
+				path = parent.path.Index(parent.next)
+				parent.next++
+			} else if key, err := json.NewDecoder(strings.NewReader(trimmed)).Token(); err == nil {
+				if key, ok := key.(string); ok {
+					if parent.path == nil {
+						path = field.NewPath(key)
+					} else {
+						path = parent.path.Child(key)
+					}
+				}
+			}
+		}
+		paths[i+1] = path
+
+		opened := strings.TrimSuffix(trimmed, ",")
+		if strings.HasSuffix(opened, "{") || strings.HasSuffix(opened, "[") {
+			stack = append(stack, &container{path: path, isList: strings.HasSuffix(opened, "[")})
+		}
+	}
+	return paths
+}
+
+// Rejects instances of templates which do not compile, or which fail the
+// template's validator. Returns nil for objects which are not instances of a
+// tracked template.
+func (c *templateController) validateInstance(attrs admission.Attributes) error {
+	switch attrs.GetOperation() {
+	case admission.Create, admission.Update:
+	default:
+		return nil
+	}
+
+	if len(attrs.GetSubresource()) > 0 {
+		return nil
+	}
+
+	obj, err := toUnstructured(attrs.GetObject())
+	if err != nil || obj == nil {
+		return err
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for templateName, info := range c.templates {
+		if info.instanceGVR != attrs.GetResource() {
+			continue
+		}
+
+		instance := &unstructured.Unstructured{Object: obj}
+		compiled := c.compileInstance(info.engine, templateName, instance)
+		if compiled.err == nil {
+			return nil
+		}
+
+		var errs field.ErrorList
+		var fieldErr *field.Error
+		var compileErr instanceCompileError
+		if errors.As(compiled.err, &compileErr) {
+			errs = compileErr.fieldErrors()
+		} else if errors.As(compiled.err, &fieldErr) {
+			errs = append(errs, fieldErr)
+		} else {
+			return compiled.err
+		}
+
+		return k8serrors.NewInvalid(
+			schema.GroupKind{Group: info.instanceGVR.Group, Kind: instance.GetKind()},
+			instance.GetName(),
+			errs,
+		)
+	}
+
+	return nil
+}
@@ -18,6 +18,10 @@ type TransformedClient[T any, TList any, TApplyConfiguration any, R any, RList a
 
 	To   func(*R) (*T, error)
 	From func(*T) (*R, error)
+
+	// Optional. Objects of the replacement client for which Keep returns false
+	// are hidden from lists and watches, as if they had been deleted
+	Keep func(*R) bool
 }
 
 func (c TransformedClient[T, TList, TApplyConfiguration, R, RList, RApplyConfiguration]) Create(ctx context.Context, object *T, opts metav1.CreateOptions) (*T, error) {
@@ -107,14 +111,18 @@ func (c TransformedClient[T, TList, TApplyConfiguration, R, RList, RApplyConfigu
 
 	items := getItems[R](value)
 
-	newItems := make([]T, len(items))
-	for i, v := range items {
+	newItems := make([]T, 0, len(items))
+	for _, v := range items {
+		if c.Keep != nil && !c.Keep(&v) {
+			continue
+		}
+
 		converted, err := c.To(&v)
 		if err != nil {
 			return nil, err
 		}
 
-		newItems[i] = *converted
+		newItems = append(newItems, *converted)
 	}
 
 	return listWithItems[TList](newItems), nil
@@ -128,6 +136,16 @@ func (c TransformedClient[T, TList, TApplyConfiguration, R, RList, RApplyConfigu
 
 	return watch.Filter(watcher, func(in watch.Event) (out watch.Event, keep bool) {
 		if asR, ok := in.Object.(any).(*R); ok {
+			if c.Keep != nil && !c.Keep(asR) {
+				switch in.Type {
+				case watch.Added:
+					return in, false
+				case watch.Modified:
+					// The object may have been kept before
+					in.Type = watch.Deleted
+				}
+			}
+
 			converted, err := c.To(asR)
 			if err != nil {
 				klog.Error(err)
@@ -0,0 +1,150 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var exprParser *parser.Parser
+
+func init() {
+	var err error
+	exprParser, err = parser.NewParser(parser.Macros(parser.AllMacros...))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Rewrites CEL expressions by replacing the identifiers they reference.
+// Identifiers bound by comprehensions are left alone.
+type exprRewriter struct {
+	// Replacement text for each identifier
+	replacements map[string]string
+}
+
+// Returns the rewritten expression, and the free identifiers it referenced
+// before rewriting
+func (r exprRewriter) rewrite(expr string) (string, []string, error) {
+	parsed, errs := exprParser.Parse(common.NewTextSource(expr))
+	if len(errs.GetErrors()) > 0 {
+		return "", nil, fmt.Errorf("failed to parse %q: %s", expr, errs.ToDisplayString())
+	}
+
+	type reference struct {
+		name   string
+		offset int32
+	}
+
+	var references []reference
+	var walk func(e *exprpb.Expr, bound map[string]bool)
+	walk = func(e *exprpb.Expr, bound map[string]bool) {
+		if e == nil {
+			return
+		}
+
+		switch kind := e.ExprKind.(type) {
+		case *exprpb.Expr_IdentExpr:
+			name := kind.IdentExpr.Name
+			if bound[name] {
+				return
+			}
+
+			offset, found := parsed.SourceInfo.Positions[e.Id]
+			if !found {
+				// Introduced by macro expansion
+				return
+			}
+			references = append(references, reference{name: name, offset: offset})
+		case *exprpb.Expr_SelectExpr:
+			walk(kind.SelectExpr.Operand, bound)
+		case *exprpb.Expr_CallExpr:
+			walk(kind.CallExpr.Target, bound)
+			for _, arg := range kind.CallExpr.Args {
+				walk(arg, bound)
+			}
+		case *exprpb.Expr_ListExpr:
+			for _, element := range kind.ListExpr.Elements {
+				walk(element, bound)
+			}
+		case *exprpb.Expr_StructExpr:
+			for _, entry := range kind.StructExpr.Entries {
+				walk(entry.GetMapKey(), bound)
+				walk(entry.Value, bound)
+			}
+		case *exprpb.Expr_ComprehensionExpr:
+			comprehension := kind.ComprehensionExpr
+			walk(comprehension.IterRange, bound)
+			walk(comprehension.AccuInit, bound)
+
+			inner := map[string]bool{
+				comprehension.IterVar: true,
+				comprehension.AccuVar: true,
+			}
+			for name := range bound {
+				inner[name] = true
+			}
+			walk(comprehension.LoopCondition, inner)
+			walk(comprehension.LoopStep, inner)
+			walk(comprehension.Result, inner)
+		}
+	}
+	walk(parsed.Expr, map[string]bool{})
+
+	// Replace from the end so earlier offsets stay valid
+	sort.Slice(references, func(i, j int) bool {
+		return references[i].offset > references[j].offset
+	})
+
+	runes := []rune(expr)
+	seen := map[string]bool{}
+	var idents []string
+	for _, ref := range references {
+		if !seen[ref.name] {
+			seen[ref.name] = true
+			idents = append(idents, ref.name)
+		}
+
+		replacement, found := r.replacements[ref.name]
+		if !found {
+			continue
+		}
+
+		start := int(ref.offset)
+		end := start + len([]rune(ref.name))
+		if end > len(runes) || string(runes[start:end]) != ref.name {
+			return "", nil, fmt.Errorf("failed to locate %s in %q", ref.name, expr)
+		}
+
+		rewritten := make([]rune, 0, len(runes)+len(replacement))
+		rewritten = append(rewritten, runes[:start]...)
+		rewritten = append(rewritten, []rune(replacement)...)
+		rewritten = append(rewritten, runes[end:]...)
+		runes = rewritten
+	}
+
+	sort.Strings(idents)
+	return strings.TrimSpace(string(runes)), idents, nil
+}
+
+// Returns true if text parses as a CEL expression which only references the
+// given identifiers. Output values of template productions are otherwise
+// treated as plain text.
+func isExpression(text string, known sets.String) bool {
+	_, idents, err := exprRewriter{}.rewrite(text)
+	if err != nil {
+		return false
+	}
+
+	for _, ident := range idents {
+		if !known.Has(ident) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Variables available to ValidationRuleSet rules
+const (
+	selfVar    = "self"
+	oldSelfVar = "oldSelf"
+	paramsVar  = "params"
+)
+
+// The expression and message of a rule before and after translation
+type Rewrite struct {
+	Rule string
+
+	Original        string
+	OriginalMessage string
+
+	// Empty if the rule could not be translated
+	Expression string
+	Message    string
+}
+
+type ValidationRuleSetResult struct {
+	// Name of the rule set, qualified by its namespace
+	Source string
+
+	Policy *v1alpha1.ValidatingAdmissionPolicy
+
+	// Nil if the rule set applies to nothing
+	Binding *v1alpha1.ValidatingAdmissionPolicyBinding
+
+	// One for each rule of the rule set, in order
+	Rewrites []Rewrite
+
+	// Constructs of the rule set which were dropped or which behave
+	// differently once translated
+	Issues []Issue
+}
+
+func (r *ValidationRuleSetResult) report(err *field.Error) {
+	r.Issues = append(r.Issues, Issue{Object: "ValidationRuleSet " + r.Source, Err: err})
+}
+
+// Shows each rule alongside its translation in the style of a unified diff,
+// so a migration can be reviewed without applying it
+func (r *ValidationRuleSetResult) Diff() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- ValidationRuleSet %s\n", r.Source)
+	fmt.Fprintf(&b, "+++ ValidatingAdmissionPolicy %s\n", r.Policy.Name)
+
+	line := func(prefix, text string) {
+		for _, l := range strings.Split(text, "\n") {
+			b.WriteString(prefix + l + "\n")
+		}
+	}
+
+	pair := func(before, after string) {
+		switch {
+		case before == after:
+			line(" ", before)
+		case len(after) == 0:
+			line("-", before)
+		default:
+			line("-", before)
+			line("+", after)
+		}
+	}
+
+	for _, rewrite := range r.Rewrites {
+		fmt.Fprintf(&b, "@@ rule %s @@\n", rewrite.Rule)
+		pair(rewrite.Original, rewrite.Expression)
+		if len(rewrite.Expression) == 0 {
+			line("-", rewrite.OriginalMessage)
+		} else {
+			pair(rewrite.OriginalMessage, rewrite.Message)
+		}
+	}
+	return b.String()
+}
+
+// Translates a ValidationRuleSet into a ValidatingAdmissionPolicy and a
+// binding which applies it to the resources the rule set matches.
+//
+// self and oldSelf become object and oldObject. Rules which reference
+// oldSelf only hold once there is an old object, as in the rule set, and
+// rules without a message keep the message the rule set would have used.
+func ValidationRuleSet(ruleSet *v0alpha1.ValidationRuleSet) *ValidationRuleSetResult {
+	result := &ValidationRuleSetResult{Source: ruleSet.Name}
+	name := ruleSet.Name
+	if len(ruleSet.Namespace) > 0 {
+		result.Source = ruleSet.Namespace + "/" + ruleSet.Name
+		name = ruleSet.Namespace + "-" + ruleSet.Name
+	}
+
+	admissionEnv := ruleSet.Spec.Environment == v0alpha1.AdmissionEnvironment
+	specPath := field.NewPath("spec")
+
+	known := sets.NewString(selfVar, oldSelfVar)
+	if admissionEnv {
+		known.Insert(requestVar, paramsVar, namespaceObjectVar)
+	}
+
+	rewriter := exprRewriter{replacements: map[string]string{
+		selfVar:    objectVar,
+		oldSelfVar: oldObjectVar,
+	}}
+
+	var validations []v1alpha1.Validation
+	for i, rule := range ruleSet.Spec.Rules {
+		path := specPath.Child("rules").Index(i).Child("rule")
+
+		message := rule.Message
+		if len(message) == 0 {
+			message = fmt.Sprintf("failed rule: %s", rule.Rule)
+		}
+
+		rewrite := Rewrite{
+			Rule:            rule.Name,
+			Original:        rule.Rule,
+			OriginalMessage: rule.Message,
+		}
+
+		expression, idents, err := rewriter.rewrite(rule.Rule)
+		if err != nil {
+			result.report(field.Invalid(path, rule.Rule, err.Error()))
+			result.Rewrites = append(result.Rewrites, rewrite)
+			continue
+		}
+
+		var unsupported []string
+		for _, ident := range idents {
+			if ident == namespaceObjectVar && admissionEnv {
+				result.report(field.Invalid(path, rule.Rule, fmt.Sprintf("%s has no equivalent", namespaceObjectVar)))
+				unsupported = append(unsupported, ident)
+			} else if !known.Has(ident) {
+				result.report(field.Invalid(path, rule.Rule, fmt.Sprintf("%s is not defined", ident)))
+				unsupported = append(unsupported, ident)
+			}
+		}
+		if len(unsupported) > 0 {
+			result.Rewrites = append(result.Rewrites, rewrite)
+			continue
+		}
+
+		if sets.NewString(idents...).Has(oldSelfVar) {
+			// Transition rules are skipped when there is no old object
+			expression = fmt.Sprintf("%s == null || (%s)", oldObjectVar, expression)
+		}
+
+		rewrite.Expression = expression
+		rewrite.Message = message
+		result.Rewrites = append(result.Rewrites, rewrite)
+
+		validations = append(validations, v1alpha1.Validation{
+			Expression: expression,
+			Message:    message,
+		})
+	}
+
+	var paramKind *v1alpha1.ParamKind
+	var paramRef *v1alpha1.ParamRef
+	if ref := ruleSet.Spec.ParamRef; ref != nil && admissionEnv {
+		paramKind = &v1alpha1.ParamKind{
+			APIVersion: ref.APIVersion,
+			Kind:       ref.Kind,
+		}
+		paramRef = &v1alpha1.ParamRef{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		}
+	}
+
+	matchPath := specPath.Child("match")
+	if len(ruleSet.Spec.Match) > 1 {
+		result.report(field.Invalid(matchPath, len(ruleSet.Spec.Match), "rule sets apply when all match entries match, policies apply when any does"))
+	}
+
+	var resourceRules []v1alpha1.NamedRuleWithOperations
+	for i, match := range ruleSet.Spec.Match {
+		path := matchPath.Index(i)
+
+		// Only wildcard operations and scopes are enforced by rule sets
+		if len(match.Operations) != 1 || match.Operations[0] != admissionregistrationv1.OperationAll {
+			result.report(field.Invalid(path.Child("operations"), match.Operations, "rule sets only apply to all operations, so the rule set is not enforced"))
+		}
+		if match.Scope == nil || *match.Scope != admissionregistrationv1.AllScopes {
+			result.report(field.Invalid(path.Child("scope"), match.Scope, "rule sets only apply to all scopes, so the rule set is not enforced"))
+		}
+
+		rule := *match.DeepCopy()
+		if !admissionEnv && len(rule.Operations) == 1 && rule.Operations[0] == admissionregistrationv1.OperationAll {
+			// Schema rules do not run on deletes, which have no object
+			rule.Operations = []v1alpha1.OperationType{v1alpha1.Create, v1alpha1.Update}
+		}
+		resourceRules = append(resourceRules, v1alpha1.NamedRuleWithOperations{RuleWithOperations: rule})
+	}
+
+	result.Policy = &v1alpha1.ValidatingAdmissionPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingAdmissionPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1alpha1.ValidatingAdmissionPolicySpec{
+			ParamKind: paramKind,
+			MatchConstraints: &v1alpha1.MatchResources{
+				ResourceRules: resourceRules,
+			},
+			Validations: validations,
+		},
+	}
+
+	if len(resourceRules) == 0 {
+		result.report(field.Required(matchPath, "rule sets without match entries apply to nothing, so the policy is not bound"))
+		return result
+	}
+
+	result.Binding = &v1alpha1.ValidatingAdmissionPolicyBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingAdmissionPolicyBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        name,
+			ParamRef:          paramRef,
+			ValidationActions: []v1alpha1.ValidationAction{v1alpha1.Deny},
+		},
+	}
+
+	return result
+}
@@ -0,0 +1,184 @@
+package convert_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/convert"
+	"github.com/google/go-cmp/cmp"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+)
+
+func TestValidationRuleSet(t *testing.T) {
+	ruleSet := &v0alpha1.ValidationRuleSet{}
+	decodeFile(t, "testdata/rulesets/schema_rules.yaml", ruleSet)
+
+	result := convert.ValidationRuleSet(ruleSet)
+	if len(result.Issues) > 0 {
+		t.Fatalf("unexpected issues: %v", result.Issues)
+	}
+
+	expectedValidations := []v1alpha1.Validation{
+		{
+			Expression: "object.metadata.name.startsWith('test')",
+			Message:    "name should be a test",
+		},
+		{
+			Expression: "object.spec.discriminator == 'mode1' || object.spec.discriminator == 'mode2'",
+			Message:    "discriminator is either 'mode1' or 'mode2'",
+		},
+		{
+			Expression: "object.spec.discriminator == 'mode1' || (has(object.spec.mode2) && object.spec.mode2 == object.spec.value)",
+			Message:    "if discriminator is mode2, mode2 and value must be equal",
+		},
+		{
+			Expression: "object.spec.discriminator == 'mode2' || (has(object.spec.mode1) && object.spec.mode1 == object.spec.value)",
+			Message:    "if discriminator is mode1, mode1 and value must be equal",
+		},
+	}
+	if !reflect.DeepEqual(expectedValidations, result.Policy.Spec.Validations) {
+		t.Fatalf("%s", cmp.Diff(expectedValidations, result.Policy.Spec.Validations))
+	}
+	checkCompiles(t, result.Policy)
+
+	// Schema rules never ran on deletes
+	allScopes := admissionregistrationv1.AllScopes
+	expectedRules := []v1alpha1.NamedRuleWithOperations{
+		{
+			RuleWithOperations: v1alpha1.RuleWithOperations{
+				Operations: []v1alpha1.OperationType{v1alpha1.Create, v1alpha1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"stable.example.com"},
+					APIVersions: []string{"*"},
+					Resources:   []string{"basicunions"},
+					Scope:       &allScopes,
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expectedRules, result.Policy.Spec.MatchConstraints.ResourceRules) {
+		t.Fatalf("%s", cmp.Diff(expectedRules, result.Policy.Spec.MatchConstraints.ResourceRules))
+	}
+
+	if result.Policy.Spec.ParamKind != nil {
+		t.Fatalf("unexpected param kind: %v", result.Policy.Spec.ParamKind)
+	}
+
+	expectedBinding := v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+		PolicyName:        "testrules",
+		ValidationActions: []v1alpha1.ValidationAction{v1alpha1.Deny},
+	}
+	if result.Binding == nil || !reflect.DeepEqual(expectedBinding, result.Binding.Spec) {
+		t.Fatalf("unexpected binding: %v", result.Binding)
+	}
+}
+
+func TestValidationRuleSetAdmission(t *testing.T) {
+	ruleSet := &v0alpha1.ValidationRuleSet{}
+	decodeFile(t, "testdata/rulesets/admission_environment_rules.yaml", ruleSet)
+
+	result := convert.ValidationRuleSet(ruleSet)
+	checkCompiles(t, result.Policy)
+
+	if result.Policy.Name != "default-testrules-admission" {
+		t.Fatalf("unexpected policy name: %s", result.Policy.Name)
+	}
+
+	expectedParamKind := &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	if !reflect.DeepEqual(expectedParamKind, result.Policy.Spec.ParamKind) {
+		t.Fatalf("%s", cmp.Diff(expectedParamKind, result.Policy.Spec.ParamKind))
+	}
+
+	expectedParamRef := &v1alpha1.ParamRef{Name: "allowed-env", Namespace: "default"}
+	if !reflect.DeepEqual(expectedParamRef, result.Binding.Spec.ParamRef) {
+		t.Fatalf("%s", cmp.Diff(expectedParamRef, result.Binding.Spec.ParamRef))
+	}
+
+	// Admission rules also ran on deletes
+	expectedOperations := []v1alpha1.OperationType{v1alpha1.OperationAll}
+	if operations := result.Policy.Spec.MatchConstraints.ResourceRules[0].Operations; !reflect.DeepEqual(expectedOperations, operations) {
+		t.Fatalf("%s", cmp.Diff(expectedOperations, operations))
+	}
+
+	if len(result.Issues) != 1 || result.Issues[0].String() != `ValidationRuleSet default/testrules-admission: spec.rules[1].rule: Invalid value: "namespaceObject.metadata.labels['env'] == params.data.env": namespaceObject has no equivalent` {
+		t.Fatalf("unexpected issues: %v", result.Issues)
+	}
+
+	expectedDiff := `--- ValidationRuleSet default/testrules-admission
++++ ValidatingAdmissionPolicy default-testrules-admission
+@@ rule user_rule @@
+ request.userInfo.username != 'mallory'
+ user is not allowed to create basicunions
+@@ rule namespace_rule @@
+-namespaceObject.metadata.labels['env'] == params.data.env
+-namespace env label must match params
+@@ rule transition_rule @@
+-self.spec.value == oldSelf.spec.value
++oldObject == null || (object.spec.value == oldObject.spec.value)
+ value is immutable
+`
+	if diff := result.Diff(); diff != expectedDiff {
+		t.Fatalf("%s", cmp.Diff(expectedDiff, diff))
+	}
+}
+
+func TestValidationRuleSetMatch(t *testing.T) {
+	// Rule sets without a message report the rule, and only apply when every
+	// match entry matches
+	ruleSet := &v0alpha1.ValidationRuleSet{
+		Spec: v0alpha1.ValidationRuleSetSpec{
+			Rules: []v0alpha1.ValidationRule{
+				{Name: "replicas", Rule: "self.spec.replicas < 10"},
+			},
+			Match: []admissionregistrationv1.RuleWithOperations{
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{"apps"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"deployments"},
+					},
+				},
+				{
+					Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.OperationAll},
+					Rule: admissionregistrationv1.Rule{
+						APIGroups:   []string{"apps"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"statefulsets"},
+					},
+				},
+			},
+		},
+	}
+	ruleSet.Name = "replicas"
+
+	result := convert.ValidationRuleSet(ruleSet)
+
+	expectedMessage := "failed rule: self.spec.replicas < 10"
+	if message := result.Policy.Spec.Validations[0].Message; message != expectedMessage {
+		t.Fatalf("unexpected message: %s", message)
+	}
+
+	var issues []string
+	for _, issue := range result.Issues {
+		issues = append(issues, issue.String())
+	}
+	expectedIssues := []string{
+		"ValidationRuleSet replicas: spec.match: Invalid value: 2: rule sets apply when all match entries match, policies apply when any does",
+		`ValidationRuleSet replicas: spec.match[0].operations: Invalid value: []v1.OperationType{"CREATE"}: rule sets only apply to all operations, so the rule set is not enforced`,
+		"ValidationRuleSet replicas: spec.match[0].scope: Invalid value: \"null\": rule sets only apply to all scopes, so the rule set is not enforced",
+		"ValidationRuleSet replicas: spec.match[1].scope: Invalid value: \"null\": rule sets only apply to all scopes, so the rule set is not enforced",
+	}
+	if !reflect.DeepEqual(expectedIssues, issues) {
+		t.Fatalf("%s", cmp.Diff(expectedIssues, issues))
+	}
+
+	// Without match entries the rule set applies to nothing
+	ruleSet.Spec.Match = nil
+	result = convert.ValidationRuleSet(ruleSet)
+	if result.Binding != nil {
+		t.Fatalf("unexpected binding: %v", result.Binding)
+	}
+}
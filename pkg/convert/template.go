@@ -0,0 +1,433 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	controllerv0alpha2 "github.com/alexzielenski/cel_polyfill/pkg/controller/celadmissionpolyfill.k8s.io/v0alpha2"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Variables available to PolicyTemplate evaluators
+const (
+	ruleVar            = "rule"
+	resourceVar        = "resource"
+	objectVar          = "object"
+	oldObjectVar       = "oldObject"
+	requestVar         = "request"
+	namespaceObjectVar = "namespaceObject"
+)
+
+// Decisions which are reported as warnings rather than denying the request
+const warningDecision = "policy.warning"
+
+// Iterates the rules of an instance, which may be given either as `rule` or
+// `rules`. Instances are the params of the converted policy
+const instanceRulesExpr = "(has(params.rule) ? [params.rule] : []) + (has(params.rules) ? params.rules : [])"
+
+type Options struct {
+	// Maps the kinds in instance match blocks to resources. If nil, instances
+	// which match on kinds are reported as untranslatable
+	RESTMapper meta.RESTMapper
+}
+
+// A construct which could not be translated
+type Issue struct {
+	// Kind and name of the object containing the construct
+	Object string
+	Err    *field.Error
+}
+
+func (i Issue) String() string {
+	return i.Object + ": " + i.Err.Error()
+}
+
+type PolicyTemplateResult struct {
+	Policy   *v1alpha1.ValidatingAdmissionPolicy
+	Bindings []*v1alpha1.ValidatingAdmissionPolicyBinding
+
+	// Constructs of the template and its instances which were dropped
+	Issues []Issue
+}
+
+func (r *PolicyTemplateResult) report(object string, err *field.Error) {
+	r.Issues = append(r.Issues, Issue{Object: object, Err: err})
+}
+
+// Translates a PolicyTemplate into a ValidatingAdmissionPolicy whose params
+// are instances of the template, and each instance into a binding of the
+// policy.
+//
+// Productions become validations which hold for an instance while none of
+// its rules match. Terms are inlined since policies have no equivalent.
+// Policies apply to creates and updates of every resource, with instance
+// match blocks narrowing the resources each binding applies to.
+func PolicyTemplate(
+	template *v0alpha2.PolicyTemplate,
+	instances []*unstructured.Unstructured,
+	options Options,
+) (*PolicyTemplateResult, error) {
+	result := &PolicyTemplateResult{}
+	templateObject := "PolicyTemplate " + template.Name
+	evaluatorPath := field.NewPath("spec", "evaluator")
+
+	if len(template.Evaluator.Environment) > 0 {
+		result.report(templateObject, field.Forbidden(evaluatorPath.Child("environment"), "custom environments have no equivalent"))
+	}
+
+	if len(template.Evaluator.Ranges) > 0 {
+		result.report(templateObject, field.Forbidden(evaluatorPath.Child("ranges"), "ranges have no equivalent"))
+	}
+
+	if template.Validator != nil {
+		result.report(templateObject, field.Forbidden(field.NewPath("spec", "validator"), "instances are not validated by ValidatingAdmissionPolicy"))
+	}
+
+	rewriter := exprRewriter{replacements: map[string]string{
+		resourceVar: objectVar,
+	}}
+
+	// Names of terms which may be referenced as expressions in outputs
+	known := sets.NewString(objectVar, oldObjectVar, requestVar, resourceVar, namespaceObjectVar, ruleVar)
+	for i, term := range template.Evaluator.Terms {
+		known.Insert(term.Name)
+
+		rewritten, _, err := rewriter.rewrite(term.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", evaluatorPath.Child("terms").Index(i).Child("value"), err)
+		}
+		rewriter.replacements[term.Name] = "(" + rewritten + ")"
+	}
+
+	// Each policy has a single set of validation actions. Warnings are only
+	// kept if nothing denies
+	warnOnly := len(template.Evaluator.Productions) > 0
+	for _, production := range template.Evaluator.Productions {
+		if production.Decision != warningDecision {
+			warnOnly = false
+		}
+	}
+
+	hasRules := !reflect.DeepEqual(template.Schema, apiextensionsv1.JSONSchemaProps{})
+
+	var validations []v1alpha1.Validation
+	for i, production := range template.Evaluator.Productions {
+		path := evaluatorPath.Child("productions").Index(i)
+
+		if len(production.Decisions) > 0 || len(production.DecisionRef) > 0 {
+			result.report(templateObject, field.Forbidden(path, "only productions with a single decision can be translated"))
+			continue
+		}
+
+		if production.Decision == warningDecision && !warnOnly {
+			result.report(templateObject, field.Invalid(path.Child("decision"), production.Decision, "translated as a denial since the template also denies"))
+		}
+
+		match := production.Match
+		if len(strings.TrimSpace(match)) == 0 {
+			match = "true"
+		}
+
+		expression, err := rewriteForPolicy(rewriter, match)
+		if err != nil {
+			result.report(templateObject, field.Invalid(path.Child("match"), production.Match, err.Error()))
+			continue
+		}
+
+		validation := v1alpha1.Validation{}
+		if hasRules {
+			validation.Expression = fmt.Sprintf("(%s).all(%s, !(%s))", instanceRulesExpr, ruleVar, expression)
+		} else {
+			validation.Expression = fmt.Sprintf("!(%s)", expression)
+		}
+
+		message, issues := outputMessage(production.Output, path.Child("output"))
+		for _, issue := range issues {
+			result.report(templateObject, issue)
+		}
+
+		switch {
+		case len(message) == 0:
+			// The default message of the policy is used
+		case !isExpression(message, known):
+			validation.Message = message
+		default:
+			messageExpression, err := rewriteForPolicy(rewriter, message)
+			if err != nil {
+				result.report(templateObject, field.Invalid(path.Child("output", "message"), message, err.Error()))
+			} else if usesRule(messageExpression) {
+				result.report(templateObject, field.Invalid(path.Child("output", "message"), message, "messages which depend on the rule cannot be translated"))
+			} else {
+				validation.MessageExpression = messageExpression
+			}
+		}
+
+		validations = append(validations, validation)
+	}
+
+	instanceGVK := controllerv0alpha2.InstanceGroupVersionKind(template)
+	result.Policy = &v1alpha1.ValidatingAdmissionPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ValidatingAdmissionPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: template.Name,
+		},
+		Spec: v1alpha1.ValidatingAdmissionPolicySpec{
+			ParamKind: &v1alpha1.ParamKind{
+				APIVersion: instanceGVK.GroupVersion().String(),
+				Kind:       instanceGVK.Kind,
+			},
+			MatchConstraints: &v1alpha1.MatchResources{
+				ResourceRules: []v1alpha1.NamedRuleWithOperations{
+					resourceRule([]string{"*"}, []string{"*"}),
+				},
+			},
+			Validations: validations,
+		},
+	}
+
+	actions := []v1alpha1.ValidationAction{v1alpha1.Deny}
+	if warnOnly {
+		actions = []v1alpha1.ValidationAction{v1alpha1.Warn}
+	}
+
+	sorted := append([]*unstructured.Unstructured{}, instances...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].GetNamespace() != sorted[j].GetNamespace() {
+			return sorted[i].GetNamespace() < sorted[j].GetNamespace()
+		}
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	for _, instance := range sorted {
+		name := template.Name + "-" + instance.GetName()
+		instanceObject := instanceGVK.Kind + " " + instance.GetName()
+		if len(instance.GetNamespace()) > 0 {
+			name = template.Name + "-" + instance.GetNamespace() + "-" + instance.GetName()
+			instanceObject = instanceGVK.Kind + " " + instance.GetNamespace() + "/" + instance.GetName()
+		}
+
+		matchResources, issues := bindingMatchResources(instance, options.RESTMapper)
+		if len(issues) > 0 {
+			// A binding which matches more than the instance is worse than
+			// no binding
+			for _, issue := range issues {
+				result.report(instanceObject, issue)
+			}
+			continue
+		}
+
+		result.Bindings = append(result.Bindings, &v1alpha1.ValidatingAdmissionPolicyBinding{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: v1alpha1.SchemeGroupVersion.String(),
+				Kind:       "ValidatingAdmissionPolicyBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+			Spec: v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName: template.Name,
+				ParamRef: &v1alpha1.ParamRef{
+					Name:      instance.GetName(),
+					Namespace: instance.GetNamespace(),
+				},
+				MatchResources:    matchResources,
+				ValidationActions: actions,
+			},
+		})
+	}
+
+	return result, nil
+}
+
+// Rewrites an expression of a template into one of a policy, failing if it
+// references variables policies do not have
+func rewriteForPolicy(rewriter exprRewriter, expr string) (string, error) {
+	rewritten, _, err := rewriter.rewrite(expr)
+	if err != nil {
+		return "", err
+	}
+
+	// Terms have been inlined, so these are the variables the expression
+	// ends up using
+	_, idents, err := exprRewriter{}.rewrite(rewritten)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ident := range idents {
+		switch ident {
+		case objectVar, oldObjectVar, requestVar, ruleVar:
+		case namespaceObjectVar:
+			return "", fmt.Errorf("%s has no equivalent", namespaceObjectVar)
+		default:
+			return "", fmt.Errorf("%s is not defined", ident)
+		}
+	}
+	return rewritten, nil
+}
+
+func usesRule(expr string) bool {
+	_, idents, err := exprRewriter{}.rewrite(expr)
+	return err == nil && sets.NewString(idents...).Has(ruleVar)
+}
+
+// Returns the message of a production's output. Other fields of the output
+// are reported, since validations only have a message
+func outputMessage(output runtime.RawExtension, path *field.Path) (string, field.ErrorList) {
+	if len(output.Raw) == 0 {
+		return "", nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(output.Raw, &decoded); err != nil {
+		return "", field.ErrorList{field.Invalid(path, string(output.Raw), err.Error())}
+	}
+
+	switch decoded := decoded.(type) {
+	case string:
+		return decoded, nil
+	case map[string]interface{}:
+		var errs field.ErrorList
+		for _, key := range sets.StringKeySet(decoded).List() {
+			if key != "message" {
+				errs = append(errs, field.Forbidden(path.Child(key), "only the message of outputs is translated"))
+			}
+		}
+
+		message, ok := decoded["message"].(string)
+		if _, found := decoded["message"]; found && !ok {
+			errs = append(errs, field.Invalid(path.Child("message"), decoded["message"], "only string messages are translated"))
+		}
+		return message, errs
+	default:
+		return "", field.ErrorList{field.Invalid(path, string(output.Raw), "only string or object outputs are translated")}
+	}
+}
+
+// Builds the match resources of the binding for an instance from its match
+// block
+func bindingMatchResources(instance *unstructured.Unstructured, mapper meta.RESTMapper) (*v1alpha1.MatchResources, field.ErrorList) {
+	matchPath := field.NewPath("match")
+
+	raw, found, err := unstructured.NestedMap(instance.Object, "match")
+	if err != nil {
+		return nil, field.ErrorList{field.Invalid(matchPath, nil, err.Error())}
+	} else if !found {
+		return nil, nil
+	}
+
+	var match v0alpha2.Match
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &match); err != nil {
+		return nil, field.ErrorList{field.Invalid(matchPath, raw, err.Error())}
+	}
+
+	result := &v1alpha1.MatchResources{
+		ObjectSelector: match.LabelSelector,
+	}
+
+	if match.NamespaceSelector != nil || len(match.Namespaces) > 0 || len(match.ExcludedNamespaces) > 0 {
+		selector := &metav1.LabelSelector{}
+		if match.NamespaceSelector != nil {
+			selector = match.NamespaceSelector.DeepCopy()
+		}
+
+		if len(match.Namespaces) > 0 {
+			selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      corev1.LabelMetadataName,
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   match.Namespaces,
+			})
+		}
+
+		if len(match.ExcludedNamespaces) > 0 {
+			selector.MatchExpressions = append(selector.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      corev1.LabelMetadataName,
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   match.ExcludedNamespaces,
+			})
+		}
+		result.NamespaceSelector = selector
+	}
+
+	var errs field.ErrorList
+	for i, kinds := range match.Kinds {
+		path := matchPath.Child("kinds").Index(i)
+
+		groups := kinds.APIGroups
+		if len(groups) == 0 {
+			groups = []string{"*"}
+		}
+
+		if len(kinds.Kinds) == 0 || sets.NewString(kinds.Kinds...).Has("*") {
+			result.ResourceRules = append(result.ResourceRules, resourceRule(groups, []string{"*"}))
+			continue
+		}
+
+		for _, group := range groups {
+			if group == "*" {
+				errs = append(errs, field.Invalid(path.Child("apiGroups"), group, "kinds can only be translated within a specific group"))
+				continue
+			} else if mapper == nil {
+				errs = append(errs, field.Forbidden(path.Child("kinds"), "kinds can only be translated with a RESTMapper"))
+				continue
+			}
+
+			resources := sets.NewString()
+			for _, kind := range kinds.Kinds {
+				mappings, err := mapper.RESTMappings(schema.GroupKind{Group: group, Kind: kind})
+				if err != nil {
+					errs = append(errs, field.Invalid(path.Child("kinds"), kind, err.Error()))
+					continue
+				}
+
+				for _, mapping := range mappings {
+					resources.Insert(mapping.Resource.Resource)
+				}
+			}
+
+			if resources.Len() > 0 {
+				result.ResourceRules = append(result.ResourceRules, resourceRule([]string{group}, resources.List()))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if reflect.DeepEqual(*result, v1alpha1.MatchResources{}) {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// Rule matching creates and updates of the given resources
+func resourceRule(groups, resources []string) v1alpha1.NamedRuleWithOperations {
+	return v1alpha1.NamedRuleWithOperations{
+		RuleWithOperations: v1alpha1.RuleWithOperations{
+			Operations: []v1alpha1.OperationType{v1alpha1.Create, v1alpha1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   groups,
+				APIVersions: []string{"*"},
+				Resources:   resources,
+			},
+		},
+	}
+}
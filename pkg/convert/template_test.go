@@ -0,0 +1,244 @@
+package convert_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	"github.com/alexzielenski/cel_polyfill/pkg/convert"
+	"github.com/google/cel-go/cel"
+	"github.com/google/go-cmp/cmp"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func decodeFile(t *testing.T, path string, into interface{}) {
+	t.Helper()
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(file), 24).Decode(into); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Checks the expressions of the policy compile with the variables available
+// to ValidatingAdmissionPolicy
+func checkCompiles(t *testing.T, policy *v1alpha1.ValidatingAdmissionPolicy) {
+	t.Helper()
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("request", cel.DynType),
+		cel.Variable("params", cel.DynType),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, validation := range policy.Spec.Validations {
+		for _, expr := range []string{validation.Expression, validation.MessageExpression} {
+			if len(expr) == 0 {
+				continue
+			}
+			if _, iss := env.Compile(expr); iss.Err() != nil {
+				t.Fatalf("%s: %v", expr, iss.Err())
+			}
+		}
+	}
+}
+
+func TestPolicyTemplate(t *testing.T) {
+	template := &v0alpha2.PolicyTemplate{}
+	decodeFile(t, "testdata/required_labels/policy.yaml", template)
+
+	instance := &unstructured.Unstructured{}
+	decodeFile(t, "testdata/required_labels/instance.yaml", instance)
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+
+	result, err := convert.PolicyTemplate(template, []*unstructured.Unstructured{instance}, convert.Options{RESTMapper: mapper})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := "(has(params.rule) ? [params.rule] : []) + (has(params.rules) ? params.rules : [])"
+	expectedValidations := []v1alpha1.Validation{
+		{
+			Expression: "(" + rules + ").all(rule, !(!has(object.metadata.labels)))",
+			Message:    "missing labels field",
+		},
+		{
+			Expression: "(" + rules + ").all(rule, !(has(object.metadata.labels) && ((rule.labels).filter(l, !(l in object.metadata.labels))).size() > 0))",
+			Message:    "missing one or more required labels",
+		},
+		{
+			Expression: "(" + rules + ").all(rule, !(has(object.metadata.labels) && (object.metadata.labels.filter(l,\n  l in (rule.labels) && (rule.labels)[l] != object.metadata.labels[l])).size() > 0))",
+			Message:    "invalid values provided on one or more labels",
+		},
+	}
+	if !reflect.DeepEqual(expectedValidations, result.Policy.Spec.Validations) {
+		t.Fatalf("%s", cmp.Diff(expectedValidations, result.Policy.Spec.Validations))
+	}
+	checkCompiles(t, result.Policy)
+
+	expectedParamKind := &v1alpha1.ParamKind{APIVersion: "policy.acme.co/v1", Kind: "requiredlabels"}
+	if !reflect.DeepEqual(expectedParamKind, result.Policy.Spec.ParamKind) {
+		t.Fatalf("%s", cmp.Diff(expectedParamKind, result.Policy.Spec.ParamKind))
+	}
+
+	// Details of outputs are dropped
+	var issues []string
+	for _, issue := range result.Issues {
+		issues = append(issues, issue.String())
+	}
+	expectedIssues := []string{
+		"PolicyTemplate requiredlabels: spec.evaluator.productions[1].output.details: Forbidden: only the message of outputs is translated",
+		"PolicyTemplate requiredlabels: spec.evaluator.productions[2].output.details: Forbidden: only the message of outputs is translated",
+	}
+	if !reflect.DeepEqual(expectedIssues, issues) {
+		t.Fatalf("%s", cmp.Diff(expectedIssues, issues))
+	}
+
+	expectedBindings := []*v1alpha1.ValidatingAdmissionPolicyBinding{
+		{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "admissionregistration.polyfill.sigs.k8s.io/v1alpha1",
+				Kind:       "ValidatingAdmissionPolicyBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "requiredlabels-default-prod-labels",
+			},
+			Spec: v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+				PolicyName: "requiredlabels",
+				ParamRef: &v1alpha1.ParamRef{
+					Name:      "prod-labels",
+					Namespace: "default",
+				},
+				MatchResources: &v1alpha1.MatchResources{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchExpressions: []metav1.LabelSelectorRequirement{
+							{
+								Key:      "kubernetes.io/metadata.name",
+								Operator: metav1.LabelSelectorOpNotIn,
+								Values:   []string{"kube-system"},
+							},
+						},
+					},
+					ObjectSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"tier": "frontend"},
+					},
+					ResourceRules: []v1alpha1.NamedRuleWithOperations{
+						{
+							RuleWithOperations: v1alpha1.RuleWithOperations{
+								Operations: []v1alpha1.OperationType{v1alpha1.Create, v1alpha1.Update},
+								Rule: admissionregistrationv1.Rule{
+									APIGroups:   []string{"apps"},
+									APIVersions: []string{"*"},
+									Resources:   []string{"deployments"},
+								},
+							},
+						},
+					},
+				},
+				ValidationActions: []v1alpha1.ValidationAction{v1alpha1.Deny},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expectedBindings, result.Bindings) {
+		t.Fatalf("%s", cmp.Diff(expectedBindings, result.Bindings))
+	}
+
+	// Kinds cannot be translated without a RESTMapper. The instance is not
+	// bound rather than bound too broadly
+	result, err = convert.PolicyTemplate(template, []*unstructured.Unstructured{instance}, convert.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Bindings) != 0 {
+		t.Fatalf("expected no bindings: %v", result.Bindings)
+	}
+
+	lastIssue := result.Issues[len(result.Issues)-1].String()
+	if lastIssue != "requiredlabels default/prod-labels: match.kinds[0].kinds: Forbidden: kinds can only be translated with a RESTMapper" {
+		t.Fatalf("unexpected issue: %s", lastIssue)
+	}
+}
+
+func TestPolicyTemplateUntranslatable(t *testing.T) {
+	template := &v0alpha2.PolicyTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "celadmissionpolyfill.k8s.io/v0alpha2",
+			Kind:       "PolicyTemplate",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "frozen",
+		},
+		PolicyTemplateSpec: v0alpha2.PolicyTemplateSpec{
+			Evaluator: v0alpha2.Evaluator{
+				Terms: v0alpha2.TermMap{
+					{Name: "frozen", Value: "namespaceObject.metadata.labels.frozen == 'true'"},
+				},
+				Productions: []v0alpha2.Production{
+					{
+						Match:    "frozen",
+						Decision: "policy.warning",
+						Output:   runtime.RawExtension{Raw: []byte(`{"message": "namespace is frozen"}`)},
+					},
+					{
+						Match:    "has(resource.metadata.annotations)",
+						Decision: "policy.warning",
+						Output:   runtime.RawExtension{Raw: []byte(`{"message": "'object ' + object.metadata.name + ' has annotations'"}`)},
+					},
+				},
+			},
+		},
+	}
+
+	instance := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "policy.acme.co/v1",
+		"kind":       "frozen",
+		"metadata": map[string]interface{}{
+			"name": "frozen-namespaces",
+		},
+	}}
+
+	result, err := convert.PolicyTemplate(template, []*unstructured.Unstructured{instance}, convert.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Templates without a schema have no rules to iterate
+	expectedValidations := []v1alpha1.Validation{
+		{
+			Expression:        "!(has(object.metadata.annotations))",
+			MessageExpression: "'object ' + object.metadata.name + ' has annotations'",
+		},
+	}
+	if !reflect.DeepEqual(expectedValidations, result.Policy.Spec.Validations) {
+		t.Fatalf("%s", cmp.Diff(expectedValidations, result.Policy.Spec.Validations))
+	}
+	checkCompiles(t, result.Policy)
+
+	if len(result.Issues) != 1 || result.Issues[0].String() != "PolicyTemplate frozen: spec.evaluator.productions[0].match: Invalid value: \"frozen\": namespaceObject has no equivalent" {
+		t.Fatalf("unexpected issues: %v", result.Issues)
+	}
+
+	// Templates which only warn are bound with the warn action
+	expectedActions := []v1alpha1.ValidationAction{v1alpha1.Warn}
+	if len(result.Bindings) != 1 || !reflect.DeepEqual(expectedActions, result.Bindings[0].Spec.ValidationActions) {
+		t.Fatalf("unexpected bindings: %v", result.Bindings)
+	}
+}
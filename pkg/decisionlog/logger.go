@@ -0,0 +1,141 @@
+package decisionlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/validator"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apiserver/pkg/admission"
+)
+
+// Number of decisions waiting to be written. Decisions are dropped while the
+// queue is full rather than slowing admission down
+const queueSize = 1000
+
+// Maximum number of records written to the sink at once
+const batchSize = 100
+
+// A validated request waiting to be logged
+type decision struct {
+	attrs    admission.Attributes
+	uid      types.UID
+	started  time.Time
+	latency  time.Duration
+	err      error
+	warnings []string
+	results  []evaluate.BindingResult
+}
+
+// Wraps a validator to write a record of each request it validates to a
+// sink. The results of the bindings the validator evaluated for the request
+// are recorded along with its decision, including the validations after the
+// first denial.
+type Logger struct {
+	admission.ValidationInterface
+
+	sink  Sink
+	queue chan decision
+}
+
+func NewLogger(validator admission.ValidationInterface, sink Sink) *Logger {
+	return &Logger{
+		ValidationInterface: validator,
+		sink:                sink,
+		queue:               make(chan decision, queueSize),
+	}
+}
+
+func (l *Logger) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	started := time.Now()
+	results, warnings, err := validator.ValidateWithResults(ctx, l.ValidationInterface, a, o)
+
+	select {
+	case l.queue <- decision{
+		attrs:    a,
+		uid:      validator.RequestUID(ctx),
+		started:  started,
+		latency:  time.Since(started),
+		err:      err,
+		warnings: warnings,
+		results:  results,
+	}:
+	default:
+		utilruntime.HandleError(fmt.Errorf("decision log is full, dropping decision of request %s", validator.RequestUID(ctx)))
+	}
+	return err
+}
+
+// Writes queued decisions to the sink until ctx is cancelled, then closes it
+func (l *Logger) Run(ctx context.Context) error {
+	defer l.sink.Close()
+
+	for {
+		var batch []Record
+		select {
+		case <-ctx.Done():
+			return nil
+		case d := <-l.queue:
+			batch = append(batch, l.record(d))
+		}
+
+		// Write whatever else is already waiting along with it
+	fill:
+		for len(batch) < batchSize {
+			select {
+			case d := <-l.queue:
+				batch = append(batch, l.record(d))
+			default:
+				break fill
+			}
+		}
+
+		if err := l.sink.Write(ctx, batch); err != nil {
+			utilruntime.HandleError(fmt.Errorf("writing %d decisions: %w", len(batch), err))
+		}
+	}
+}
+
+func (l *Logger) record(d decision) Record {
+	a := d.attrs
+	record := Record{
+		Timestamp: d.started.UTC(),
+		UID:       d.uid,
+		User: User{
+			Name:   a.GetUserInfo().GetName(),
+			UID:    a.GetUserInfo().GetUID(),
+			Groups: a.GetUserInfo().GetGroups(),
+		},
+		Operation:   a.GetOperation(),
+		Kind:        metav1.GroupVersionKind(a.GetKind()),
+		Resource:    metav1.GroupVersionResource(a.GetResource()),
+		SubResource: a.GetSubresource(),
+		Namespace:   a.GetNamespace(),
+		Name:        a.GetName(),
+		DryRun:      a.IsDryRun(),
+		Allowed:     d.err == nil,
+		Warnings:    d.warnings,
+		Latency:     d.latency,
+	}
+
+	if d.err != nil {
+		record.Message = d.err.Error()
+
+		var apiStatus k8serrors.APIStatus
+		if errors.As(d.err, &apiStatus) {
+			record.Message = apiStatus.Status().Message
+		}
+	}
+
+	record.Bindings = d.results
+	for _, binding := range record.Bindings {
+		record.Cost += binding.Cost
+	}
+	return record
+}
@@ -0,0 +1,139 @@
+package decisionlog_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/decisionlog"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	"github.com/alexzielenski/cel_polyfill/pkg/validator"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// Collects the records written by the logger
+type memorySink struct {
+	lock    sync.Mutex
+	records []decisionlog.Record
+}
+
+func (s *memorySink) Write(ctx context.Context, records []decisionlog.Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func (s *memorySink) Records() []decisionlog.Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]decisionlog.Record{}, s.records...)
+}
+
+// Evaluates bindings like the enforcement and namespaced validators:
+// replica-limit-prod denies deployments named big, and the namespaced
+// team-label-prod warns of every deployment
+var stubValidator = evaluatetest.Validator(func(a admission.Attributes) []evaluate.BindingResult {
+	replicas := evaluate.ValidationResult{Expression: "object.spec.replicas <= 3", Outcome: evaluate.ValidationAdmit}
+	if a.GetName() == "big" {
+		replicas.Outcome, replicas.Message = evaluate.ValidationDeny, "too many replicas"
+	}
+
+	return []evaluate.BindingResult{
+		{
+			Policy:      "replica-limit",
+			Binding:     "replica-limit-prod",
+			Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+			Validations: []evaluate.ValidationResult{replicas},
+			Cost:        3,
+		},
+		{
+			Policy:      "team-label",
+			Binding:     "team-label-prod",
+			Namespace:   "prod",
+			Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Warn},
+			Validations: []evaluate.ValidationResult{{Expression: "has(object.metadata.labels.team)", Outcome: evaluate.ValidationDeny, Message: "missing team label"}},
+			Cost:        2,
+		},
+	}
+})
+
+func TestLogger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sink := &memorySink{}
+	logger := decisionlog.NewLogger(stubValidator, sink)
+	go logger.Run(ctx)
+
+	for _, name := range []string{"small", "big"} {
+		attrs := evaluatetest.DeploymentAttributes(
+			evaluatetest.Deployment("prod", name, map[string]int64{"small": 2, "big": 5}[name], map[string]interface{}{}),
+			&user.DefaultInfo{Name: "alice", Groups: []string{"dev"}},
+		)
+
+		requestContext := validator.WithRequestUID(ctx, types.UID("uid-"+name))
+		err := logger.Validate(requestContext, attrs, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+		if (err != nil) != (name == "big") {
+			t.Errorf("unexpected result of validating %s: %v", name, err)
+		}
+	}
+
+	var records []decisionlog.Record
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		records = sink.Records()
+		return len(records) == 2, nil
+	}); err != nil {
+		t.Fatalf("expected 2 records, got: %+v", records)
+	}
+
+	small, big := records[0], records[1]
+	if small.UID != "uid-small" || !small.Allowed || len(small.Message) != 0 || len(small.Warnings) != 1 {
+		t.Errorf("unexpected record of the allowed request: %+v", small)
+	}
+
+	if big.UID != "uid-big" || big.Allowed || big.Message != "deployments.apps \"big\" is forbidden: ValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit-prod' denied request: too many replicas" {
+		t.Errorf("unexpected record of the denied request: %+v", big)
+	}
+
+	if big.User.Name != "alice" || big.Operation != admission.Create || big.Resource.Resource != "deployments" || big.Namespace != "prod" || big.Name != "big" {
+		t.Errorf("unexpected request of the record: %+v", big)
+	}
+
+	if len(big.Warnings) != 1 {
+		t.Errorf("expected the warning to be recorded, got: %q", big.Warnings)
+	}
+
+	// The results of the evaluation which decided the request are recorded,
+	// including those of namespaced policies
+	if len(big.Bindings) != 2 {
+		t.Fatalf("expected two bindings to be recorded, got: %+v", big.Bindings)
+	}
+
+	if result := big.Bindings[0]; result.Policy != "replica-limit" || result.Validations[0].Outcome != evaluate.ValidationDeny {
+		t.Errorf("unexpected binding result: %+v", result)
+	}
+
+	if result := big.Bindings[1]; result.Policy != "team-label" || result.Namespace != "prod" {
+		t.Errorf("unexpected namespaced binding result: %+v", result)
+	}
+
+	if big.Cost != 5 {
+		t.Errorf("expected the cost of the bindings to be recorded, got %d", big.Cost)
+	}
+
+	if small.Bindings[0].Validations[0].Outcome != evaluate.ValidationAdmit {
+		t.Errorf("expected the replica limit to admit small, got: %+v", small.Bindings[0].Validations)
+	}
+}
@@ -0,0 +1,51 @@
+package decisionlog
+
+import (
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/admission"
+)
+
+// A single admission decision, written to sinks as a line of JSON
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	// UID of the AdmissionReview. Correlates the record with the audit
+	// events of the apiserver
+	UID  types.UID `json:"uid,omitempty"`
+	User User      `json:"user"`
+
+	Operation   admission.Operation         `json:"operation"`
+	Kind        metav1.GroupVersionKind     `json:"kind"`
+	Resource    metav1.GroupVersionResource `json:"resource"`
+	SubResource string                      `json:"subResource,omitempty"`
+	Namespace   string                      `json:"namespace,omitempty"`
+	Name        string                      `json:"name,omitempty"`
+	DryRun      bool                        `json:"dryRun,omitempty"`
+
+	Allowed bool `json:"allowed"`
+
+	// Message the request was denied with
+	Message  string   `json:"message,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Bindings which matched the request, including those of
+	// NamespacedValidatingAdmissionPolicies, with the result of each of their
+	// validations as they were evaluated for the decision
+	Bindings []evaluate.BindingResult `json:"bindings,omitempty"`
+
+	// Total runtime CEL cost of the matched bindings
+	Cost int64 `json:"cost"`
+
+	// Time taken to validate the request, encoded in nanoseconds
+	Latency time.Duration `json:"latencyNanoseconds"`
+}
+
+type User struct {
+	Name   string   `json:"name"`
+	UID    string   `json:"uid,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
@@ -0,0 +1,155 @@
+package decisionlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/logfile"
+)
+
+// Timeout of the HTTP sink created by NewSink
+const defaultHTTPTimeout = 10 * time.Second
+
+// Destination of decision records. Only used by a single goroutine
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// Returns the sink described by spec, one of:
+//
+//	stdout		records are written to standard output
+//	file:///path	records are written to a file rotated every 100MiB
+//	https://host/path	batches of records are POSTed to the URL
+func NewSink(spec string) (Sink, error) {
+	if spec == "stdout" {
+		return NewStreamSink(os.Stdout), nil
+	}
+
+	parsed, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parsing decision log sink %q: %w", spec, err)
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		return NewFileSink(parsed.Path, logfile.DefaultMaxSize, logfile.DefaultMaxBackups)
+	case "http", "https":
+		return NewHTTPSink(spec, &http.Client{Timeout: defaultHTTPTimeout}), nil
+	default:
+		return nil, fmt.Errorf("unsupported decision log sink %q", spec)
+	}
+}
+
+// Encodes records as newline delimited JSON
+func encode(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+type streamSink struct {
+	w io.Writer
+}
+
+// Writes records as lines of JSON to w
+func NewStreamSink(w io.Writer) Sink {
+	return streamSink{w: w}
+}
+
+func (s streamSink) Write(ctx context.Context, records []Record) error {
+	data, err := encode(records)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s streamSink) Close() error {
+	return nil
+}
+
+// Writes records as lines of JSON to a file. Once the file would exceed
+// maxSize it is renamed to path.1, and so on up to maxBackups, and a new file
+// is started.
+type FileSink struct {
+	file *logfile.File
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	file, err := logfile.Open(path, 0644, maxSize, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("opening decision log: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(ctx context.Context, records []Record) error {
+	for i := range records {
+		line, err := encode(records[i : i+1])
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.file.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// POSTs batches of records to a URL as newline delimited JSON
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	return &HTTPSink{url: url, client: client}
+}
+
+func (s *HTTPSink) Write(ctx context.Context, records []Record) error {
+	data, err := encode(records)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("posting decisions: %w", err)
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("posting decisions: unexpected status %s", response.Status)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	return nil
+}
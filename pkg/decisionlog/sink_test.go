@@ -0,0 +1,153 @@
+package decisionlog_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/decisionlog"
+)
+
+func names(names ...string) []decisionlog.Record {
+	var records []decisionlog.Record
+	for _, name := range names {
+		records = append(records, decisionlog.Record{Name: name, Allowed: true})
+	}
+	return records
+}
+
+// Returns the names of the records in a file of JSON lines
+func readNames(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var result []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record decisionlog.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, record.Name)
+	}
+	return result
+}
+
+func TestFileSink(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	line, err := json.Marshal(names("a")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two records fit in a file
+	sink, err := decisionlog.NewFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		if err := sink.Write(ctx, names(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest file, of a and b, is dropped
+	expected := map[string]string{
+		path:        "g",
+		path + ".1": "e,f",
+		path + ".2": "c,d",
+	}
+	for file, records := range expected {
+		if actual := strings.Join(readNames(t, file), ","); actual != records {
+			t.Errorf("expected %s to have %q, got %q", file, records, actual)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, got: %v", err)
+	}
+
+	// Reopened files are appended to
+	sink, err = decisionlog.NewFileSink(path, int64(2*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(ctx, names("h")); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	if actual := strings.Join(readNames(t, path), ","); actual != "g,h" {
+		t.Errorf("expected the reopened file to have g,h, got %q", actual)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received []string
+	status := http.StatusOK
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected request %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, strings.TrimSpace(string(body)))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink, err := decisionlog.NewSink(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Write(context.Background(), names("a", "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 1 || len(strings.Split(received[0], "\n")) != 2 {
+		t.Errorf("expected a single request of two lines, got: %q", received)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := sink.Write(context.Background(), names("c")); err == nil {
+		t.Error("expected an error for a failed request")
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	for _, spec := range []string{"stdout", "file://" + path, "https://siem.example.com/ingest"} {
+		sink, err := decisionlog.NewSink(spec)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", spec, err)
+			continue
+		}
+		sink.Close()
+	}
+
+	if _, err := decisionlog.NewSink("syslog://localhost"); err == nil {
+		t.Error("expected an error for an unsupported sink")
+	}
+}
@@ -0,0 +1,144 @@
+package enforcement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/client-go/util/retry"
+)
+
+// A binding requests are exempt from. Every binding of the policy if binding
+// is empty
+type exemptBinding struct {
+	policy  string
+	binding string
+}
+
+func isExempt(exempt []exemptBinding, policy, binding string) bool {
+	for _, e := range exempt {
+		if e.policy == policy && (len(e.binding) == 0 || e.binding == binding) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns whether a request is exempt from the bindings of an exemption at
+// the given time
+func MatchesExemption(exemption *polyfillv1alpha1.PolicyExemption, a admission.Attributes, now time.Time) (bool, error) {
+	spec := exemption.Spec
+	if !now.Before(spec.Expires.Time) {
+		return false, nil
+	}
+
+	if len(spec.Namespaces) > 0 && !sets.NewString(spec.Namespaces...).Has(a.GetNamespace()) {
+		return false, nil
+	}
+
+	if len(spec.Users) > 0 || len(spec.Groups) > 0 {
+		userInfo := a.GetUserInfo()
+		if !sets.NewString(spec.Users...).Has(userInfo.GetName()) && !sets.NewString(spec.Groups...).HasAny(userInfo.GetGroups()...) {
+			return false, nil
+		}
+	}
+
+	if spec.ObjectSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.ObjectSelector)
+		if err != nil {
+			return false, err
+		}
+
+		obj := a.GetObject()
+		if a.GetOperation() == admission.Delete {
+			obj = a.GetOldObject()
+		}
+
+		if obj == nil {
+			return false, nil
+		}
+
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			// Objects without labels, e.g. of subresources, are not selected
+			return false, nil
+		}
+
+		if !selector.Matches(labels.Set(accessor.GetLabels())) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Updates the status of each exemption whose expiry has changed as of now,
+// and of each binding which unexpired exemptions started or stopped applying
+// to
+func (v *Validator) SyncStatus(ctx context.Context, now time.Time) error {
+	exemptions, err := v.exemptionLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("listing policy exemptions: %w", err)
+	}
+
+	var errs []error
+	var active []exemptBinding
+	for _, exemption := range exemptions {
+		status := polyfillv1alpha1.PolicyExemptionStatus{
+			Expired:            !now.Before(exemption.Spec.Expires.Time),
+			ObservedGeneration: exemption.Generation,
+		}
+
+		if !status.Expired {
+			active = append(active, exemptBinding{policy: exemption.Spec.PolicyName, binding: exemption.Spec.BindingName})
+		}
+
+		if exemption.Status == status {
+			continue
+		}
+
+		updated := exemption.DeepCopy()
+		updated.Status = status
+		if _, err := v.client.AdmissionregistrationV1alpha1().PolicyExemptions().UpdateStatus(ctx, updated, metav1.UpdateOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("updating status of policy exemption %s: %w", exemption.Name, err))
+		}
+	}
+
+	bindings, err := v.polyfillBindingLister.List(labels.Everything())
+	if err != nil {
+		return utilerrors.NewAggregate(append(errs, fmt.Errorf("listing bindings: %w", err)))
+	}
+
+	for _, binding := range bindings {
+		exempted := isExempt(active, binding.Spec.PolicyName, binding.Name)
+		if binding.Status.Exempted == exempted {
+			continue
+		}
+
+		// The status of bindings is also written by the shadow evaluator
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			client := v.client.AdmissionregistrationV1alpha1().ValidatingAdmissionPolicyBindings()
+			latest, err := client.Get(ctx, binding.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			latest = latest.DeepCopy()
+			latest.Status.Exempted = exempted
+			_, err = client.UpdateStatus(ctx, latest, metav1.UpdateOptions{})
+			return err
+		})
+
+		if err != nil && !k8serrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("updating status of binding %s: %w", binding.Name, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,59 @@
+package enforcement
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Returns whether a binding enforces its Deny action for an object in a
+// namespace at the given time. namespace is nil for cluster scoped objects,
+// which are bucketed by their name instead.
+func Enforced(binding *polyfillv1alpha1.ValidatingAdmissionPolicyBinding, namespace *corev1.Namespace, name string, now time.Time) (bool, error) {
+	rollout := binding.Spec.Rollout
+	if rollout == nil {
+		return true, nil
+	}
+
+	if rollout.EnforceAfter != nil && now.Before(rollout.EnforceAfter.Time) {
+		return false, nil
+	}
+
+	if rollout.Percentage == nil && rollout.NamespaceSelector == nil {
+		return true, nil
+	}
+
+	if rollout.Percentage != nil {
+		key := name
+		if namespace != nil {
+			key = namespace.Name
+		}
+
+		if *rollout.Percentage >= 100 || bucket(binding.Name, key) < *rollout.Percentage {
+			return true, nil
+		}
+	}
+
+	if rollout.NamespaceSelector != nil && namespace != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rollout.NamespaceSelector)
+		if err != nil {
+			return false, fmt.Errorf("rollout of binding %s: %w", binding.Name, err)
+		}
+		return selector.Matches(labels.Set(namespace.Labels)), nil
+	}
+	return false, nil
+}
+
+// Returns the bucket in [0, 100) of a namespace, or of a cluster scoped
+// object, in the rollout of a binding. The name of the binding is included so
+// that every rollout does not start with the same namespaces
+func bucket(binding, key string) int32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(binding + "/" + key))
+	return int32(hash.Sum32() % 100)
+}
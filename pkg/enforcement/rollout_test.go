@@ -0,0 +1,200 @@
+package enforcement_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/enforcement"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/warning"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func rolloutBinding(rollout *polyfillv1alpha1.BindingRollout) *polyfillv1alpha1.ValidatingAdmissionPolicyBinding {
+	return &polyfillv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit-prod"},
+		Spec: polyfillv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        "replica-limit",
+			ValidationActions: []polyfillv1alpha1.ValidationAction{polyfillv1alpha1.Deny},
+			Rollout:           rollout,
+		},
+	}
+}
+
+func percentage(p int32) *int32 {
+	return &p
+}
+
+func TestEnforced(t *testing.T) {
+	now := time.Unix(1000, 0)
+	prod := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"ring": "early"}}}
+
+	cases := []struct {
+		name      string
+		rollout   *polyfillv1alpha1.BindingRollout
+		namespace *corev1.Namespace
+		expected  bool
+	}{
+		{
+			name:      "no rollout",
+			namespace: prod,
+			expected:  true,
+		},
+		{
+			name:      "before enforceAfter",
+			rollout:   &polyfillv1alpha1.BindingRollout{EnforceAfter: &metav1.Time{Time: now.Add(time.Minute)}},
+			namespace: prod,
+		},
+		{
+			name:      "after enforceAfter",
+			rollout:   &polyfillv1alpha1.BindingRollout{EnforceAfter: &metav1.Time{Time: now.Add(-time.Minute)}},
+			namespace: prod,
+			expected:  true,
+		},
+		{
+			name:      "no namespaces",
+			rollout:   &polyfillv1alpha1.BindingRollout{Percentage: percentage(0)},
+			namespace: prod,
+		},
+		{
+			name:      "every namespace",
+			rollout:   &polyfillv1alpha1.BindingRollout{Percentage: percentage(100)},
+			namespace: prod,
+			expected:  true,
+		},
+		{
+			name:    "cluster scoped",
+			rollout: &polyfillv1alpha1.BindingRollout{Percentage: percentage(0)},
+		},
+		{
+			name:     "every cluster scoped object",
+			rollout:  &polyfillv1alpha1.BindingRollout{Percentage: percentage(100)},
+			expected: true,
+		},
+		{
+			name:      "early adopter",
+			rollout:   &polyfillv1alpha1.BindingRollout{Percentage: percentage(0), NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ring": "early"}}},
+			namespace: prod,
+			expected:  true,
+		},
+		{
+			name:      "not early adopter",
+			rollout:   &polyfillv1alpha1.BindingRollout{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"ring": "late"}}},
+			namespace: prod,
+		},
+	}
+
+	for _, c := range cases {
+		enforced, err := enforcement.Enforced(rolloutBinding(c.rollout), c.namespace, "web", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if enforced != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, enforced)
+		}
+	}
+}
+
+func TestEnforcedPercentage(t *testing.T) {
+	for _, clusterScoped := range []bool{false, true} {
+		enforcedAt := func(p int32) map[string]bool {
+			enforced := map[string]bool{}
+			for i := 0; i < 1000; i++ {
+				name := fmt.Sprintf("team-%d", i)
+
+				// Cluster scoped objects are bucketed by their own name
+				var namespace *corev1.Namespace
+				if !clusterScoped {
+					namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+				}
+
+				if ok, _ := enforcement.Enforced(rolloutBinding(&polyfillv1alpha1.BindingRollout{Percentage: &p}), namespace, name, time.Now()); ok {
+					enforced[name] = true
+				}
+			}
+			return enforced
+		}
+
+		low, high := enforcedAt(20), enforcedAt(60)
+		if len(low) < 150 || len(low) > 250 || len(high) < 550 || len(high) > 650 {
+			t.Errorf("expected about 200 and 600 of 1000 to be enforced (cluster scoped: %v), got %d and %d", clusterScoped, len(low), len(high))
+		}
+
+		// Raising the percentage only adds namespaces and objects
+		for name := range low {
+			if !high[name] {
+				t.Errorf("expected %s to remain enforced", name)
+			}
+		}
+	}
+}
+
+func TestValidatorRollout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	equivalent := polyfillv1alpha1.Equivalent
+	binding := rolloutBinding(&polyfillv1alpha1.BindingRollout{EnforceAfter: &metav1.Time{Time: time.Now().Add(time.Hour)}})
+	binding.Spec.MatchResources = &polyfillv1alpha1.MatchResources{
+		NamespaceSelector: &metav1.LabelSelector{},
+		ObjectSelector:    &metav1.LabelSelector{},
+		MatchPolicy:       &equivalent,
+	}
+
+	customClient := polyfillfake.NewSimpleClientset(
+		evaluatetest.Policy("replica-limit", "object.spec.replicas <= 3", "too many replicas"),
+		binding,
+	)
+	client := controllerv1alpha1.NewWrappedClient(fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}), customClient)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	validator := enforcement.NewValidator(
+		factory,
+		customFactory,
+		client,
+		customClient,
+		meta.NewDefaultRESTMapper(nil),
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		time.Hour,
+	)
+	factory.Start(ctx.Done())
+	customFactory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+
+	// The denial of the binding is downgraded to a warning until enforceAfter
+	warnings := evaluate.NewWarningCollector(ctx)
+	if err := validator.Validate(warning.WithWarningRecorder(ctx, warnings), request("bob", 5, nil), nil); err != nil {
+		t.Fatalf("expected the request to be allowed, got: %v", err)
+	}
+
+	expected := []string{
+		"Validation failed for ValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit-prod': too many replicas",
+	}
+
+	actual := warnings.Warnings()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected warnings %q, got %q", expected, actual)
+	}
+
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("expected warning %q, got %q", expected[i], actual[i])
+		}
+	}
+}
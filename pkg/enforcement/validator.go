@@ -0,0 +1,271 @@
+package enforcement
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	polyfilllisters "github.com/alexzielenski/cel_polyfill/pkg/generated/listers/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/matching"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	admissionregistrationv1alpha1listers "k8s.io/client-go/listers/admissionregistration/v1alpha1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Enforces the ValidatingAdmissionPolicies and their bindings like the
+// validatingadmissionpolicy plugin, except that how a binding is enforced may
+// depend on the request: bindings PolicyExemptions apply to are skipped, and
+// those outside of their rollout only warn of the request. Every binding
+// which matches is evaluated, and its result passed to the recorder of the
+// context, so observers see more than the first denial.
+type Validator struct {
+	exemptionLister       polyfilllisters.PolicyExemptionLister
+	polyfillBindingLister polyfilllisters.ValidatingAdmissionPolicyBindingLister
+	policyLister          admissionregistrationv1alpha1listers.ValidatingAdmissionPolicyLister
+	namespaceLister       corev1listers.NamespaceLister
+	hasSynced             []cache.InformerSynced
+
+	client   versioned.Interface
+	matcher  validatingadmissionpolicy.Matcher
+	params   *evaluate.ParamCache
+	interval time.Duration
+
+	// Signalled when exemptions or bindings change, so their status is
+	// updated without waiting for the interval
+	syncs chan struct{}
+}
+
+func NewValidator(
+	factory informers.SharedInformerFactory,
+	customFactory externalversions.SharedInformerFactory,
+	client kubernetes.Interface,
+	customClient versioned.Interface,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
+	interval time.Duration,
+) *Validator {
+	exemptions := customFactory.Admissionregistration().V1alpha1().PolicyExemptions()
+	polyfillBindings := customFactory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicyBindings()
+	policies := factory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicies()
+	namespaces := factory.Core().V1().Namespaces()
+
+	result := &Validator{
+		exemptionLister:       exemptions.Lister(),
+		polyfillBindingLister: polyfillBindings.Lister(),
+		policyLister:          policies.Lister(),
+		namespaceLister:       namespaces.Lister(),
+		hasSynced: []cache.InformerSynced{
+			exemptions.Informer().HasSynced,
+			polyfillBindings.Informer().HasSynced,
+			policies.Informer().HasSynced,
+			namespaces.Informer().HasSynced,
+		},
+		client:   customClient,
+		matcher:  validatingadmissionpolicy.NewMatcher(matching.NewMatcher(namespaces.Lister(), client)),
+		params:   evaluate.NewDefaultParamCache(restMapper, dynamicClient),
+		interval: interval,
+		syncs:    make(chan struct{}, 1),
+	}
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { result.queueSync() },
+		UpdateFunc: func(oldObj, newObj interface{}) { result.queueSync() },
+		DeleteFunc: func(obj interface{}) { result.queueSync() },
+	}
+	exemptions.Informer().AddEventHandler(handler)
+	polyfillBindings.Informer().AddEventHandler(handler)
+	return result
+}
+
+func (v *Validator) HasSynced() bool {
+	for _, hasSynced := range v.hasSynced {
+		if !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Validator) Handles(operation admission.Operation) bool {
+	return true
+}
+
+// Evaluates every enforced binding which matches a request, skipping those it
+// is exempt from. The first to deny the request decides its status like the
+// plugin would. Bindings outside of their rollout warn of their violations
+// instead
+func (v *Validator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if isPolicyResource(a) {
+		return nil
+	}
+
+	if err := wait.PollImmediateWithContext(ctx, 100*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return v.HasSynced(), nil
+	}); err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("not yet ready to handle request"))
+	}
+
+	now := time.Now()
+	exempt := v.exemptions(a, now)
+
+	crdBindings, err := v.polyfillBindingLister.List(labels.Everything())
+	if err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("listing bindings: %w", err))
+	}
+
+	var namespace *corev1.Namespace
+	var bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+	warnOnly := sets.NewString()
+
+	for _, crdBinding := range crdBindings {
+		if !controllerv1alpha1.IsEnforced(crdBinding) || isExempt(exempt, crdBinding.Spec.PolicyName, crdBinding.Name) {
+			continue
+		}
+
+		if crdBinding.Spec.Rollout != nil {
+			if namespace == nil && len(a.GetNamespace()) > 0 {
+				if namespace, err = v.namespaceLister.Get(a.GetNamespace()); err != nil {
+					// Namespaces being created are not yet known
+					namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: a.GetNamespace()}}
+				}
+			}
+
+			enforced, err := Enforced(crdBinding, namespace, a.GetName(), now)
+			if err != nil {
+				utilruntime.HandleError(err)
+			} else if !enforced {
+				warnOnly.Insert(crdBinding.Name)
+			}
+		}
+
+		binding, err := controllerv1alpha1.CRDToNativePolicyBinding(crdBinding)
+		if err != nil {
+			return admission.NewForbidden(a, fmt.Errorf("converting binding %s: %w", crdBinding.Name, err))
+		}
+		bindings = append(bindings, binding)
+	}
+
+	if len(bindings) == 0 {
+		return nil
+	}
+
+	policies, err := v.policyLister.List(labels.Everything())
+	if err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("listing policies: %w", err))
+	}
+
+	results := evaluate.NewExplainer(v.matcher, v.params.Resolver(ctx)).Explain(ctx, a, policies, bindings)
+	for i, result := range results {
+		if warnOnly.Has(result.Binding) {
+			results[i].Actions = warnInstead(result.Actions)
+		}
+	}
+
+	evaluate.AddResults(ctx, results...)
+	return evaluate.Enforce(ctx, a, results)
+}
+
+// Replaces the Deny action of a binding outside of its rollout with Warn
+func warnInstead(actions []admissionregistrationv1alpha1.ValidationAction) []admissionregistrationv1alpha1.ValidationAction {
+	result := []admissionregistrationv1alpha1.ValidationAction{}
+	warns := false
+	for _, action := range actions {
+		switch action {
+		case admissionregistrationv1alpha1.Deny:
+			continue
+		case admissionregistrationv1alpha1.Warn:
+			warns = true
+		}
+		result = append(result, action)
+	}
+
+	if !warns {
+		result = append(result, admissionregistrationv1alpha1.Warn)
+	}
+	return result
+}
+
+// Requests for policies, bindings and exemptions are never denied, so they
+// can be fixed while a policy denies everything
+func isPolicyResource(a admission.Attributes) bool {
+	resource := a.GetResource()
+	if resource.Group != "admissionregistration.k8s.io" && resource.Group != polyfillv1alpha1.GroupName {
+		return false
+	}
+
+	switch resource.Resource {
+	case "validatingadmissionpolicies", "validatingadmissionpolicybindings":
+		return true
+	case "policyexemptions":
+		return resource.Group == polyfillv1alpha1.GroupName
+	}
+	return false
+}
+
+// Returns the bindings a request is exempt from by now
+func (v *Validator) exemptions(a admission.Attributes, now time.Time) []exemptBinding {
+	exemptions, err := v.exemptionLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("listing policy exemptions: %w", err))
+	}
+
+	result := []exemptBinding{}
+	for _, exemption := range exemptions {
+		matches, err := MatchesExemption(exemption, a, now)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("matching policy exemption %s: %w", exemption.Name, err))
+			continue
+		} else if matches {
+			result = append(result, exemptBinding{policy: exemption.Spec.PolicyName, binding: exemption.Spec.BindingName})
+		}
+	}
+	return result
+}
+
+// Flags expired exemptions and the bindings exemptions apply to in their
+// status, whenever either changes and every interval, until ctx is cancelled
+func (v *Validator) Run(ctx context.Context) error {
+	if !cache.WaitForNamedCacheSync("enforcement", ctx.Done(), v.hasSynced...) {
+		return ctx.Err()
+	}
+
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		v.queueSync()
+	}, v.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-v.syncs:
+			if err := v.SyncStatus(ctx, time.Now()); err != nil {
+				utilruntime.HandleError(err)
+			}
+		}
+	}
+}
+
+func (v *Validator) queueSync() {
+	select {
+	case v.syncs <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,255 @@
+package enforcement_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/enforcement"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/warning"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Returns the attributes of a request by username, in the group dev,
+// creating the Deployment prod/web
+func request(username string, replicas int64, labels map[string]interface{}) admission.Attributes {
+	return evaluatetest.DeploymentAttributes(evaluatetest.Deployment("prod", "web", replicas, labels), &user.DefaultInfo{Name: username, Groups: []string{"dev"}})
+}
+
+func exempted(binding *polyfillv1alpha1.ValidatingAdmissionPolicyBinding) *polyfillv1alpha1.ValidatingAdmissionPolicyBinding {
+	binding.Status.Exempted = true
+	return binding
+}
+
+func policyExemption(name string, spec polyfillv1alpha1.PolicyExemptionSpec) *polyfillv1alpha1.PolicyExemption {
+	spec.Justification = "migrating"
+	return &polyfillv1alpha1.PolicyExemption{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Generation: 1},
+		Spec:       spec,
+	}
+}
+
+func TestValidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	future := metav1.NewTime(time.Now().Add(time.Hour))
+	past := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	// The status of both exempted bindings is stale: the exemption of
+	// replica-limit-prod was created and that of team-label-all expired since
+	// they were last synced
+	customClient := polyfillfake.NewSimpleClientset(
+		evaluatetest.Policy("replica-limit", "object.spec.replicas <= 3", "too many replicas"),
+		evaluatetest.Binding("replica-limit-prod", "replica-limit"),
+		evaluatetest.Policy("team-label", "has(object.metadata.labels.team)", "missing team label"),
+		exempted(evaluatetest.Binding("team-label-all", "team-label")),
+		evaluatetest.Policy("image-tag", "false", "latest tag"),
+		evaluatetest.Binding("image-tag-all", "image-tag", polyfillv1alpha1.Warn),
+		policyExemption("replica-limit-alice", polyfillv1alpha1.PolicyExemptionSpec{
+			PolicyName: "replica-limit",
+			Namespaces: []string{"prod"},
+			Users:      []string{"alice"},
+			Expires:    future,
+		}),
+		policyExemption("team-label-expired", polyfillv1alpha1.PolicyExemptionSpec{
+			PolicyName:  "team-label",
+			BindingName: "team-label-all",
+			Expires:     past,
+		}),
+	)
+	client := controllerv1alpha1.NewWrappedClient(fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}), customClient)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	validator := enforcement.NewValidator(
+		factory,
+		customFactory,
+		client,
+		customClient,
+		meta.NewDefaultRESTMapper(nil),
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		time.Hour,
+	)
+	factory.Start(ctx.Done())
+	customFactory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+
+	imageTagWarning := "Validation failed for ValidatingAdmissionPolicy 'image-tag' with binding 'image-tag-all': latest tag"
+	cases := []struct {
+		name     string
+		attrs    admission.Attributes
+		expected string
+		warnings []string
+		results  int
+	}{
+		{
+			name:     "exempt",
+			attrs:    request("alice", 5, map[string]interface{}{"team": "web"}),
+			warnings: []string{imageTagWarning},
+			results:  2,
+		},
+		{
+			name:     "exempt denied by another binding",
+			attrs:    request("alice", 5, map[string]interface{}{}),
+			expected: "deployments.apps \"web\" is forbidden: ValidatingAdmissionPolicy 'team-label' with binding 'team-label-all' denied request: missing team label",
+			warnings: []string{imageTagWarning},
+			results:  2,
+		},
+		{
+			name:     "not exempt",
+			attrs:    request("bob", 5, map[string]interface{}{"team": "web"}),
+			expected: "deployments.apps \"web\" is forbidden: ValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit-prod' denied request: too many replicas",
+			warnings: []string{imageTagWarning},
+			results:  3,
+		},
+		{
+			// Exemptions can be granted while a policy denies everything
+			name: "policy exemption",
+			attrs: admission.NewAttributesRecord(
+				nil,
+				nil,
+				polyfillv1alpha1.SchemeGroupVersion.WithKind("PolicyExemption"),
+				"",
+				"replica-limit-bob",
+				polyfillv1alpha1.SchemeGroupVersion.WithResource("policyexemptions"),
+				"",
+				admission.Create,
+				&metav1.CreateOptions{},
+				false,
+				&user.DefaultInfo{Name: "bob"},
+			),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			warnings := evaluate.NewWarningCollector(ctx)
+			results := evaluate.NewResultCollector(ctx)
+			err := validator.Validate(warning.WithWarningRecorder(evaluate.WithResultRecorder(ctx, results), warnings), c.attrs, nil)
+
+			message := ""
+			if err != nil {
+				message = err.Error()
+			}
+
+			if message != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, message)
+			}
+
+			actual := warnings.Warnings()
+			if len(actual) != len(c.warnings) {
+				t.Fatalf("expected warnings %q, got %q", c.warnings, actual)
+			}
+
+			for i := range c.warnings {
+				if actual[i] != c.warnings[i] {
+					t.Errorf("expected warning %q, got %q", c.warnings[i], actual[i])
+				}
+			}
+
+			// Every matching binding is evaluated, not only the first to
+			// deny the request
+			if len(results.Results()) != c.results {
+				t.Errorf("expected %d results, got: %+v", c.results, results.Results())
+			}
+		})
+	}
+
+	if err := validator.SyncStatus(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expired := range map[string]bool{"replica-limit-alice": false, "team-label-expired": true} {
+		e, err := customClient.AdmissionregistrationV1alpha1().PolicyExemptions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if e.Status.Expired != expired || e.Status.ObservedGeneration != 1 {
+			t.Errorf("unexpected status of %s: %+v", name, e.Status)
+		}
+	}
+
+	// Bindings are only exempted while their exemptions have not expired
+	for name, exempted := range map[string]bool{"replica-limit-prod": true, "team-label-all": false, "image-tag-all": false} {
+		b, err := customClient.AdmissionregistrationV1alpha1().ValidatingAdmissionPolicyBindings().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b.Status.Exempted != exempted {
+			t.Errorf("expected %s to be exempted %v, got: %+v", name, exempted, b.Status)
+		}
+	}
+}
+
+func TestMatchesExemption(t *testing.T) {
+	now := time.Unix(1000, 0)
+	expires := metav1.NewTime(now.Add(time.Minute))
+
+	cases := []struct {
+		name     string
+		spec     polyfillv1alpha1.PolicyExemptionSpec
+		expected bool
+	}{
+		{
+			name:     "everything",
+			spec:     polyfillv1alpha1.PolicyExemptionSpec{Expires: expires},
+			expected: true,
+		},
+		{
+			name: "expired",
+			spec: polyfillv1alpha1.PolicyExemptionSpec{Expires: metav1.NewTime(now)},
+		},
+		{
+			name: "other namespace",
+			spec: polyfillv1alpha1.PolicyExemptionSpec{Expires: expires, Namespaces: []string{"staging"}},
+		},
+		{
+			name:     "group",
+			spec:     polyfillv1alpha1.PolicyExemptionSpec{Expires: expires, Users: []string{"bob"}, Groups: []string{"dev"}},
+			expected: true,
+		},
+		{
+			name: "other user",
+			spec: polyfillv1alpha1.PolicyExemptionSpec{Expires: expires, Users: []string{"bob"}},
+		},
+		{
+			name:     "selected",
+			spec:     polyfillv1alpha1.PolicyExemptionSpec{Expires: expires, ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "web"}}},
+			expected: true,
+		},
+		{
+			name: "not selected",
+			spec: polyfillv1alpha1.PolicyExemptionSpec{Expires: expires, ObjectSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "db"}}},
+		},
+	}
+
+	attrs := request("alice", 1, map[string]interface{}{"team": "web"})
+	for _, c := range cases {
+		matches, err := enforcement.MatchesExemption(policyExemption(c.name, c.spec), attrs, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if matches != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, matches)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package evaluate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/warning"
+	"k8s.io/klog/v2"
+)
+
+// Outcome of a single validation expression
+type ValidationOutcome string
+
+const (
+	// The expression evaluated to true
+	ValidationAdmit ValidationOutcome = "Admit"
+	// The expression evaluated to anything other than true
+	ValidationDeny ValidationOutcome = "Deny"
+	// The expression failed to compile or evaluate
+	ValidationError ValidationOutcome = "Error"
+)
+
+// Result of evaluating a request against the policies of an evaluator
+type Decision struct {
+	// False if any binding denied the request
+	Allowed bool
+
+	// Status the request was denied with. Nil if it was allowed
+	Status *metav1.Status
+
+	// Warnings returned to the client by bindings with the Warn action
+	Warnings []string
+
+	// Annotations added to the audit event of the request
+	AuditAnnotations map[string]string
+
+	// Bindings which matched the request, ordered by policy then binding name
+	Bindings []BindingResult
+
+	// Total runtime CEL cost of all matched bindings
+	Cost int64
+}
+
+// Result of evaluating the validations of a policy for one of its bindings
+type BindingResult struct {
+	Policy  string `json:"policy"`
+	Binding string `json:"binding,omitempty"`
+
+	// Namespace of a NamespacedValidatingAdmissionPolicy and its binding.
+	// Empty for ValidatingAdmissionPolicies
+	Namespace string `json:"namespace,omitempty"`
+
+	// Actions the binding takes for failed validations
+	Actions []admissionregistrationv1alpha1.ValidationAction `json:"actions,omitempty"`
+
+	// Failure policy of the policy, which decides whether errors deny the
+	// request. Fail if unset
+	FailurePolicy admissionregistrationv1alpha1.FailurePolicyType `json:"failurePolicy,omitempty"`
+
+	// Set if the binding could not be evaluated, e.g. because its param was
+	// not found. Its failure policy decides whether the request is denied
+	Error string `json:"error,omitempty"`
+
+	Validations []ValidationResult `json:"validations,omitempty"`
+
+	// Audit annotations of the policy which were published or failed to
+	// evaluate. Empty and null values are left out
+	AuditAnnotations []AuditAnnotationResult `json:"auditAnnotations,omitempty"`
+
+	// Runtime CEL cost of the validations, their messages and the audit
+	// annotations
+	Cost int64 `json:"cost"`
+}
+
+// Result of evaluating the valueExpression of an audit annotation
+type AuditAnnotationResult struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+
+	// Like errors of validations, these only deny the request if the failure
+	// policy is Fail
+	Error string `json:"error,omitempty"`
+}
+
+// Result of evaluating a single validation expression
+type ValidationResult struct {
+	Expression string            `json:"expression"`
+	Outcome    ValidationOutcome `json:"outcome"`
+
+	// Message and reason the request is denied with. Only set for denials
+	Message string              `json:"message,omitempty"`
+	Reason  metav1.StatusReason `json:"reason,omitempty"`
+
+	// Only set for errors
+	Error string `json:"error,omitempty"`
+
+	// Encoded in nanoseconds
+	Elapsed time.Duration `json:"elapsedNanoseconds"`
+}
+
+// A message a binding fails validation of a request with
+type Denial struct {
+	Message string
+
+	// Reason of the validation which failed, StatusReasonInvalid if unset
+	Reason metav1.StatusReason
+}
+
+// Returns the messages the binding fails validation of the request with.
+// Errors only fail validation if the failure policy is Fail
+func (r BindingResult) Denials() []Denial {
+	if len(r.Error) > 0 {
+		if !r.failsClosed() {
+			return nil
+		}
+		return []Denial{{Message: r.Error, Reason: metav1.StatusReasonInvalid}}
+	}
+
+	var denials []Denial
+	for i := range r.Validations {
+		if denial, failed := r.validationDenial(i); failed {
+			denials = append(denials, denial)
+		}
+	}
+	return denials
+}
+
+// Returns the message the i-th validation of the binding fails validation of
+// the request with, if it does
+func (r BindingResult) validationDenial(i int) (Denial, bool) {
+	validation := r.Validations[i]
+	switch {
+	case validation.Outcome == ValidationDeny:
+		reason := validation.Reason
+		if len(reason) == 0 {
+			reason = metav1.StatusReasonInvalid
+		}
+		return Denial{Message: validation.Message, Reason: reason}, true
+	case validation.Outcome == ValidationError && r.failsClosed():
+		return Denial{Message: validation.Error, Reason: metav1.StatusReasonInvalid}, true
+	}
+	return Denial{}, false
+}
+
+// Returns whether errors deny the request
+func (r BindingResult) failsClosed() bool {
+	return r.FailurePolicy != admissionregistrationv1alpha1.Ignore
+}
+
+// Returns whether the binding takes action for failed validations
+func (r BindingResult) HasAction(action admissionregistrationv1alpha1.ValidationAction) bool {
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the kind of the policy of the binding
+func (r BindingResult) Kind() string {
+	if len(r.Namespace) > 0 {
+		return "NamespacedValidatingAdmissionPolicy"
+	}
+	return "ValidatingAdmissionPolicy"
+}
+
+// Applies the actions of the bindings which matched a request like the
+// plugin would: the first denial decides the status of the request, and every
+// other failure is still warned of or annotated
+func Decide(attrs admission.Attributes, results []BindingResult) *Decision {
+	decision := &Decision{
+		Allowed:          true,
+		AuditAnnotations: map[string]string{},
+		Bindings:         results,
+	}
+
+	deny := func(result BindingResult, denial Denial) {
+		if !decision.Allowed {
+			return
+		}
+		decision.Allowed = false
+
+		message := fmt.Sprintf("%s '%s' denied request: %s", result.Kind(), result.Policy, denial.Message)
+		if len(result.Binding) > 0 {
+			message = fmt.Sprintf("%s '%s' with binding '%s' denied request: %s", result.Kind(), result.Policy, result.Binding, denial.Message)
+		}
+
+		err := admission.NewForbidden(attrs, errors.New(message)).(*k8serrors.StatusError)
+		err.ErrStatus.Reason = denial.Reason
+		err.ErrStatus.Code = reasonToCode(denial.Reason)
+		err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{Message: message})
+		decision.Status = &err.ErrStatus
+	}
+
+	// Values of the audit annotations of each policy, deduplicated across its
+	// bindings
+	auditAnnotations := map[string][]string{}
+
+	for _, result := range results {
+		decision.Cost += result.Cost
+
+		// Bindings which could not be evaluated deny regardless of their
+		// actions
+		if len(result.Error) > 0 {
+			for _, denial := range result.Denials() {
+				deny(result, denial)
+			}
+			continue
+		}
+
+		for i := range result.Validations {
+			denial, failed := result.validationDenial(i)
+			if !failed {
+				continue
+			}
+
+			if result.HasAction(admissionregistrationv1alpha1.Deny) {
+				deny(result, denial)
+			}
+			if result.HasAction(admissionregistrationv1alpha1.Warn) {
+				decision.Warnings = append(decision.Warnings, fmt.Sprintf("Validation failed for %s '%s' with binding '%s': %s", result.Kind(), result.Policy, result.Binding, denial.Message))
+			}
+			if result.HasAction(admissionregistrationv1alpha1.Audit) {
+				// Annotations cannot be overwritten, so only the first
+				// failure is recorded
+				if _, found := decision.AuditAnnotations[validationFailureAnnotation]; !found {
+					decision.AuditAnnotations[validationFailureAnnotation] = validationFailure(result, i, denial)
+				}
+			}
+		}
+
+		for _, auditAnnotation := range result.AuditAnnotations {
+			if len(auditAnnotation.Error) > 0 {
+				if result.failsClosed() {
+					deny(result, Denial{Message: auditAnnotation.Error, Reason: metav1.StatusReasonInvalid})
+				}
+				continue
+			}
+
+			key := result.Policy + "/" + auditAnnotation.Key
+			value := auditAnnotation.Value
+			if len(value) > maxAuditAnnotationValueLength {
+				value = value[:maxAuditAnnotationValueLength]
+			}
+			if !sets.NewString(auditAnnotations[key]...).Has(value) {
+				auditAnnotations[key] = append(auditAnnotations[key], value)
+			}
+		}
+	}
+
+	for key, values := range auditAnnotations {
+		decision.AuditAnnotations[key] = strings.Join(values, ", ")
+	}
+	return decision
+}
+
+// Longest value of an audit annotation the plugin publishes
+const maxAuditAnnotationValueLength = 10 * 1024
+
+// Annotates the failures of bindings with the Audit action
+const validationFailureAnnotation = "validation.policy.admission.k8s.io/validation_failure"
+
+// Encodes the failure of the i-th validation of a binding as the value of its
+// validationFailureAnnotation
+func validationFailure(result BindingResult, i int, denial Denial) string {
+	value, err := json.Marshal([]map[string]interface{}{{
+		"message":           denial.Message,
+		"policy":            result.Policy,
+		"binding":           result.Binding,
+		"expressionIndex":   i,
+		"validationActions": result.Actions,
+	}})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+	return string(value)
+}
+
+// Returns the status code the plugin denies requests with for reason
+func reasonToCode(reason metav1.StatusReason) int32 {
+	switch reason {
+	case metav1.StatusReasonForbidden:
+		return http.StatusForbidden
+	case metav1.StatusReasonUnauthorized:
+		return http.StatusUnauthorized
+	case metav1.StatusReasonRequestEntityTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// Applies the decision of results to a request: its warnings are added to the
+// recorder of ctx and its audit annotations to a. Returns the status the
+// request is denied with, if any
+func Enforce(ctx context.Context, a admission.Attributes, results []BindingResult) error {
+	decision := Decide(a, results)
+	for _, w := range decision.Warnings {
+		warning.AddWarning(ctx, "", w)
+	}
+
+	for key, value := range decision.AuditAnnotations {
+		if err := a.AddAnnotation(key, value); err != nil {
+			klog.Warningf("Failed to set admission audit annotation %s to %s: %v", key, value, err)
+		}
+	}
+
+	if decision.Status != nil {
+		return &k8serrors.StatusError{ErrStatus: *decision.Status}
+	}
+	return nil
+}
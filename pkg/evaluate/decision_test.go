@@ -0,0 +1,63 @@
+package evaluate_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDenials(t *testing.T) {
+	result := evaluate.BindingResult{
+		Validations: []evaluate.ValidationResult{
+			{Outcome: evaluate.ValidationDeny, Message: "a failed", Reason: metav1.StatusReasonForbidden},
+			{Outcome: evaluate.ValidationAdmit},
+			{Outcome: evaluate.ValidationError, Error: "c errored"},
+		},
+	}
+
+	ignored := result
+	ignored.FailurePolicy = admissionregistrationv1alpha1.Ignore
+
+	failed := evaluate.BindingResult{Error: "param not found"}
+
+	ignoredFailed := failed
+	ignoredFailed.FailurePolicy = admissionregistrationv1alpha1.Ignore
+
+	cases := []struct {
+		name     string
+		result   evaluate.BindingResult
+		expected []evaluate.Denial
+	}{
+		{
+			name:   "fail",
+			result: result,
+			expected: []evaluate.Denial{
+				{Message: "a failed", Reason: metav1.StatusReasonForbidden},
+				{Message: "c errored", Reason: metav1.StatusReasonInvalid},
+			},
+		},
+		{
+			name:     "ignore",
+			result:   ignored,
+			expected: []evaluate.Denial{{Message: "a failed", Reason: metav1.StatusReasonForbidden}},
+		},
+		{
+			name:     "binding error",
+			result:   failed,
+			expected: []evaluate.Denial{{Message: "param not found", Reason: metav1.StatusReasonInvalid}},
+		},
+		{
+			name:   "ignored binding error",
+			result: ignoredFailed,
+		},
+	}
+
+	for _, c := range cases {
+		if denials := c.result.Denials(); !reflect.DeepEqual(denials, c.expected) {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, denials)
+		}
+	}
+}
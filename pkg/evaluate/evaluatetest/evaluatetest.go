@@ -0,0 +1,144 @@
+// Package evaluatetest provides the validators, policies, bindings and
+// requests shared by the tests of the packages built on package evaluate
+package evaluatetest
+
+import (
+	"context"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// Decides requests like the enforcement validator, from the results the
+// function returns for them rather than by evaluating policies
+type Validator func(a admission.Attributes) []evaluate.BindingResult
+
+func (Validator) Handles(admission.Operation) bool {
+	return true
+}
+
+func (v Validator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	results := v(a)
+	evaluate.AddResults(ctx, results...)
+	return evaluate.Enforce(ctx, a, results)
+}
+
+// Returns an apps/v1 Deployment. Labels are left unset if nil
+func Deployment(namespace, name string, replicas int64, labels map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name, "namespace": namespace}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   metadata,
+		"spec":       map[string]interface{}{"replicas": replicas},
+	}}
+}
+
+// Returns the attributes of a request by userInfo creating deployment
+func DeploymentAttributes(deployment *unstructured.Unstructured, userInfo user.Info) admission.Attributes {
+	return admission.NewAttributesRecord(
+		deployment,
+		nil,
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		deployment.GetNamespace(),
+		deployment.GetName(),
+		schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		"",
+		admission.Create,
+		&metav1.CreateOptions{},
+		false,
+		userInfo,
+	)
+}
+
+// Rules matching the creation of Deployments
+func deploymentRules() []polyfillv1alpha1.NamedRuleWithOperations {
+	return []polyfillv1alpha1.NamedRuleWithOperations{{
+		RuleWithOperations: polyfillv1alpha1.RuleWithOperations{
+			Operations: []polyfillv1alpha1.OperationType{"CREATE"},
+			Rule: polyfillv1alpha1.Rule{
+				APIGroups:   []string{"apps"},
+				APIVersions: []string{"*"},
+				Resources:   []string{"deployments"},
+			},
+		},
+	}}
+}
+
+// Returns a policy validating the creation of Deployments with expression
+func Policy(name, expression, message string) *polyfillv1alpha1.ValidatingAdmissionPolicy {
+	fail := polyfillv1alpha1.Fail
+	equivalent := polyfillv1alpha1.Equivalent
+	return &polyfillv1alpha1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: polyfillv1alpha1.ValidatingAdmissionPolicySpec{
+			FailurePolicy: &fail,
+			MatchConstraints: &polyfillv1alpha1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				MatchPolicy:       &equivalent,
+				ResourceRules:     deploymentRules(),
+			},
+			Validations: []polyfillv1alpha1.Validation{{Expression: expression, Message: message}},
+		},
+	}
+}
+
+// Returns a binding of policyName to every object. It denies failed
+// validations unless other actions are given
+func Binding(name, policyName string, actions ...polyfillv1alpha1.ValidationAction) *polyfillv1alpha1.ValidatingAdmissionPolicyBinding {
+	if len(actions) == 0 {
+		actions = []polyfillv1alpha1.ValidationAction{polyfillv1alpha1.Deny}
+	}
+
+	equivalent := polyfillv1alpha1.Equivalent
+	return &polyfillv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: polyfillv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+			PolicyName: policyName,
+			MatchResources: &polyfillv1alpha1.MatchResources{
+				NamespaceSelector: &metav1.LabelSelector{},
+				ObjectSelector:    &metav1.LabelSelector{},
+				MatchPolicy:       &equivalent,
+			},
+			ValidationActions: actions,
+		},
+	}
+}
+
+// Returns a namespaced policy validating the creation of Deployments with
+// expression. Its defaults are left unset
+func NamespacedPolicy(namespace, name string, paramKind *polyfillv1alpha1.ParamKind, expression, message string) *polyfillv1alpha1.NamespacedValidatingAdmissionPolicy {
+	return &polyfillv1alpha1.NamespacedValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: polyfillv1alpha1.ValidatingAdmissionPolicySpec{
+			ParamKind:        paramKind,
+			MatchConstraints: &polyfillv1alpha1.MatchResources{ResourceRules: deploymentRules()},
+			Validations:      []polyfillv1alpha1.Validation{{Expression: expression, Message: message}},
+		},
+	}
+}
+
+// Returns a namespaced binding of policyName to param, if not empty
+func NamespacedBinding(namespace, name, policyName, param string, action polyfillv1alpha1.ValidationAction) *polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBinding {
+	binding := &polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBindingSpec{
+			PolicyName:        policyName,
+			ValidationActions: []polyfillv1alpha1.ValidationAction{action},
+		},
+	}
+	if len(param) > 0 {
+		binding.Spec.ParamRef = &polyfillv1alpha1.NamespacedParamRef{Name: param}
+	}
+	return binding
+}
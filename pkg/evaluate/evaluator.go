@@ -0,0 +1,309 @@
+package evaluate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/matching"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// Evaluates requests against policies like the validatingadmissionpolicy
+// plugin, with resources held in memory rather than in a cluster. Starting an
+// evaluator waits for its namespace informer to sync, so one should be reused
+// across requests against the same resources.
+type Evaluator struct {
+	restMapper meta.RESTMapper
+	explainer  *Explainer
+
+	policies []*admissionregistrationv1alpha1.ValidatingAdmissionPolicy
+	bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+	params   []*unstructured.Unstructured
+
+	cancel func()
+}
+
+// Starts an evaluator for resources. It must be closed to stop its informers.
+func New(ctx context.Context, resources *Resources) (*Evaluator, error) {
+	evaluator := &Evaluator{restMapper: NewRESTMapper(resources.CRDs)}
+
+	for _, policy := range resources.Policies {
+		native, err := controllerv1alpha1.CRDToNativePolicy(policy.DeepCopy())
+		if err != nil {
+			return nil, err
+		}
+		evaluator.policies = append(evaluator.policies, defaultPolicy(native))
+	}
+
+	for _, binding := range resources.Bindings {
+		native, err := controllerv1alpha1.CRDToNativePolicyBinding(binding.DeepCopy())
+		if err != nil {
+			return nil, err
+		}
+		evaluator.bindings = append(evaluator.bindings, defaultBinding(native))
+	}
+
+	for _, param := range resources.Params {
+		raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(param)
+		if err != nil {
+			return nil, err
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.GroupVersionKind().Empty() {
+			return nil, fmt.Errorf("param %s is missing its apiVersion and kind", obj.GetName())
+		}
+		evaluator.params = append(evaluator.params, obj)
+	}
+
+	// Namespaces are read by the matcher of namespaceSelectors
+	var namespaces []runtime.Object
+	for _, namespace := range resources.Namespaces {
+		namespace = namespace.DeepCopy()
+
+		// Set by the apiserver on every namespace
+		if namespace.Labels == nil {
+			namespace.Labels = map[string]string{}
+		}
+		namespace.Labels[corev1.LabelMetadataName] = namespace.Name
+		namespaces = append(namespaces, namespace)
+	}
+
+	client := fake.NewSimpleClientset(namespaces...)
+	factory := informers.NewSharedInformerFactory(client, 0)
+
+	//!TODO: type check policies against the schemas of CRDs and builtins
+	evaluator.explainer = NewExplainer(
+		validatingadmissionpolicy.NewMatcher(matching.NewMatcher(factory.Core().V1().Namespaces().Lister(), client)),
+		evaluator.findParam,
+	)
+
+	ctx, cancel := context.WithCancel(ctx)
+	evaluator.cancel = cancel
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	return evaluator, nil
+}
+
+// Evaluates a single request against resources
+func Evaluate(ctx context.Context, resources *Resources, request *admissionv1.AdmissionRequest) (*Decision, error) {
+	evaluator, err := New(ctx, resources)
+	if err != nil {
+		return nil, err
+	}
+	defer evaluator.Close()
+
+	return evaluator.Evaluate(ctx, request)
+}
+
+// Stops the informers of the evaluator
+func (e *Evaluator) Close() {
+	e.cancel()
+}
+
+// Evaluates a request. Returns an error if the request itself is malformed;
+// failures of policies are reported in the decision.
+func (e *Evaluator) Evaluate(ctx context.Context, request *admissionv1.AdmissionRequest) (*Decision, error) {
+	attrs, err := e.attributes(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decide(attrs, e.explainer.Explain(ctx, attrs, e.policies, e.bindings)), nil
+}
+
+// Decides a request from the results its bindings were evaluated with
+// elsewhere, e.g. those recorded by the webhook, as the evaluator would
+func (e *Evaluator) Decide(request *admissionv1.AdmissionRequest, results []BindingResult) (*Decision, error) {
+	attrs, err := e.attributes(request)
+	if err != nil {
+		return nil, err
+	}
+	return Decide(attrs, results), nil
+}
+
+var objectInterfaces = admission.NewObjectInterfacesFromScheme(clientsetscheme.Scheme)
+
+// Builds the admission attributes of a request. The kind, resource, name and
+// namespace of the request are taken from its object if unset.
+func (e *Evaluator) attributes(request *admissionv1.AdmissionRequest) (admission.Attributes, error) {
+	operation := admission.Create
+	if len(request.Operation) > 0 {
+		operation = admission.Operation(request.Operation)
+	}
+
+	// Absent objects must be untyped nils
+	var object, oldObject runtime.Object
+	var reference *unstructured.Unstructured
+	if len(request.OldObject.Raw) > 0 {
+		decoded := &unstructured.Unstructured{}
+		if err := decoded.UnmarshalJSON(request.OldObject.Raw); err != nil {
+			return nil, fmt.Errorf("oldObject: %w", err)
+		}
+		oldObject, reference = decoded, decoded
+	}
+	if len(request.Object.Raw) > 0 {
+		decoded := &unstructured.Unstructured{}
+		if err := decoded.UnmarshalJSON(request.Object.Raw); err != nil {
+			return nil, fmt.Errorf("object: %w", err)
+		}
+		object, reference = decoded, decoded
+	}
+
+	gvk := schema.GroupVersionKind(request.Kind)
+	name, namespace := request.Name, request.Namespace
+	if reference != nil {
+		if gvk.Empty() {
+			gvk = reference.GroupVersionKind()
+		}
+		if len(name) == 0 {
+			name = reference.GetName()
+		}
+		if len(namespace) == 0 {
+			namespace = reference.GetNamespace()
+		}
+	}
+
+	if gvk.Empty() {
+		return nil, errors.New("the kind of the request is required if it has no object")
+	}
+
+	gvr := schema.GroupVersionResource(request.Resource)
+	if gvr.Empty() {
+		mapping, err := e.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, err
+		}
+		gvr = mapping.Resource
+	}
+
+	userInfo := &user.DefaultInfo{
+		Name:   request.UserInfo.Username,
+		UID:    request.UserInfo.UID,
+		Groups: request.UserInfo.Groups,
+		Extra:  map[string][]string{},
+	}
+	for key, values := range request.UserInfo.Extra {
+		userInfo.Extra[key] = values
+	}
+
+	dryRun := request.DryRun != nil && *request.DryRun
+	return admission.NewAttributesRecord(
+		object,
+		oldObject,
+		gvk,
+		namespace,
+		name,
+		gvr,
+		request.SubResource,
+		operation,
+		nil,
+		dryRun,
+		userInfo,
+	), nil
+}
+
+// Finds the param a binding refers to
+func (e *Evaluator) findParam(paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error) {
+	gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	gvk := gv.WithKind(paramKind.Kind)
+	for _, param := range e.params {
+		if param.GroupVersionKind() == gvk && param.GetName() == ref.Name && param.GetNamespace() == ref.Namespace {
+			return param, nil
+		}
+	}
+	if len(ref.Namespace) > 0 {
+		return nil, fmt.Errorf("param %s %s/%s not found", paramKind.Kind, ref.Namespace, ref.Name)
+	}
+	return nil, fmt.Errorf("param %s %s not found", paramKind.Kind, ref.Name)
+}
+
+// Maps builtin kinds and the kinds defined by crds to their resources. Only
+// the resources of requests are mapped, so builtin kinds are all assumed to be
+// namespaced rather than discovered.
+func NewRESTMapper(crds []*apiextensionsv1.CustomResourceDefinition) meta.RESTMapper {
+	var defaultVersions []schema.GroupVersion
+	defaultVersions = append(defaultVersions, clientsetscheme.Scheme.PrioritizedVersionsAllGroups()...)
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			defaultVersions = append(defaultVersions, schema.GroupVersion{Group: crd.Spec.Group, Version: version.Name})
+		}
+	}
+
+	mapper := meta.NewDefaultRESTMapper(defaultVersions)
+	for gvk := range clientsetscheme.Scheme.AllKnownTypes() {
+		if gvk.Version == runtime.APIVersionInternal || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+
+	for _, crd := range crds {
+		scope := meta.RESTScopeNamespace
+		if crd.Spec.Scope == apiextensionsv1.ClusterScoped {
+			scope = meta.RESTScopeRoot
+		}
+
+		for _, version := range crd.Spec.Versions {
+			gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+			plural := gvk.GroupVersion().WithResource(crd.Spec.Names.Plural)
+			singular := gvk.GroupVersion().WithResource(crd.Spec.Names.Singular)
+			mapper.AddSpecific(gvk, plural, singular, scope)
+		}
+	}
+	return mapper
+}
+
+// Applies the defaults of the CRD schema. Without them nil selectors match
+// nothing
+func defaultPolicy(policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy) *admissionregistrationv1alpha1.ValidatingAdmissionPolicy {
+	if policy.Spec.FailurePolicy == nil {
+		failurePolicy := admissionregistrationv1alpha1.Fail
+		policy.Spec.FailurePolicy = &failurePolicy
+	}
+	defaultMatchResources(policy.Spec.MatchConstraints)
+	return policy
+}
+
+func defaultBinding(binding *admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding) *admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding {
+	defaultMatchResources(binding.Spec.MatchResources)
+	return binding
+}
+
+func defaultMatchResources(match *admissionregistrationv1alpha1.MatchResources) {
+	if match == nil {
+		return
+	}
+
+	if match.MatchPolicy == nil {
+		matchPolicy := admissionregistrationv1alpha1.Equivalent
+		match.MatchPolicy = &matchPolicy
+	}
+	if match.NamespaceSelector == nil {
+		match.NamespaceSelector = &metav1.LabelSelector{}
+	}
+	if match.ObjectSelector == nil {
+		match.ObjectSelector = &metav1.LabelSelector{}
+	}
+}
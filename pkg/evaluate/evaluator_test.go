@@ -0,0 +1,263 @@
+package evaluate_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+)
+
+func replicaResources() *evaluate.Resources {
+	return &evaluate.Resources{
+		Policies: []*v1alpha1.ValidatingAdmissionPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "replica-limit"},
+			Spec: v1alpha1.ValidatingAdmissionPolicySpec{
+				ParamKind: &v1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"},
+				MatchConstraints: &v1alpha1.MatchResources{
+					ResourceRules: []v1alpha1.NamedRuleWithOperations{{
+						RuleWithOperations: v1alpha1.RuleWithOperations{
+							Operations: []v1alpha1.OperationType{"CREATE", "UPDATE"},
+							Rule: v1alpha1.Rule{
+								APIGroups:   []string{"apps"},
+								APIVersions: []string{"v1"},
+								Resources:   []string{"deployments"},
+							},
+						},
+					}},
+				},
+				MatchConditions: []v1alpha1.MatchCondition{{
+					Name:       "not-exempt",
+					Expression: "!has(object.metadata.labels) || !('exempt' in object.metadata.labels)",
+				}},
+				Validations: []v1alpha1.Validation{
+					{
+						Expression:        "object.spec.replicas <= int(params.data.maxReplicas)",
+						MessageExpression: "'replicas must be at most ' + params.data.maxReplicas",
+					},
+					{
+						Expression: "object.metadata.name.startsWith('app-')",
+					},
+				},
+				AuditAnnotations: []v1alpha1.AuditAnnotation{{
+					Key:             "replicas",
+					ValueExpression: "string(object.spec.replicas)",
+				}},
+			},
+		}},
+		Bindings: []*v1alpha1.ValidatingAdmissionPolicyBinding{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "replica-limit-prod"},
+				Spec: v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+					PolicyName: "replica-limit",
+					ParamRef:   &v1alpha1.ParamRef{Name: "prod-limits", Namespace: "default"},
+					MatchResources: &v1alpha1.MatchResources{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+					},
+					ValidationActions: []v1alpha1.ValidationAction{v1alpha1.Deny, v1alpha1.Audit},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "replica-limit-test"},
+				Spec: v1alpha1.ValidatingAdmissionPolicyBindingSpec{
+					PolicyName: "replica-limit",
+					ParamRef:   &v1alpha1.ParamRef{Name: "test-limits", Namespace: "default"},
+					MatchResources: &v1alpha1.MatchResources{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "test"}},
+					},
+					ValidationActions: []v1alpha1.ValidationAction{v1alpha1.Warn},
+				},
+			},
+		},
+		Params: []runtime.Object{
+			&corev1.ConfigMap{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{Name: "prod-limits", Namespace: "default"},
+				Data:       map[string]string{"maxReplicas": "3"},
+			},
+			&corev1.ConfigMap{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{Name: "test-limits", Namespace: "default"},
+				Data:       map[string]string{"maxReplicas": "1"},
+			},
+		},
+		Namespaces: []*corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"env": "prod"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "test", Labels: map[string]string{"env": "test"}}},
+		},
+	}
+}
+
+func deploymentRequest(t *testing.T, namespace, name string, replicas int32, labels map[string]string) *admissionv1.AdmissionRequest {
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: pointer.Int32(replicas)},
+	}
+
+	js, err := json.Marshal(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: js},
+		UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: []string{"system:authenticated"}},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	evaluator, err := evaluate.New(ctx, replicaResources())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer evaluator.Close()
+
+	t.Run("allowed", func(t *testing.T) {
+		decision, err := evaluator.Evaluate(ctx, deploymentRequest(t, "prod", "app-web", 2, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !decision.Allowed || decision.Status != nil {
+			t.Fatalf("expected the request to be allowed, got: %v", decision.Status)
+		}
+
+		if len(decision.Bindings) != 1 || decision.Bindings[0].Binding != "replica-limit-prod" {
+			t.Fatalf("expected only replica-limit-prod to match, got: %+v", decision.Bindings)
+		}
+
+		for _, validation := range decision.Bindings[0].Validations {
+			if validation.Outcome != evaluate.ValidationAdmit {
+				t.Errorf("expected %q to admit, got: %+v", validation.Expression, validation)
+			}
+		}
+
+		if decision.Cost <= 0 {
+			t.Errorf("expected a positive cost, got %d", decision.Cost)
+		}
+
+		if decision.AuditAnnotations["replica-limit/replicas"] != "2" {
+			t.Errorf("expected the replicas audit annotation, got: %v", decision.AuditAnnotations)
+		}
+
+		expectedAnnotations := []evaluate.AuditAnnotationResult{{Key: "replicas", Value: "2"}}
+		if !reflect.DeepEqual(decision.Bindings[0].AuditAnnotations, expectedAnnotations) {
+			t.Errorf("expected %+v, got %+v", expectedAnnotations, decision.Bindings[0].AuditAnnotations)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		decision, err := evaluator.Evaluate(ctx, deploymentRequest(t, "prod", "web", 5, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if decision.Allowed || decision.Status == nil {
+			t.Fatal("expected the request to be denied")
+		}
+
+		if !strings.Contains(decision.Status.Message, "replicas must be at most 3") {
+			t.Errorf("expected the denial to contain the evaluated message, got: %s", decision.Status.Message)
+		}
+
+		if decision.Status.Reason != metav1.StatusReasonInvalid || decision.Status.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected the request to be denied as invalid, got: %s (%d)", decision.Status.Reason, decision.Status.Code)
+		}
+
+		if len(decision.Bindings) != 1 {
+			t.Fatalf("expected one binding to match, got: %+v", decision.Bindings)
+		}
+
+		expected := []evaluate.ValidationResult{
+			{
+				Expression: "object.spec.replicas <= int(params.data.maxReplicas)",
+				Outcome:    evaluate.ValidationDeny,
+				Message:    "replicas must be at most 3",
+				Reason:     metav1.StatusReasonInvalid,
+			},
+			{
+				Expression: "object.metadata.name.startsWith('app-')",
+				Outcome:    evaluate.ValidationDeny,
+				Message:    "failed expression: object.metadata.name.startsWith('app-')",
+				Reason:     metav1.StatusReasonInvalid,
+			},
+		}
+
+		validations := decision.Bindings[0].Validations
+		if len(validations) != len(expected) {
+			t.Fatalf("expected %d validations, got: %+v", len(expected), validations)
+		}
+		for i, validation := range validations {
+			validation.Elapsed = 0
+			if validation != expected[i] {
+				t.Errorf("expected %+v, got %+v", expected[i], validation)
+			}
+		}
+
+		if len(decision.AuditAnnotations["validation.policy.admission.k8s.io/validation_failure"]) == 0 {
+			t.Errorf("expected the Audit action to annotate the failure, got: %v", decision.AuditAnnotations)
+		}
+	})
+
+	t.Run("warned", func(t *testing.T) {
+		decision, err := evaluator.Evaluate(ctx, deploymentRequest(t, "test", "app-web", 2, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !decision.Allowed {
+			t.Fatalf("expected the request to be allowed, got: %v", decision.Status)
+		}
+
+		if len(decision.Warnings) != 1 || !strings.Contains(decision.Warnings[0], "replicas must be at most 1") {
+			t.Errorf("expected a warning for the replicas, got: %q", decision.Warnings)
+		}
+	})
+
+	t.Run("match conditions", func(t *testing.T) {
+		decision, err := evaluator.Evaluate(ctx, deploymentRequest(t, "prod", "web", 5, map[string]string{"exempt": "true"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !decision.Allowed || len(decision.Bindings) != 0 {
+			t.Errorf("expected exempt deployments not to match, got: %+v", decision)
+		}
+	})
+}
+
+func TestEvaluateMissingParam(t *testing.T) {
+	resources := replicaResources()
+	resources.Params = nil
+
+	decision, err := evaluate.Evaluate(context.Background(), resources, deploymentRequest(t, "prod", "app-web", 2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decision.Allowed {
+		t.Error("expected the request to be denied by the failure policy")
+	}
+
+	if len(decision.Bindings) != 1 || !strings.Contains(decision.Bindings[0].Error, "not found") {
+		t.Errorf("expected the binding to report its missing param, got: %+v", decision.Bindings)
+	}
+}
@@ -0,0 +1,264 @@
+package evaluate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	celtypes "github.com/google/cel-go/common/types"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/cel"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/webhook/matchconditions"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+)
+
+// Returns the param a binding refers to
+type ParamResolver func(paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error)
+
+// Evaluates every validation and audit annotation of each binding which
+// matches a request. The plugin stops at the first denial, and only reports
+// that one.
+type Explainer struct {
+	matcher validatingadmissionpolicy.Matcher
+	params  ParamResolver
+}
+
+func NewExplainer(matcher validatingadmissionpolicy.Matcher, params ParamResolver) *Explainer {
+	return &Explainer{matcher: matcher, params: params}
+}
+
+// Evaluates the validations of each of policies and bindings which match a
+// request, ordered by policy then binding name
+func (x *Explainer) Explain(
+	ctx context.Context,
+	attrs admission.Attributes,
+	policies []*admissionregistrationv1alpha1.ValidatingAdmissionPolicy,
+	bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding,
+) []BindingResult {
+	policies = append([]*admissionregistrationv1alpha1.ValidatingAdmissionPolicy{}, policies...)
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Name < policies[j].Name
+	})
+
+	bindings = append([]*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding{}, bindings...)
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+
+	var results []BindingResult
+	for _, policy := range policies {
+		matches, matchKind, err := x.matcher.DefinitionMatches(attrs, objectInterfaces, policy)
+		if err != nil {
+			result := newBindingResult(policy, nil)
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		} else if !matches {
+			continue
+		}
+
+		for _, binding := range bindings {
+			if binding.Spec.PolicyName != policy.Name {
+				continue
+			}
+
+			if matches, err := x.matcher.BindingMatches(attrs, objectInterfaces, binding); err != nil {
+				result := newBindingResult(policy, binding)
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			} else if !matches {
+				continue
+			}
+
+			if result, matched := x.evaluateBinding(ctx, attrs, matchKind, policy, binding); matched {
+				results = append(results, result)
+			}
+		}
+	}
+	return results
+}
+
+// Returns the result of a binding before it is evaluated. binding is nil if
+// the policy itself could not be matched
+func newBindingResult(
+	policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy,
+	binding *admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding,
+) BindingResult {
+	result := BindingResult{Policy: policy.Name}
+	if policy.Spec.FailurePolicy != nil {
+		result.FailurePolicy = *policy.Spec.FailurePolicy
+	}
+	if binding != nil {
+		result.Binding = binding.Name
+		result.Actions = binding.Spec.ValidationActions
+	}
+	return result
+}
+
+// Evaluates the validations of policy for binding. Returns false if the
+// request does not satisfy the matchConditions of policy.
+func (x *Explainer) evaluateBinding(
+	ctx context.Context,
+	attrs admission.Attributes,
+	matchKind schema.GroupVersionKind,
+	policy *admissionregistrationv1alpha1.ValidatingAdmissionPolicy,
+	binding *admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding,
+) (BindingResult, bool) {
+	result := newBindingResult(policy, binding)
+
+	var params runtime.Object
+	if policy.Spec.ParamKind != nil && binding.Spec.ParamRef != nil {
+		param, err := x.params(policy.Spec.ParamKind, binding.Spec.ParamRef)
+		if err != nil {
+			result.Error = err.Error()
+			return result, true
+		}
+		params = param
+	}
+
+	versionedAttr, err := admission.NewVersionedAttributes(attrs, matchKind, objectInterfaces)
+	if err != nil {
+		result.Error = err.Error()
+		return result, true
+	}
+
+	compiler := cel.NewFilterCompiler()
+	hasParams := policy.Spec.ParamKind != nil
+
+	if len(policy.Spec.MatchConditions) > 0 {
+		var accessors []cel.ExpressionAccessor
+		for i := range policy.Spec.MatchConditions {
+			accessors = append(accessors, (*matchconditions.MatchCondition)(&policy.Spec.MatchConditions[i]))
+		}
+
+		failurePolicy := admissionregistrationv1.FailurePolicyType(*policy.Spec.FailurePolicy)
+		filter := compiler.Compile(accessors, cel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, celconfig.PerCallLimit)
+		matched := matchconditions.NewMatcher(filter, nil, &failurePolicy, "validatingadmissionpolicy", policy.Name).Match(ctx, versionedAttr, params)
+		if matched.Error != nil {
+			result.Error = matched.Error.Error()
+			return result, true
+		} else if !matched.Matches {
+			return result, false
+		}
+	}
+
+	var validations, messages []cel.ExpressionAccessor
+	for _, validation := range policy.Spec.Validations {
+		validations = append(validations, &validatingadmissionpolicy.ValidationCondition{
+			Expression: validation.Expression,
+			Message:    validation.Message,
+			Reason:     validation.Reason,
+		})
+		messages = append(messages, &validatingadmissionpolicy.MessageExpressionCondition{
+			MessageExpression: validation.MessageExpression,
+		})
+	}
+
+	request := cel.CreateAdmissionRequest(attrs)
+	validationFilter := compiler.Compile(validations, cel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, celconfig.PerCallLimit)
+	evaluations, remaining, err := validationFilter.ForInput(ctx, versionedAttr, request, cel.OptionalVariableBindings{VersionedParams: params}, celconfig.RuntimeCELCostBudget)
+	if err != nil {
+		result.Error = err.Error()
+		result.Cost = celconfig.RuntimeCELCostBudget
+		return result, true
+	}
+
+	// Messages are only evaluated for failed validations, but the plugin
+	// evaluates them all against the remaining budget
+	messageFilter := compiler.Compile(messages, cel.OptionalVariableDeclarations{HasParams: hasParams}, celconfig.PerCallLimit)
+	messageEvaluations, remainingAfterMessages, err := messageFilter.ForInput(ctx, versionedAttr, request, cel.OptionalVariableBindings{VersionedParams: params}, remaining)
+	if err == nil {
+		remaining = remainingAfterMessages
+	}
+	result.Cost = celconfig.RuntimeCELCostBudget - remaining
+
+	for i, evaluation := range evaluations {
+		validation := policy.Spec.Validations[i]
+		validationResult := ValidationResult{
+			Expression: validation.Expression,
+			Elapsed:    evaluation.Elapsed,
+		}
+
+		switch {
+		case evaluation.Error != nil:
+			validationResult.Outcome = ValidationError
+			validationResult.Error = evaluation.Error.Error()
+		case evaluation.EvalResult == celtypes.True:
+			validationResult.Outcome = ValidationAdmit
+		default:
+			validationResult.Outcome = ValidationDeny
+			validationResult.Reason = metav1.StatusReasonInvalid
+			if validation.Reason != nil {
+				validationResult.Reason = *validation.Reason
+			}
+
+			var message string
+			if i < len(messageEvaluations) && messageEvaluations[i].Error == nil && messageEvaluations[i].EvalResult != nil {
+				message, _ = messageEvaluations[i].EvalResult.Value().(string)
+				message = strings.TrimSpace(message)
+				if strings.Contains(message, "\n") || len(message) > celconfig.MaxEvaluatedMessageExpressionSizeBytes {
+					message = ""
+				}
+			}
+			if len(message) == 0 {
+				message = strings.TrimSpace(validation.Message)
+			}
+			if len(message) == 0 {
+				message = fmt.Sprintf("failed expression: %v", strings.TrimSpace(validation.Expression))
+			}
+			validationResult.Message = message
+		}
+		result.Validations = append(result.Validations, validationResult)
+	}
+
+	// Audit annotations are evaluated against a budget of their own, like the
+	// plugin does
+	if len(policy.Spec.AuditAnnotations) == 0 {
+		return result, true
+	}
+
+	var auditAnnotations []cel.ExpressionAccessor
+	for _, auditAnnotation := range policy.Spec.AuditAnnotations {
+		auditAnnotations = append(auditAnnotations, &validatingadmissionpolicy.AuditAnnotationCondition{
+			Key:             auditAnnotation.Key,
+			ValueExpression: auditAnnotation.ValueExpression,
+		})
+	}
+
+	auditAnnotationFilter := compiler.Compile(auditAnnotations, cel.OptionalVariableDeclarations{HasParams: hasParams, HasAuthorizer: true}, celconfig.PerCallLimit)
+	auditAnnotationEvaluations, remaining, err := auditAnnotationFilter.ForInput(ctx, versionedAttr, request, cel.OptionalVariableBindings{VersionedParams: params}, celconfig.RuntimeCELCostBudget)
+	if err != nil {
+		result.Error = err.Error()
+		result.Cost += celconfig.RuntimeCELCostBudget
+		return result, true
+	}
+	result.Cost += celconfig.RuntimeCELCostBudget - remaining
+
+	for i, evaluation := range auditAnnotationEvaluations {
+		auditAnnotationResult := AuditAnnotationResult{Key: policy.Spec.AuditAnnotations[i].Key}
+
+		switch {
+		case evaluation.Error != nil:
+			auditAnnotationResult.Error = evaluation.Error.Error()
+		case evaluation.EvalResult.Type() == celtypes.StringType:
+			auditAnnotationResult.Value = strings.TrimSpace(evaluation.EvalResult.Value().(string))
+		case evaluation.EvalResult.Type() != celtypes.NullType:
+			auditAnnotationResult.Error = fmt.Sprintf("valueExpression '%v' resulted in unsupported return type: %v. "+
+				"Return type must be either string or null.", policy.Spec.AuditAnnotations[i].ValueExpression, evaluation.EvalResult.Type())
+		}
+
+		// Empty and null values are not published
+		if len(auditAnnotationResult.Value) > 0 || len(auditAnnotationResult.Error) > 0 {
+			result.AuditAnnotations = append(result.AuditAnnotations, auditAnnotationResult)
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,94 @@
+package evaluate
+
+import (
+	"context"
+	"time"
+
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilcache "k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/dynamic"
+)
+
+// Defaults of NewDefaultParamCache
+const (
+	DefaultParamTTL       = 10 * time.Second
+	DefaultParamCacheSize = 100
+)
+
+type paramKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
+type paramResult struct {
+	param runtime.Object
+	err   error
+}
+
+// Fetches params from the apiserver rather than watching them, reusing each
+// result for the requests evaluated within ttl
+type ParamCache struct {
+	restMapper    meta.RESTMapper
+	dynamicClient dynamic.Interface
+	ttl           time.Duration
+	cache         *utilcache.LRUExpireCache
+}
+
+func NewParamCache(restMapper meta.RESTMapper, dynamicClient dynamic.Interface, size int, ttl time.Duration) *ParamCache {
+	return &ParamCache{
+		restMapper:    restMapper,
+		dynamicClient: dynamicClient,
+		ttl:           ttl,
+		cache:         utilcache.NewLRUExpireCache(size),
+	}
+}
+
+// Returns a ParamCache of DefaultParamCacheSize params, each reused for
+// DefaultParamTTL
+func NewDefaultParamCache(restMapper meta.RESTMapper, dynamicClient dynamic.Interface) *ParamCache {
+	return NewParamCache(restMapper, dynamicClient, DefaultParamCacheSize, DefaultParamTTL)
+}
+
+// Returns a ParamResolver which fetches params missing from the cache with ctx
+func (c *ParamCache) Resolver(ctx context.Context) ParamResolver {
+	return func(paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error) {
+		return c.Get(ctx, paramKind, ref)
+	}
+}
+
+func (c *ParamCache) Get(ctx context.Context, paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error) {
+	gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	key := paramKey{gvk: gv.WithKind(paramKind.Kind), namespace: ref.Namespace, name: ref.Name}
+	if cached, found := c.cache.Get(key); found {
+		result := cached.(paramResult)
+		return result.param, result.err
+	}
+
+	mapping, err := c.restMapper.RESTMapping(key.gvk.GroupKind(), key.gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other errors, e.g. of the context of the request, are not cached so
+	// they do not fail the requests which follow
+	param, err := c.dynamicClient.Resource(mapping.Resource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		c.cache.Add(key, paramResult{err: err}, c.ttl)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	c.cache.Add(key, paramResult{param: param}, c.ttl)
+	return param, nil
+}
@@ -0,0 +1,86 @@
+package evaluate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestParamCache(t *testing.T) {
+	ctx := context.Background()
+
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	// The first get of the canceled param fails as if its request was
+	// canceled
+	canceled := false
+	dynamicClient.PrependReactor("get", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.(clienttesting.GetAction).GetName() == "canceled" && !canceled {
+			canceled = true
+			return true, nil, context.Canceled
+		}
+		return false, nil, nil
+	})
+
+	cache := evaluate.NewParamCache(restMapper, dynamicClient, 10, time.Minute)
+	paramKind := &admissionregistrationv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	get := func(name string) (runtime.Object, error) {
+		return cache.Get(ctx, paramKind, &admissionregistrationv1alpha1.ParamRef{Name: name, Namespace: "default"})
+	}
+
+	create := func(name string) {
+		param := &unstructured.Unstructured{}
+		param.SetAPIVersion("v1")
+		param.SetKind("ConfigMap")
+		param.SetName(name)
+		param.SetNamespace("default")
+		if err := dynamicClient.Tracker().Create(configMaps, param, "default"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Errors of the context are not cached
+	create("canceled")
+	if _, err := get("canceled"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if param, err := get("canceled"); err != nil || param == nil {
+		t.Fatalf("expected the param to be fetched again, got %v", err)
+	}
+
+	// Missing params are cached until they expire
+	if _, err := get("missing"); !k8serrors.IsNotFound(err) {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+	create("missing")
+	if _, err := get("missing"); !k8serrors.IsNotFound(err) {
+		t.Fatalf("expected NotFound to be cached, got %v", err)
+	}
+
+	// Found params are cached until they expire
+	create("found")
+	if _, err := get("found"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamicClient.Tracker().Delete(configMaps, "default", "found"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get("found"); err != nil {
+		t.Fatalf("expected the param to be cached, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package evaluate
+
+import (
+	"fmt"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Objects which make up the in-memory cluster policies are evaluated in
+type Resources struct {
+	Policies []*v1alpha1.ValidatingAdmissionPolicy
+	Bindings []*v1alpha1.ValidatingAdmissionPolicyBinding
+
+	// Objects referenced by the paramRef of bindings. Must have their
+	// apiVersion and kind set
+	Params []runtime.Object
+
+	// Namespaces of requests which are matched by namespaceSelectors
+	Namespaces []*corev1.Namespace
+
+	// Definitions of the custom resources which are requested or used as
+	// params
+	CRDs []*apiextensionsv1.CustomResourceDefinition
+}
+
+// Sorts objects into resources. Policies and bindings may be given either as
+// the polyfill's types or as admissionregistration.k8s.io types. Objects of
+// other kinds are params.
+func ResourcesFromObjects(objects []*unstructured.Unstructured) (*Resources, error) {
+	resources := &Resources{}
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		switch {
+		case gvk == v1alpha1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicy"):
+			policy := &v1alpha1.ValidatingAdmissionPolicy{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, policy); err != nil {
+				return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicy %s: %w", obj.GetName(), err)
+			}
+			resources.Policies = append(resources.Policies, policy)
+		case gvk == v1alpha1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicyBinding"):
+			binding := &v1alpha1.ValidatingAdmissionPolicyBinding{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, binding); err != nil {
+				return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicyBinding %s: %w", obj.GetName(), err)
+			}
+			resources.Bindings = append(resources.Bindings, binding)
+		case gvk == admissionregistrationv1alpha1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicy"):
+			native := &admissionregistrationv1alpha1.ValidatingAdmissionPolicy{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, native); err != nil {
+				return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicy %s: %w", obj.GetName(), err)
+			}
+
+			policy, err := controllerv1alpha1.NativeToCRDPolicy(native)
+			if err != nil {
+				return nil, err
+			}
+			resources.Policies = append(resources.Policies, policy)
+		case gvk == admissionregistrationv1alpha1.SchemeGroupVersion.WithKind("ValidatingAdmissionPolicyBinding"):
+			native := &admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, native); err != nil {
+				return nil, fmt.Errorf("failed to decode ValidatingAdmissionPolicyBinding %s: %w", obj.GetName(), err)
+			}
+
+			binding, err := controllerv1alpha1.NativeToCRDPolicyBinding(native)
+			if err != nil {
+				return nil, err
+			}
+			resources.Bindings = append(resources.Bindings, binding)
+		case gvk == apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"):
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, crd); err != nil {
+				return nil, fmt.Errorf("failed to decode CustomResourceDefinition %s: %w", obj.GetName(), err)
+			}
+			resources.CRDs = append(resources.CRDs, crd)
+		case gvk == corev1.SchemeGroupVersion.WithKind("Namespace"):
+			namespace := &corev1.Namespace{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, namespace); err != nil {
+				return nil, fmt.Errorf("failed to decode Namespace %s: %w", obj.GetName(), err)
+			}
+			resources.Namespaces = append(resources.Namespaces, namespace)
+		default:
+			resources.Params = append(resources.Params, obj)
+		}
+	}
+	return resources, nil
+}
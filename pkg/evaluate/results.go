@@ -0,0 +1,55 @@
+package evaluate
+
+import (
+	"context"
+	"sync"
+)
+
+// Receives the results of the bindings evaluated for a request, like the
+// recorders of the warning package receive its warnings
+type ResultRecorder interface {
+	AddResults(results ...BindingResult)
+}
+
+type resultRecorderKey struct{}
+
+// Returns a context whose validators pass the results of the bindings they
+// evaluate to recorder
+func WithResultRecorder(ctx context.Context, recorder ResultRecorder) context.Context {
+	return context.WithValue(ctx, resultRecorderKey{}, recorder)
+}
+
+// Passes results to the recorder of ctx. Dropped if ctx has none
+func AddResults(ctx context.Context, results ...BindingResult) {
+	if recorder, ok := ctx.Value(resultRecorderKey{}).(ResultRecorder); ok && len(results) > 0 {
+		recorder.AddResults(results...)
+	}
+}
+
+// Collects results, passing them on to the recorder of the context it was
+// created with
+type ResultCollector struct {
+	ctx context.Context
+
+	lock    sync.Mutex
+	results []BindingResult
+}
+
+func NewResultCollector(ctx context.Context) *ResultCollector {
+	return &ResultCollector{ctx: ctx}
+}
+
+func (c *ResultCollector) AddResults(results ...BindingResult) {
+	c.lock.Lock()
+	c.results = append(c.results, results...)
+	c.lock.Unlock()
+
+	AddResults(c.ctx, results...)
+}
+
+// Returns the results collected so far
+func (c *ResultCollector) Results() []BindingResult {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]BindingResult{}, c.results...)
+}
@@ -0,0 +1,36 @@
+package evaluate
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apiserver/pkg/warning"
+)
+
+// Collects warnings, passing them on to the recorder of the context it was
+// created with. A warning.Recorder
+type WarningCollector struct {
+	ctx context.Context
+
+	lock     sync.Mutex
+	warnings []string
+}
+
+func NewWarningCollector(ctx context.Context) *WarningCollector {
+	return &WarningCollector{ctx: ctx}
+}
+
+func (c *WarningCollector) AddWarning(agent, text string) {
+	c.lock.Lock()
+	c.warnings = append(c.warnings, text)
+	c.lock.Unlock()
+
+	warning.AddWarning(c.ctx, agent, text)
+}
+
+// Returns the warnings collected so far
+func (c *WarningCollector) Warnings() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string(nil), c.warnings...)
+}
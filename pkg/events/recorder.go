@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	polyfilllisters "github.com/alexzielenski/cel_polyfill/pkg/generated/listers/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	clientsetscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	admissionregistrationv1alpha1listers "k8s.io/client-go/listers/admissionregistration/v1alpha1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Component events are reported by
+const component = "cel-admission-polyfill"
+
+// Reasons of events, by the action the binding took
+const (
+	reasonDenied  = "PolicyDenied"
+	reasonWarned  = "PolicyWarned"
+	reasonAudited = "PolicyAudited"
+)
+
+// Similar events are aggregated by the correlator of the broadcaster. Each
+// involved object may be sent a burst of events, after which they are
+// limited to the rate
+const (
+	eventBurst = 25
+	eventQPS   = 1. / 60
+)
+
+// Emits Warning events for the requests which fail validation of bindings, so
+// they can be found with kubectl get events
+type Recorder struct {
+	broadcaster            record.EventBroadcaster
+	recorder               record.EventRecorder
+	policyLister           admissionregistrationv1alpha1listers.ValidatingAdmissionPolicyLister
+	namespacedPolicyLister polyfilllisters.NamespacedValidatingAdmissionPolicyLister
+
+	// Whether events are also emitted on the object of the request, in its
+	// namespace
+	objectEvents bool
+}
+
+func NewRecorder(factory informers.SharedInformerFactory, customFactory externalversions.SharedInformerFactory, client kubernetes.Interface, objectEvents bool) *Recorder {
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		BurstSize: eventBurst,
+		QPS:       eventQPS,
+	})
+
+	// Events recorded before the broadcaster has a sink are dropped
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
+	return &Recorder{
+		broadcaster:            broadcaster,
+		recorder:               broadcaster.NewRecorder(clientsetscheme.Scheme, corev1.EventSource{Component: component}),
+		policyLister:           factory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicies().Lister(),
+		namespacedPolicyLister: customFactory.Admissionregistration().V1alpha1().NamespacedValidatingAdmissionPolicies().Lister(),
+		objectEvents:           objectEvents,
+	}
+}
+
+// Stops sending events to the apiserver once ctx is cancelled
+func (r *Recorder) Run(ctx context.Context) error {
+	<-ctx.Done()
+	r.broadcaster.Shutdown()
+	return nil
+}
+
+// Emits an event for each validation a request failed, by the first of the
+// Deny, Warn and Audit actions of its binding. Passed to validator.NewObserved
+func (r *Recorder) RecordAdmission(attrs admission.Attributes, results []evaluate.BindingResult) {
+	gvk := attrs.GetKind()
+
+	name := attrs.GetName()
+	if len(attrs.GetNamespace()) > 0 {
+		name = attrs.GetNamespace() + "/" + name
+	}
+
+	for _, result := range results {
+		var reason, verb string
+		switch {
+		case result.HasAction(admissionregistrationv1alpha1.Deny):
+			reason, verb = reasonDenied, "Denied"
+		case result.HasAction(admissionregistrationv1alpha1.Warn):
+			reason, verb = reasonWarned, "Warned of"
+		case result.HasAction(admissionregistrationv1alpha1.Audit):
+			reason, verb = reasonAudited, "Audited"
+		default:
+			continue
+		}
+
+		for _, denial := range result.Denials() {
+			message := fmt.Sprintf("%s %s of %s %s by %s with binding '%s': %s",
+				verb, attrs.GetOperation(), gvk.Kind, name, attrs.GetUserInfo().GetName(), result.Binding, denial.Message)
+			r.recorder.Event(r.policyReference(result), corev1.EventTypeWarning, reason, message)
+
+			if r.objectEvents && len(attrs.GetNamespace()) > 0 && len(attrs.GetName()) > 0 {
+				message := fmt.Sprintf("%s %s by %s '%s' with binding '%s': %s",
+					verb, attrs.GetOperation(), result.Kind(), result.Policy, result.Binding, denial.Message)
+				r.recorder.Event(&corev1.ObjectReference{
+					APIVersion: gvk.GroupVersion().String(),
+					Kind:       gvk.Kind,
+					Namespace:  attrs.GetNamespace(),
+					Name:       attrs.GetName(),
+				}, corev1.EventTypeWarning, reason, message)
+			}
+		}
+	}
+}
+
+// Returns a reference to the polyfill's policy of a result. Events of cluster
+// scoped policies are emitted in the default namespace
+func (r *Recorder) policyReference(result evaluate.BindingResult) *corev1.ObjectReference {
+	reference := &corev1.ObjectReference{
+		APIVersion: polyfillv1alpha1.SchemeGroupVersion.String(),
+		Kind:       result.Kind(),
+		Namespace:  result.Namespace,
+		Name:       result.Policy,
+	}
+
+	// The UID lets kubectl describe find events of the policy
+	if len(result.Namespace) > 0 {
+		if policy, err := r.namespacedPolicyLister.NamespacedValidatingAdmissionPolicies(result.Namespace).Get(result.Policy); err == nil {
+			reference.UID = policy.UID
+		}
+	} else if policy, err := r.policyLister.Get(result.Policy); err == nil {
+		reference.UID = policy.UID
+	}
+	return reference
+}
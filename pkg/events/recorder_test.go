@@ -0,0 +1,132 @@
+package events_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/events"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := fake.NewSimpleClientset(&admissionregistrationv1alpha1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-limit", UID: "policy-uid"},
+	})
+
+	customClient := polyfillfake.NewSimpleClientset(&polyfillv1alpha1.NamespacedValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-limit", Namespace: "prod", UID: "namespaced-policy-uid"},
+	})
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	recorder := events.NewRecorder(factory, customFactory, client, true)
+	factory.Start(ctx.Done())
+	customFactory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+	go recorder.Run(ctx)
+
+	attrs := admission.NewAttributesRecord(
+		nil,
+		nil,
+		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		"prod",
+		"web",
+		schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		"",
+		admission.Create,
+		nil,
+		false,
+		&user.DefaultInfo{Name: "alice"},
+	)
+
+	denied := []evaluate.ValidationResult{{Outcome: evaluate.ValidationDeny, Message: "too many replicas"}}
+	recorder.RecordAdmission(attrs, []evaluate.BindingResult{
+		{
+			Policy:      "replica-limit",
+			Binding:     "replica-limit-prod",
+			Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny, admissionregistrationv1alpha1.Warn},
+			Validations: denied,
+		},
+		{
+			Policy:      "team-label",
+			Binding:     "team-label-all",
+			Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Warn},
+			Validations: []evaluate.ValidationResult{{Outcome: evaluate.ValidationDeny, Message: "missing team label"}},
+		},
+		{
+			Policy:      "tenant-limit",
+			Binding:     "tenant-limit-all",
+			Namespace:   "prod",
+			Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Audit},
+			Validations: denied,
+		},
+		{
+			// Passed validation
+			Policy:  "image-tag",
+			Binding: "image-tag-all",
+			Actions: []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+		},
+	})
+
+	var found []corev1.Event
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		list, err := client.CoreV1().Events("").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		found = list.Items
+		return len(found) == 6, nil
+	}); err != nil {
+		t.Fatalf("expected 6 events, got: %+v", found)
+	}
+
+	var messages []string
+	for _, event := range found {
+		if event.Type != corev1.EventTypeWarning {
+			t.Errorf("expected a Warning event, got %s", event.Type)
+		}
+
+		if event.InvolvedObject.Name == "replica-limit" && event.InvolvedObject.UID != "policy-uid" {
+			t.Errorf("expected the event of the policy to refer to its UID, got %q", event.InvolvedObject.UID)
+		}
+
+		if event.InvolvedObject.Name == "tenant-limit" && (event.InvolvedObject.Kind != "NamespacedValidatingAdmissionPolicy" || event.InvolvedObject.UID != "namespaced-policy-uid") {
+			t.Errorf("expected the event of the namespaced policy to refer to it, got %+v", event.InvolvedObject)
+		}
+		messages = append(messages, event.Namespace+" "+event.Reason+" "+event.InvolvedObject.Name+": "+event.Message)
+	}
+	sort.Strings(messages)
+
+	expected := []string{
+		"default PolicyDenied replica-limit: Denied CREATE of Deployment prod/web by alice with binding 'replica-limit-prod': too many replicas",
+		"default PolicyWarned team-label: Warned of CREATE of Deployment prod/web by alice with binding 'team-label-all': missing team label",
+		"prod PolicyAudited tenant-limit: Audited CREATE of Deployment prod/web by alice with binding 'tenant-limit-all': too many replicas",
+		"prod PolicyAudited web: Audited CREATE by NamespacedValidatingAdmissionPolicy 'tenant-limit' with binding 'tenant-limit-all': too many replicas",
+		"prod PolicyDenied web: Denied CREATE by ValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit-prod': too many replicas",
+		"prod PolicyWarned web: Warned of CREATE by ValidatingAdmissionPolicy 'team-label' with binding 'team-label-all': missing team label",
+	}
+
+	for i := range expected {
+		if messages[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], messages[i])
+		}
+	}
+}
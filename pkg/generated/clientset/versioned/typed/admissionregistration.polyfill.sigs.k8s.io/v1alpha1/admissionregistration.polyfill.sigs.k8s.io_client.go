@@ -28,6 +28,9 @@ import (
 
 type AdmissionregistrationV1alpha1Interface interface {
 	RESTClient() rest.Interface
+	NamespacedValidatingAdmissionPoliciesGetter
+	NamespacedValidatingAdmissionPolicyBindingsGetter
+	PolicyExemptionsGetter
 	ValidatingAdmissionPoliciesGetter
 	ValidatingAdmissionPolicyBindingsGetter
 }
@@ -37,6 +40,18 @@ type AdmissionregistrationV1alpha1Client struct {
 	restClient rest.Interface
 }
 
+func (c *AdmissionregistrationV1alpha1Client) NamespacedValidatingAdmissionPolicies(namespace string) NamespacedValidatingAdmissionPolicyInterface {
+	return newNamespacedValidatingAdmissionPolicies(c, namespace)
+}
+
+func (c *AdmissionregistrationV1alpha1Client) NamespacedValidatingAdmissionPolicyBindings(namespace string) NamespacedValidatingAdmissionPolicyBindingInterface {
+	return newNamespacedValidatingAdmissionPolicyBindings(c, namespace)
+}
+
+func (c *AdmissionregistrationV1alpha1Client) PolicyExemptions() PolicyExemptionInterface {
+	return newPolicyExemptions(c)
+}
+
 func (c *AdmissionregistrationV1alpha1Client) ValidatingAdmissionPolicies() ValidatingAdmissionPolicyInterface {
 	return newValidatingAdmissionPolicies(c)
 }
@@ -18,6 +18,12 @@ limitations under the License.
 
 package v1alpha1
 
+type NamespacedValidatingAdmissionPolicyExpansion interface{}
+
+type NamespacedValidatingAdmissionPolicyBindingExpansion interface{}
+
+type PolicyExemptionExpansion interface{}
+
 type ValidatingAdmissionPolicyExpansion interface{}
 
 type ValidatingAdmissionPolicyBindingExpansion interface{}
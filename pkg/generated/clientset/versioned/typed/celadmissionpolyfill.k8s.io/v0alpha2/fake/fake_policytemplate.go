@@ -101,6 +101,18 @@ func (c *FakePolicyTemplates) Update(ctx context.Context, policyTemplate *v0alph
 	return obj.(*v0alpha2.PolicyTemplate), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakePolicyTemplates) UpdateStatus(ctx context.Context, policyTemplate *v0alpha2.PolicyTemplate, opts v1.UpdateOptions) (*v0alpha2.PolicyTemplate, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(policytemplatesResource, "status", c.ns, policyTemplate), &v0alpha2.PolicyTemplate{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v0alpha2.PolicyTemplate), err
+}
+
 // Delete takes name of the policyTemplate and deletes it. Returns an error if one occurs.
 func (c *FakePolicyTemplates) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
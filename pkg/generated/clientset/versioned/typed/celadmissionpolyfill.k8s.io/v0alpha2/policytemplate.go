@@ -40,6 +40,7 @@ type PolicyTemplatesGetter interface {
 type PolicyTemplateInterface interface {
 	Create(ctx context.Context, policyTemplate *v0alpha2.PolicyTemplate, opts v1.CreateOptions) (*v0alpha2.PolicyTemplate, error)
 	Update(ctx context.Context, policyTemplate *v0alpha2.PolicyTemplate, opts v1.UpdateOptions) (*v0alpha2.PolicyTemplate, error)
+	UpdateStatus(ctx context.Context, policyTemplate *v0alpha2.PolicyTemplate, opts v1.UpdateOptions) (*v0alpha2.PolicyTemplate, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v0alpha2.PolicyTemplate, error)
@@ -135,6 +136,22 @@ func (c *policyTemplates) Update(ctx context.Context, policyTemplate *v0alpha2.P
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *policyTemplates) UpdateStatus(ctx context.Context, policyTemplate *v0alpha2.PolicyTemplate, opts v1.UpdateOptions) (result *v0alpha2.PolicyTemplate, err error) {
+	result = &v0alpha2.PolicyTemplate{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("policytemplates").
+		Name(policyTemplate.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(policyTemplate).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the policyTemplate and deletes it. Returns an error if one occurs.
 func (c *policyTemplates) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -24,6 +24,12 @@ import (
 
 // Interface provides access to all the informers in this group version.
 type Interface interface {
+	// NamespacedValidatingAdmissionPolicies returns a NamespacedValidatingAdmissionPolicyInformer.
+	NamespacedValidatingAdmissionPolicies() NamespacedValidatingAdmissionPolicyInformer
+	// NamespacedValidatingAdmissionPolicyBindings returns a NamespacedValidatingAdmissionPolicyBindingInformer.
+	NamespacedValidatingAdmissionPolicyBindings() NamespacedValidatingAdmissionPolicyBindingInformer
+	// PolicyExemptions returns a PolicyExemptionInformer.
+	PolicyExemptions() PolicyExemptionInformer
 	// ValidatingAdmissionPolicies returns a ValidatingAdmissionPolicyInformer.
 	ValidatingAdmissionPolicies() ValidatingAdmissionPolicyInformer
 	// ValidatingAdmissionPolicyBindings returns a ValidatingAdmissionPolicyBindingInformer.
@@ -41,6 +47,21 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
 }
 
+// NamespacedValidatingAdmissionPolicies returns a NamespacedValidatingAdmissionPolicyInformer.
+func (v *version) NamespacedValidatingAdmissionPolicies() NamespacedValidatingAdmissionPolicyInformer {
+	return &namespacedValidatingAdmissionPolicyInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// NamespacedValidatingAdmissionPolicyBindings returns a NamespacedValidatingAdmissionPolicyBindingInformer.
+func (v *version) NamespacedValidatingAdmissionPolicyBindings() NamespacedValidatingAdmissionPolicyBindingInformer {
+	return &namespacedValidatingAdmissionPolicyBindingInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
+// PolicyExemptions returns a PolicyExemptionInformer.
+func (v *version) PolicyExemptions() PolicyExemptionInformer {
+	return &policyExemptionInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
+
 // ValidatingAdmissionPolicies returns a ValidatingAdmissionPolicyInformer.
 func (v *version) ValidatingAdmissionPolicies() ValidatingAdmissionPolicyInformer {
 	return &validatingAdmissionPolicyInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
@@ -18,6 +18,26 @@ limitations under the License.
 
 package v1alpha1
 
+// NamespacedValidatingAdmissionPolicyListerExpansion allows custom methods to be added to
+// NamespacedValidatingAdmissionPolicyLister.
+type NamespacedValidatingAdmissionPolicyListerExpansion interface{}
+
+// NamespacedValidatingAdmissionPolicyNamespaceListerExpansion allows custom methods to be added to
+// NamespacedValidatingAdmissionPolicyNamespaceLister.
+type NamespacedValidatingAdmissionPolicyNamespaceListerExpansion interface{}
+
+// NamespacedValidatingAdmissionPolicyBindingListerExpansion allows custom methods to be added to
+// NamespacedValidatingAdmissionPolicyBindingLister.
+type NamespacedValidatingAdmissionPolicyBindingListerExpansion interface{}
+
+// NamespacedValidatingAdmissionPolicyBindingNamespaceListerExpansion allows custom methods to be added to
+// NamespacedValidatingAdmissionPolicyBindingNamespaceLister.
+type NamespacedValidatingAdmissionPolicyBindingNamespaceListerExpansion interface{}
+
+// PolicyExemptionListerExpansion allows custom methods to be added to
+// PolicyExemptionLister.
+type PolicyExemptionListerExpansion interface{}
+
 // ValidatingAdmissionPolicyListerExpansion allows custom methods to be added to
 // ValidatingAdmissionPolicyLister.
 type ValidatingAdmissionPolicyListerExpansion interface{}
@@ -0,0 +1,117 @@
+package impact
+
+import (
+	"encoding/json"
+	"net/url"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+// Whether an event records a write which passed through admission
+func isWrite(event *auditv1.Event) bool {
+	if event.ObjectRef == nil {
+		return false
+	}
+
+	// Each request is logged once per stage
+	if event.Stage != auditv1.StageResponseComplete && event.Stage != auditv1.StagePanic {
+		return false
+	}
+
+	switch event.Verb {
+	case "create", "update", "patch", "delete":
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the object in raw if it is of the requested resource rather than a
+// Status
+func objectOf(raw *runtime.Unknown) []byte {
+	if raw == nil || len(raw.Raw) == 0 {
+		return nil
+	}
+
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(raw.Raw, &meta); err != nil || meta.Kind == "Status" || len(meta.Kind) == 0 {
+		return nil
+	}
+	return raw.Raw
+}
+
+// Reconstructs the admission request of a write from its audit event. Only
+// events logged at the RequestResponse level include the objects of their
+// requests; nil is returned for writes whose objects were not logged.
+//
+// Audit events have no old object, so transition rules of UPDATEs see a null
+// oldObject. Patches are only reconstructed if they succeeded, from the
+// object they resulted in.
+func RequestFromEvent(event *auditv1.Event) *admissionv1.AdmissionRequest {
+	succeeded := event.ResponseStatus != nil && event.ResponseStatus.Code < 300
+	response := objectOf(event.ResponseObject)
+	if !succeeded {
+		response = nil
+	}
+
+	request := &admissionv1.AdmissionRequest{
+		UID: event.AuditID,
+		Resource: metav1.GroupVersionResource{
+			Group:    event.ObjectRef.APIGroup,
+			Version:  event.ObjectRef.APIVersion,
+			Resource: event.ObjectRef.Resource,
+		},
+		SubResource: event.ObjectRef.Subresource,
+		Namespace:   event.ObjectRef.Namespace,
+		Name:        event.ObjectRef.Name,
+		UserInfo:    event.User,
+	}
+
+	if event.ImpersonatedUser != nil {
+		request.UserInfo = *event.ImpersonatedUser
+	}
+
+	if uri, err := url.ParseRequestURI(event.RequestURI); err == nil && len(uri.Query().Get("dryRun")) > 0 {
+		dryRun := true
+		request.DryRun = &dryRun
+	}
+
+	switch event.Verb {
+	case "create":
+		request.Operation = admissionv1.Create
+		request.Object.Raw = response
+		if request.Object.Raw == nil {
+			request.Object.Raw = objectOf(event.RequestObject)
+		}
+	case "update":
+		request.Operation = admissionv1.Update
+		request.Object.Raw = response
+		if request.Object.Raw == nil {
+			request.Object.Raw = objectOf(event.RequestObject)
+		}
+	case "patch":
+		// Server-side apply may create the object it patches
+		request.Operation = admissionv1.Update
+		if event.ResponseStatus != nil && event.ResponseStatus.Code == 201 {
+			request.Operation = admissionv1.Create
+		}
+
+		// The request object is the patch itself
+		request.Object.Raw = response
+	case "delete":
+		request.Operation = admissionv1.Delete
+		request.OldObject.Raw = response
+		if request.OldObject.Raw == nil {
+			return nil
+		}
+		return request
+	}
+
+	if request.Object.Raw == nil {
+		return nil
+	}
+	return request
+}
@@ -0,0 +1,246 @@
+package impact
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+// Predicted effect of policies on the requests of audit logs
+type Report struct {
+	// Number of audit events read
+	Events int
+
+	// Number of writes evaluated against the policies
+	Evaluated int
+
+	// Number of writes whose objects were not logged, so could not be
+	// evaluated
+	Incomplete int
+
+	// Number of writes which could not be evaluated
+	Errors int
+
+	// Number of evaluated writes which would have been denied or warned of
+	Denied int
+	Warned int
+
+	// Bindings which would have denied or warned of requests, or failed to
+	// evaluate them, ordered by policy then binding name
+	Bindings []BindingImpact
+}
+
+type BindingImpact struct {
+	Policy  string
+	Binding string
+
+	Denied int
+	Warned int
+	Errors int
+
+	// Requests of the binding by namespace and user, ordered by namespace
+	// then user
+	Groups []GroupImpact
+}
+
+type GroupImpact struct {
+	Namespace string
+	User      string
+
+	Denied int
+	Warned int
+	Errors int
+}
+
+type bindingKey struct {
+	policy  string
+	binding string
+}
+
+type groupKey struct {
+	namespace string
+	user      string
+}
+
+// Accumulates the impact of the requests of one or more audit logs
+type Predictor struct {
+	evaluator *evaluate.Evaluator
+	report    Report
+	bindings  map[bindingKey]map[groupKey]*GroupImpact
+}
+
+func NewPredictor(evaluator *evaluate.Evaluator) *Predictor {
+	return &Predictor{evaluator: evaluator, bindings: map[bindingKey]map[groupKey]*GroupImpact{}}
+}
+
+// Evaluates the writes recorded by a stream of audit.k8s.io/v1 Events, one
+// per line, against the policies of evaluator
+func Predict(ctx context.Context, evaluator *evaluate.Evaluator, r io.Reader) (*Report, error) {
+	p := NewPredictor(evaluator)
+	if err := p.Read(ctx, r); err != nil {
+		return nil, err
+	}
+	return p.Report(), nil
+}
+
+// Evaluates the writes of an audit log like Predict, adding them to the
+// report of the predictor
+func (p *Predictor) Read(ctx context.Context, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(data) == 0 && errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		event := &auditv1.Event{}
+		if err := json.Unmarshal(data, event); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		p.report.Events++
+
+		if !isWrite(event) {
+			continue
+		}
+
+		request := RequestFromEvent(event)
+		if request == nil {
+			p.report.Incomplete++
+			continue
+		}
+
+		decision, err := p.evaluator.Evaluate(ctx, request)
+		if err != nil {
+			p.report.Errors++
+			continue
+		}
+		p.report.Evaluated++
+		p.record(request.Namespace, request.UserInfo.Username, decision)
+	}
+}
+
+func (p *Predictor) record(namespace, user string, decision *evaluate.Decision) {
+	if !decision.Allowed {
+		p.report.Denied++
+	}
+	if len(decision.Warnings) > 0 {
+		p.report.Warned++
+	}
+
+	for _, binding := range decision.Bindings {
+		failed, errored := false, len(binding.Error) > 0
+		for _, validation := range binding.Validations {
+			switch validation.Outcome {
+			case evaluate.ValidationDeny:
+				failed = true
+			case evaluate.ValidationError:
+				errored = true
+			}
+		}
+
+		if !failed && !errored {
+			continue
+		}
+
+		key := bindingKey{policy: binding.Policy, binding: binding.Binding}
+		groups, ok := p.bindings[key]
+		if !ok {
+			groups = map[groupKey]*GroupImpact{}
+			p.bindings[key] = groups
+		}
+
+		group, ok := groups[groupKey{namespace: namespace, user: user}]
+		if !ok {
+			group = &GroupImpact{Namespace: namespace, User: user}
+			groups[groupKey{namespace: namespace, user: user}] = group
+		}
+
+		if errored {
+			group.Errors++
+			continue
+		}
+
+		for _, action := range binding.Actions {
+			switch action {
+			case admissionregistrationv1alpha1.Deny:
+				group.Denied++
+			case admissionregistrationv1alpha1.Warn:
+				group.Warned++
+			}
+		}
+	}
+}
+
+// Returns the report of the audit logs read so far
+func (p *Predictor) Report() *Report {
+	report := p.report
+	report.Bindings = nil
+	for key, groups := range p.bindings {
+		impact := BindingImpact{Policy: key.policy, Binding: key.binding}
+		for _, group := range groups {
+			impact.Denied += group.Denied
+			impact.Warned += group.Warned
+			impact.Errors += group.Errors
+			impact.Groups = append(impact.Groups, *group)
+		}
+
+		sort.Slice(impact.Groups, func(i, j int) bool {
+			if impact.Groups[i].Namespace != impact.Groups[j].Namespace {
+				return impact.Groups[i].Namespace < impact.Groups[j].Namespace
+			}
+			return impact.Groups[i].User < impact.Groups[j].User
+		})
+		report.Bindings = append(report.Bindings, impact)
+	}
+
+	sort.Slice(report.Bindings, func(i, j int) bool {
+		if report.Bindings[i].Policy != report.Bindings[j].Policy {
+			return report.Bindings[i].Policy < report.Bindings[j].Policy
+		}
+		return report.Bindings[i].Binding < report.Bindings[j].Binding
+	})
+	return &report
+}
+
+// Writes a table of the requests each binding would have denied or warned
+// of, by namespace and user, followed by a summary
+func WriteReport(w io.Writer, report *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if len(report.Bindings) > 0 {
+		fmt.Fprintln(tw, "POLICY\tBINDING\tNAMESPACE\tUSER\tDENIED\tWARNED\tERRORS")
+	}
+
+	for _, binding := range report.Bindings {
+		for _, group := range binding.Groups {
+			namespace := group.Namespace
+			if len(namespace) == 0 {
+				namespace = "<none>"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\n", binding.Policy, binding.Binding, namespace, group.User, group.Denied, group.Warned, group.Errors)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "read %d audit events: evaluated %d writes, %d would be denied, %d warned of; %d writes were logged without their objects, %d could not be evaluated\n",
+		report.Events, report.Evaluated, report.Denied, report.Warned, report.Incomplete, report.Errors)
+	return err
+}
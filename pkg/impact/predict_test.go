@@ -0,0 +1,95 @@
+package impact_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/impact"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func loadEvaluator(t *testing.T, ctx context.Context, path string) *evaluate.Evaluator {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var objects []*unstructured.Unstructured
+	decoder := yamlutil.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, obj)
+	}
+
+	resources, err := evaluate.ResourcesFromObjects(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluator, err := evaluate.New(ctx, resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return evaluator
+}
+
+func TestPredict(t *testing.T) {
+	ctx := context.Background()
+	evaluator := loadEvaluator(t, ctx, "testdata/policy.yaml")
+	defer evaluator.Close()
+
+	events, err := os.Open("testdata/audit.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	report, err := impact.Predict(ctx, evaluator, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The request received stage and the get are not writes. The metadata
+	// level create and the delete responded to with a Status have no objects
+	if report.Events != 8 || report.Evaluated != 4 || report.Incomplete != 2 || report.Errors != 0 {
+		t.Errorf("unexpected counts: %+v", report)
+	}
+
+	// The create of huge was denied by the apiserver, but is evaluated from
+	// its request object
+	if report.Denied != 2 || report.Warned != 3 {
+		t.Errorf("expected 2 writes to be denied and 3 warned of, got %d and %d", report.Denied, report.Warned)
+	}
+
+	var out strings.Builder
+	if err := impact.WriteReport(&out, report); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes made while impersonating are attributed to the impersonated user
+	expected := `POLICY         BINDING             NAMESPACE  USER   DENIED  WARNED  ERRORS
+replica-limit  replica-limit-all   dev        bob    0       1       0
+replica-limit  replica-limit-all   prod       alice  0       1       0
+replica-limit  replica-limit-all   prod       carol  0       1       0
+replica-limit  replica-limit-prod  prod       alice  1       0       0
+replica-limit  replica-limit-prod  prod       carol  1       0       0
+read 8 audit events: evaluated 4 writes, 2 would be denied, 3 warned of; 2 writes were logged without their objects, 0 could not be evaluated
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
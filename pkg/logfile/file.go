@@ -0,0 +1,100 @@
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Defaults of the files of the decision log and of captured reviews
+const (
+	DefaultMaxSize    = 100 * 1024 * 1024
+	DefaultMaxBackups = 5
+)
+
+// A file of lines which is appended to. Once the file would exceed maxSize it
+// is renamed to path.1, and so on up to maxBackups, and a new file is
+// started. Only used by a single goroutine at a time
+type File struct {
+	path       string
+	perm       os.FileMode
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// Opens the file at path for appending, creating it with perm if it does not
+// exist
+func Open(path string, perm os.FileMode, maxSize int64, maxBackups int) (*File, error) {
+	f := &File{path: path, perm: perm, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, f.perm)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", f.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening %s: %w", f.path, err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Appends a line, rotating the file first if it would exceed its maximum
+// size. Lines are never split across files
+func (f *File) Write(line []byte) (int, error) {
+	if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	backup := func(i int) string {
+		return f.path + "." + strconv.Itoa(i)
+	}
+
+	// The oldest backup is overwritten
+	for i := f.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("rotating %s: %w", f.path, err)
+		}
+	}
+
+	var err error
+	if f.maxBackups > 0 {
+		err = os.Rename(f.path, backup(1))
+	} else {
+		err = os.Remove(f.path)
+	}
+	if err != nil {
+		return fmt.Errorf("rotating %s: %w", f.path, err)
+	}
+	return f.open()
+}
+
+func (f *File) Close() error {
+	return f.file.Close()
+}
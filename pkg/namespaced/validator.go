@@ -0,0 +1,225 @@
+package namespaced
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	polyfilllisters "github.com/alexzielenski/cel_polyfill/pkg/generated/listers/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/matching"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Validates requests for objects in a namespace against the
+// NamespacedValidatingAdmissionPolicies and bindings of that namespace. Only
+// the policies of the namespace of a request are evaluated, and their params
+// are only fetched from that namespace, so no selector of a tenant can reach
+// the objects of another namespace. Cluster scoped objects, including the
+// namespaces themselves, are never validated.
+type Validator struct {
+	policyLister  polyfilllisters.NamespacedValidatingAdmissionPolicyLister
+	bindingLister polyfilllisters.NamespacedValidatingAdmissionPolicyBindingLister
+	hasSynced     []cache.InformerSynced
+
+	restMapper meta.RESTMapper
+	matcher    validatingadmissionpolicy.Matcher
+	params     *evaluate.ParamCache
+}
+
+func NewValidator(
+	factory informers.SharedInformerFactory,
+	customFactory externalversions.SharedInformerFactory,
+	client kubernetes.Interface,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
+) *Validator {
+	policies := customFactory.Admissionregistration().V1alpha1().NamespacedValidatingAdmissionPolicies()
+	bindings := customFactory.Admissionregistration().V1alpha1().NamespacedValidatingAdmissionPolicyBindings()
+	namespaces := factory.Core().V1().Namespaces()
+
+	return &Validator{
+		policyLister:  policies.Lister(),
+		bindingLister: bindings.Lister(),
+		hasSynced: []cache.InformerSynced{
+			policies.Informer().HasSynced,
+			bindings.Informer().HasSynced,
+			namespaces.Informer().HasSynced,
+		},
+		restMapper: restMapper,
+		matcher:    validatingadmissionpolicy.NewMatcher(matching.NewMatcher(namespaces.Lister(), client)),
+		params:     evaluate.NewDefaultParamCache(restMapper, dynamicClient),
+	}
+}
+
+func (v *Validator) HasSynced() bool {
+	for _, hasSynced := range v.hasSynced {
+		if !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *Validator) Handles(operation admission.Operation) bool {
+	return true
+}
+
+func (v *Validator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	namespace := a.GetNamespace()
+	if len(namespace) == 0 || isClusterScoped(a) || isNamespacedPolicyResource(a) {
+		return nil
+	}
+
+	if err := wait.PollImmediateWithContext(ctx, 100*time.Millisecond, 1*time.Second, func(ctx context.Context) (done bool, err error) {
+		return v.HasSynced(), nil
+	}); err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("not yet ready to handle request"))
+	}
+
+	crdPolicies, err := v.policyLister.NamespacedValidatingAdmissionPolicies(namespace).List(labels.Everything())
+	if err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("listing namespaced policies: %w", err))
+	} else if len(crdPolicies) == 0 {
+		return nil
+	}
+
+	crdBindings, err := v.bindingLister.NamespacedValidatingAdmissionPolicyBindings(namespace).List(labels.Everything())
+	if err != nil {
+		return admission.NewForbidden(a, fmt.Errorf("listing namespaced bindings: %w", err))
+	}
+
+	var policies []*admissionregistrationv1alpha1.ValidatingAdmissionPolicy
+	for _, crdPolicy := range crdPolicies {
+		policy, err := NativePolicy(crdPolicy)
+		if err != nil {
+			return admission.NewForbidden(a, fmt.Errorf("converting namespaced policy %s: %w", crdPolicy.Name, err))
+		}
+		policies = append(policies, policy)
+	}
+
+	var bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+	for _, crdBinding := range crdBindings {
+		binding, err := NativePolicyBinding(crdBinding)
+		if err != nil {
+			return admission.NewForbidden(a, fmt.Errorf("converting namespaced binding %s: %w", crdBinding.Name, err))
+		}
+		bindings = append(bindings, binding)
+	}
+
+	results := evaluate.NewExplainer(v.matcher, v.resolver(ctx, namespace)).Explain(ctx, a, policies, bindings)
+	for i := range results {
+		results[i].Namespace = namespace
+	}
+
+	evaluate.AddResults(ctx, results...)
+	return evaluate.Enforce(ctx, a, results)
+}
+
+// Returns a ParamResolver which only resolves namespaced params in namespace
+func (v *Validator) resolver(ctx context.Context, namespace string) evaluate.ParamResolver {
+	resolve := v.params.Resolver(ctx)
+	return func(paramKind *admissionregistrationv1alpha1.ParamKind, ref *admissionregistrationv1alpha1.ParamRef) (runtime.Object, error) {
+		gv, err := schema.ParseGroupVersion(paramKind.APIVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		mapping, err := v.restMapper.RESTMapping(gv.WithKind(paramKind.Kind).GroupKind(), gv.Version)
+		if err != nil {
+			return nil, err
+		} else if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			return nil, fmt.Errorf("paramKind %s of a namespaced policy must be namespaced", paramKind.Kind)
+		}
+
+		if ref.Namespace != namespace {
+			return nil, fmt.Errorf("params of a namespaced policy must be in namespace %s", namespace)
+		}
+		return resolve(paramKind, ref)
+	}
+}
+
+// Converts a NamespacedValidatingAdmissionPolicy to the ValidatingAdmissionPolicy
+// it is evaluated as, filling in the defaults of the CRD of
+// ValidatingAdmissionPolicies
+func NativePolicy(policy *polyfillv1alpha1.NamespacedValidatingAdmissionPolicy) (*admissionregistrationv1alpha1.ValidatingAdmissionPolicy, error) {
+	spec := policy.Spec.DeepCopy()
+	spec.MatchConstraints = withDefaults(spec.MatchConstraints)
+	if spec.FailurePolicy == nil {
+		fail := polyfillv1alpha1.Fail
+		spec.FailurePolicy = &fail
+	}
+
+	return controllerv1alpha1.CRDToNativePolicy(&polyfillv1alpha1.ValidatingAdmissionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: policy.Name, UID: policy.UID, Generation: policy.Generation},
+		Spec:       *spec,
+	})
+}
+
+// Converts a NamespacedValidatingAdmissionPolicyBinding to the
+// ValidatingAdmissionPolicyBinding it is evaluated as. Its params are always
+// in its own namespace.
+func NativePolicyBinding(binding *polyfillv1alpha1.NamespacedValidatingAdmissionPolicyBinding) (*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding, error) {
+	spec := polyfillv1alpha1.ValidatingAdmissionPolicyBindingSpec{
+		PolicyName:        binding.Spec.PolicyName,
+		MatchResources:    withDefaults(binding.Spec.MatchResources.DeepCopy()),
+		ValidationActions: binding.Spec.ValidationActions,
+	}
+
+	if binding.Spec.ParamRef != nil {
+		spec.ParamRef = &polyfillv1alpha1.ParamRef{Name: binding.Spec.ParamRef.Name, Namespace: binding.Namespace}
+	}
+
+	return controllerv1alpha1.CRDToNativePolicyBinding(&polyfillv1alpha1.ValidatingAdmissionPolicyBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: binding.Name, UID: binding.UID, Generation: binding.Generation},
+		Spec:       spec,
+	})
+}
+
+// Selectors which are unset match everything, like the defaults of the CRDs
+// of ValidatingAdmissionPolicies and bindings
+func withDefaults(match *polyfillv1alpha1.MatchResources) *polyfillv1alpha1.MatchResources {
+	if match == nil {
+		match = &polyfillv1alpha1.MatchResources{}
+	}
+	if match.NamespaceSelector == nil {
+		match.NamespaceSelector = &metav1.LabelSelector{}
+	}
+	if match.ObjectSelector == nil {
+		match.ObjectSelector = &metav1.LabelSelector{}
+	}
+	if match.MatchPolicy == nil {
+		equivalent := polyfillv1alpha1.Equivalent
+		match.MatchPolicy = &equivalent
+	}
+	return match
+}
+
+// Namespaces are cluster scoped, but their requests carry their own name as
+// namespace
+func isClusterScoped(a admission.Attributes) bool {
+	resource := a.GetResource()
+	return resource.Group == "" && resource.Resource == "namespaces"
+}
+
+// Tenants must remain able to fix their policies while one denies everything
+func isNamespacedPolicyResource(a admission.Attributes) bool {
+	resource := a.GetResource()
+	return resource.Group == polyfillv1alpha1.GroupName &&
+		(resource.Resource == "namespacedvalidatingadmissionpolicies" || resource.Resource == "namespacedvalidatingadmissionpolicybindings")
+}
@@ -0,0 +1,160 @@
+package namespaced_test
+
+import (
+	"context"
+	"testing"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	"github.com/alexzielenski/cel_polyfill/pkg/namespaced"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/warning"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Returns the attributes of a request creating the Deployment web in
+// namespace
+func request(namespace string, replicas int64) admission.Attributes {
+	return evaluatetest.DeploymentAttributes(evaluatetest.Deployment(namespace, "web", replicas, nil), &user.DefaultInfo{Name: "alice"})
+}
+
+func limits(namespace, maxReplicas string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "limits", "namespace": namespace},
+		"data":       map[string]interface{}{"maxReplicas": maxReplicas},
+	}}
+}
+
+func TestValidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configMaps := &polyfillv1alpha1.ParamKind{APIVersion: "v1", Kind: "ConfigMap"}
+	nodes := &polyfillv1alpha1.ParamKind{APIVersion: "v1", Kind: "Node"}
+
+	// The selectors of the binding in team-a cannot select team-b
+	everywhere := evaluatetest.NamespacedBinding("team-a", "replica-limit", "replica-limit", "limits", polyfillv1alpha1.Deny)
+	everywhere.Spec.MatchResources = &polyfillv1alpha1.MatchResources{
+		NamespaceSelector: &metav1.LabelSelector{},
+		ObjectSelector:    &metav1.LabelSelector{},
+	}
+
+	customClient := polyfillfake.NewSimpleClientset(
+		evaluatetest.NamespacedPolicy("team-a", "replica-limit", configMaps, "object.spec.replicas <= int(params.data.maxReplicas)", "too many replicas"),
+		everywhere,
+		evaluatetest.NamespacedPolicy("team-a", "odd-replicas", nil, "object.spec.replicas % 2 == 1", "replicas should be odd"),
+		evaluatetest.NamespacedBinding("team-a", "odd-replicas", "odd-replicas", "", polyfillv1alpha1.Warn),
+		evaluatetest.NamespacedPolicy("team-c", "node-limit", nodes, "object.spec.replicas <= 1", "too many replicas"),
+		evaluatetest.NamespacedBinding("team-c", "node-limit", "node-limit", "worker", polyfillv1alpha1.Deny),
+	)
+
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-c"}},
+	)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		limits("team-a", "3"),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Node",
+			"metadata":   map[string]interface{}{"name": "worker"},
+		}},
+	)
+
+	restMapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	validator := namespaced.NewValidator(factory, customFactory, client, restMapper, dynamicClient)
+	factory.Start(ctx.Done())
+	customFactory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+
+	cases := []struct {
+		name     string
+		attrs    admission.Attributes
+		expected string
+		warnings []string
+	}{
+		{
+			name:  "allowed",
+			attrs: request("team-a", 3),
+		},
+		{
+			name:     "denied",
+			attrs:    request("team-a", 4),
+			expected: "deployments.apps \"web\" is forbidden: NamespacedValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit' denied request: too many replicas",
+			warnings: []string{"Validation failed for NamespacedValidatingAdmissionPolicy 'odd-replicas' with binding 'odd-replicas': replicas should be odd"},
+		},
+		{
+			name:  "other namespace",
+			attrs: request("team-b", 4),
+		},
+		{
+			name:     "cluster scoped params",
+			attrs:    request("team-c", 1),
+			expected: "deployments.apps \"web\" is forbidden: NamespacedValidatingAdmissionPolicy 'node-limit' with binding 'node-limit' denied request: paramKind Node of a namespaced policy must be namespaced",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			warnings := evaluate.NewWarningCollector(ctx)
+			err := validator.Validate(warning.WithWarningRecorder(ctx, warnings), c.attrs, admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+
+			message := ""
+			if err != nil {
+				message = err.Error()
+			}
+
+			if message != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, message)
+			}
+
+			actual := warnings.Warnings()
+			if len(actual) != len(c.warnings) {
+				t.Fatalf("expected warnings %q, got %q", c.warnings, actual)
+			}
+
+			for i := range c.warnings {
+				if actual[i] != c.warnings[i] {
+					t.Errorf("expected warning %q, got %q", c.warnings[i], actual[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNativePolicyBinding(t *testing.T) {
+	native, err := namespaced.NativePolicyBinding(evaluatetest.NamespacedBinding("team-a", "replica-limit", "replica-limit", "limits", polyfillv1alpha1.Deny))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if native.Spec.ParamRef == nil || native.Spec.ParamRef.Namespace != "team-a" || native.Spec.ParamRef.Name != "limits" {
+		t.Errorf("expected params in team-a, got %+v", native.Spec.ParamRef)
+	}
+
+	if match := native.Spec.MatchResources; match == nil || match.NamespaceSelector == nil || match.ObjectSelector == nil || match.MatchPolicy == nil {
+		t.Errorf("expected defaulted matchResources, got %+v", match)
+	}
+}
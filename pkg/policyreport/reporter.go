@@ -0,0 +1,392 @@
+package policyreport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/audit"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// Name of the report written to each namespace, and of the cluster report
+	reportName = "cel-admission-polyfill"
+
+	// Source of every result written by the reporter
+	source = "cel-admission-polyfill"
+
+	// Categories of results, by how the violations were found
+	categoryAudit     = "audit"
+	categoryAdmission = "admission"
+
+	// Number of resources listed by each result
+	resourcesPerResult = 10
+)
+
+// Provides the report of the last audit scan. Implemented by *audit.Scanner
+type AuditSource interface {
+	Report() *audit.Report
+}
+
+type resultKey struct {
+	namespace string
+	kind      string
+	policy    string
+	binding   string
+	result    PolicyResult
+}
+
+// Writes the violations found by the audit scanner and by admission to a
+// PolicyReport in each namespace, aggregated by policy and binding.
+// Violations by cluster scoped objects are written to a ClusterPolicyReport.
+type Reporter struct {
+	dynamicClient dynamic.Interface
+
+	// May be nil, in which case only admission is reported
+	scanner  AuditSource
+	interval time.Duration
+
+	// Violations are reported until admission has not seen them again for
+	// admissionTTL
+	admissionTTL time.Duration
+
+	lock sync.Mutex
+
+	// Violations seen by admission within admissionTTL
+	admission map[resultKey]*PolicyReportResult
+
+	// Results last written to each namespace
+	written map[string][]PolicyReportResult
+}
+
+func NewReporter(dynamicClient dynamic.Interface, scanner AuditSource, interval, admissionTTL time.Duration) *Reporter {
+	return &Reporter{
+		dynamicClient: dynamicClient,
+		scanner:       scanner,
+		interval:      interval,
+		admissionTTL:  admissionTTL,
+		admission:     map[resultKey]*PolicyReportResult{},
+		written:       map[string][]PolicyReportResult{},
+	}
+}
+
+// Writes reports every interval until ctx is cancelled
+func (r *Reporter) Run(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.Sync(ctx); err != nil {
+			utilruntime.HandleError(fmt.Errorf("failed to write policy reports: %w", err))
+		}
+	}, r.interval)
+	return nil
+}
+
+// Writes the reports of every namespace whose results changed since they were
+// last written
+func (r *Reporter) Sync(ctx context.Context) error {
+	desired := r.results()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Namespaces which no longer have results are written empty
+	for namespace := range r.written {
+		if _, found := desired[namespace]; !found {
+			desired[namespace] = nil
+		}
+	}
+
+	var errs []error
+	for namespace, results := range desired {
+		if written, found := r.written[namespace]; found && equality.Semantic.DeepEqual(written, results) {
+			continue
+		}
+
+		if err := r.write(ctx, namespace, results); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if len(results) == 0 {
+			delete(r.written, namespace)
+		} else {
+			r.written[namespace] = results
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Returns the results of each namespace, ordered by policy, binding and
+// category
+func (r *Reporter) results() map[string][]PolicyReportResult {
+	results := map[string][]PolicyReportResult{}
+
+	if r.scanner != nil {
+		if report := r.scanner.Report(); report != nil {
+			for _, violations := range report.Violations {
+				for namespace, count := range violations.Namespaces {
+					results[namespace] = append(results[namespace], auditResult(report, violations, namespace, count))
+				}
+				for namespace, count := range violations.ErrorNamespaces {
+					results[namespace] = append(results[namespace], auditErrorResult(report, violations, namespace, count))
+				}
+			}
+		}
+	}
+
+	// Violations of objects which were since fixed or deleted are forgotten
+	// once they expire
+	expired := time.Now().Add(-r.admissionTTL)
+
+	r.lock.Lock()
+	for key, result := range r.admission {
+		if time.Unix(result.Timestamp.Seconds, int64(result.Timestamp.Nanos)).Before(expired) {
+			delete(r.admission, key)
+			continue
+		}
+		results[key.namespace] = append(results[key.namespace], result.deepCopy())
+	}
+	r.lock.Unlock()
+
+	for _, namespaceResults := range results {
+		sort.Slice(namespaceResults, func(i, j int) bool {
+			a, b := namespaceResults[i], namespaceResults[j]
+			if a.Policy != b.Policy {
+				return a.Policy < b.Policy
+			} else if a.Rule != b.Rule {
+				return a.Rule < b.Rule
+			} else if a.Category != b.Category {
+				return a.Category < b.Category
+			}
+			return a.Result < b.Result
+		})
+	}
+	return results
+}
+
+// Summarizes the violations of a binding by existing objects in a namespace
+func auditResult(report *audit.Report, violations audit.Violations, namespace string, count int) PolicyReportResult {
+	result := PolicyReportResult{
+		Source:    source,
+		Policy:    violations.Policy,
+		Rule:      violations.Binding,
+		Category:  categoryAudit,
+		Timestamp: timestamp(report.Completed),
+		Result:    PolicyResultWarn,
+		Message:   fmt.Sprintf("%d existing objects fail validation", count),
+		Properties: map[string]string{
+			"count": strconv.Itoa(count),
+		},
+	}
+
+	for _, action := range violations.Actions {
+		if action == admissionregistrationv1alpha1.Deny {
+			result.Result = PolicyResultFail
+		}
+	}
+
+	for _, sample := range violations.Samples {
+		if sample.Object.Namespace != namespace || len(sample.Messages) == 0 {
+			continue
+		}
+
+		if len(result.Resources) == 0 {
+			result.Message += ": " + sample.Messages[0]
+		}
+		result.Resources = append(result.Resources, sample.Object)
+	}
+	return result
+}
+
+// Summarizes the existing objects in a namespace a binding could not be
+// evaluated for
+func auditErrorResult(report *audit.Report, violations audit.Violations, namespace string, count int) PolicyReportResult {
+	result := PolicyReportResult{
+		Source:    source,
+		Policy:    violations.Policy,
+		Rule:      violations.Binding,
+		Category:  categoryAudit,
+		Timestamp: timestamp(report.Completed),
+		Result:    PolicyResultError,
+		Message:   fmt.Sprintf("%d existing objects could not be evaluated", count),
+		Properties: map[string]string{
+			"count": strconv.Itoa(count),
+		},
+	}
+
+	for _, sample := range violations.Samples {
+		if sample.Object.Namespace != namespace || len(sample.Errors) == 0 {
+			continue
+		}
+
+		if len(result.Resources) == 0 {
+			result.Message += ": " + sample.Errors[0]
+		}
+		result.Resources = append(result.Resources, sample.Object)
+	}
+	return result
+}
+
+// Records the bindings a request failed validation of, or which could not be
+// evaluated. Passed to validator.NewObserved
+func (r *Reporter) RecordAdmission(attrs admission.Attributes, results []evaluate.BindingResult) {
+	gvk := attrs.GetKind()
+	resource := corev1.ObjectReference{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  attrs.GetNamespace(),
+		Name:       attrs.GetName(),
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, result := range results {
+		key := resultKey{namespace: attrs.GetNamespace(), kind: result.Kind(), policy: result.Policy, binding: result.Binding}
+
+		if len(result.Error) > 0 {
+			key.result = PolicyResultError
+			r.recordAdmission(key, resource, result.Error)
+			continue
+		}
+
+		denials := result.Denials()
+		if len(denials) == 0 {
+			continue
+		}
+
+		key.result = PolicyResultWarn
+		if result.HasAction(admissionregistrationv1alpha1.Deny) {
+			key.result = PolicyResultFail
+		}
+		r.recordAdmission(key, resource, denials[0].Message)
+	}
+}
+
+// Counts a request towards the result of key. The lock must be held
+func (r *Reporter) recordAdmission(key resultKey, resource corev1.ObjectReference, message string) {
+	result, found := r.admission[key]
+	if !found {
+		result = &PolicyReportResult{
+			Source:     source,
+			Policy:     key.policy,
+			Rule:       key.binding,
+			Category:   categoryAdmission,
+			Result:     key.result,
+			Properties: map[string]string{"count": "0"},
+		}
+
+		// Namespaced policies may share the name of a cluster policy
+		if key.kind != "ValidatingAdmissionPolicy" {
+			result.Properties["policyKind"] = key.kind
+		}
+		r.admission[key] = result
+	}
+
+	count, _ := strconv.Atoi(result.Properties["count"])
+	result.Properties["count"] = strconv.Itoa(count + 1)
+	result.Timestamp = timestamp(time.Now())
+	result.Message = message
+
+	// The most recent resources are listed, each once
+	resources := []corev1.ObjectReference{resource}
+	for _, existing := range result.Resources {
+		if existing != resource && len(resources) < resourcesPerResult {
+			resources = append(resources, existing)
+		}
+	}
+	result.Resources = resources
+}
+
+// Creates or updates the report of a namespace
+func (r *Reporter) write(ctx context.Context, namespace string, results []PolicyReportResult) error {
+	report := &PolicyReport{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersion.String(),
+			Kind:       "PolicyReport",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      reportName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": source,
+			},
+		},
+		Results: results,
+	}
+
+	client := r.dynamicClient.Resource(policyReportsResource).Namespace(namespace)
+	if len(namespace) == 0 {
+		report.Kind = "ClusterPolicyReport"
+		client = r.dynamicClient.Resource(clusterPolicyReportsResource)
+	}
+
+	for _, result := range results {
+		switch result.Result {
+		case PolicyResultPass:
+			report.Summary.Pass++
+		case PolicyResultFail:
+			report.Summary.Fail++
+		case PolicyResultWarn:
+			report.Summary.Warn++
+		case PolicyResultError:
+			report.Summary.Error++
+		case PolicyResultSkip:
+			report.Summary.Skip++
+		}
+	}
+
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(report)
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{Object: raw}
+
+	existing, err := client.Get(ctx, reportName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		if len(results) == 0 {
+			return nil
+		}
+
+		_, err = client.Create(ctx, obj, metav1.CreateOptions{})
+	} else if err == nil {
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		_, err = client.Update(ctx, obj, metav1.UpdateOptions{})
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", report.Kind, namespace, err)
+	}
+	return nil
+}
+
+// Copies the result, which is otherwise changed by later admission requests
+func (r PolicyReportResult) deepCopy() PolicyReportResult {
+	r.Resources = append([]corev1.ObjectReference(nil), r.Resources...)
+
+	properties := make(map[string]string, len(r.Properties))
+	for key, value := range r.Properties {
+		properties[key] = value
+	}
+	r.Properties = properties
+	return r
+}
+
+func timestamp(t time.Time) metav1.Timestamp {
+	return metav1.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+}
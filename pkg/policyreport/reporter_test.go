@@ -0,0 +1,242 @@
+package policyreport_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/audit"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	"github.com/alexzielenski/cel_polyfill/pkg/policyreport"
+	"github.com/alexzielenski/cel_polyfill/pkg/validator"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/warning"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+type auditSource struct {
+	report *audit.Report
+}
+
+func (s *auditSource) Report() *audit.Report {
+	return s.report
+}
+
+// team-label-all warns of every request, replica-limit-prod denies those
+// named big, and its param is missing for those named small
+var stubValidator = evaluatetest.Validator(func(a admission.Attributes) []evaluate.BindingResult {
+	results := []evaluate.BindingResult{{
+		Policy:      "team-label",
+		Binding:     "team-label-all",
+		Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Warn},
+		Validations: []evaluate.ValidationResult{{Outcome: evaluate.ValidationDeny, Message: "missing team label"}},
+	}}
+
+	replicaLimit := evaluate.BindingResult{
+		Policy:        "replica-limit",
+		Binding:       "replica-limit-prod",
+		Actions:       []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+		FailurePolicy: admissionregistrationv1alpha1.Ignore,
+	}
+	switch a.GetName() {
+	case "big":
+		replicaLimit.Validations = []evaluate.ValidationResult{{Outcome: evaluate.ValidationDeny, Message: "too many replicas"}}
+		results = append(results, replicaLimit)
+	case "small":
+		replicaLimit.Error = "param not found"
+		results = append(results, replicaLimit)
+	}
+	return results
+})
+
+var (
+	policyReports        = policyreport.SchemeGroupVersion.WithResource("policyreports")
+	clusterPolicyReports = policyreport.SchemeGroupVersion.WithResource("clusterpolicyreports")
+)
+
+func getReport(t *testing.T, client *dynamicfake.FakeDynamicClient, namespace string) *policyreport.PolicyReport {
+	t.Helper()
+
+	resource := client.Resource(policyReports).Namespace(namespace)
+	if len(namespace) == 0 {
+		resource = client.Resource(clusterPolicyReports)
+	}
+
+	obj, err := resource.Get(context.Background(), "cel-admission-polyfill", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := &policyreport.PolicyReport{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func request(namespace, name string) admission.Attributes {
+	return evaluatetest.DeploymentAttributes(evaluatetest.Deployment(namespace, name, 1, nil), &user.DefaultInfo{Name: "alice"})
+}
+
+func TestReporter(t *testing.T) {
+	ctx := context.Background()
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		policyReports:        "PolicyReportList",
+		clusterPolicyReports: "ClusterPolicyReportList",
+	})
+
+	source := &auditSource{report: &audit.Report{
+		Completed: time.Unix(1000, 0),
+		Violations: []audit.Violations{
+			{
+				Policy:          "replica-limit",
+				Binding:         "replica-limit-prod",
+				Actions:         []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+				Count:           1,
+				Namespaces:      map[string]int{"prod": 1},
+				Errors:          1,
+				ErrorNamespaces: map[string]int{"prod": 1},
+				Samples: []audit.Sample{
+					{
+						Object:   corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "old"},
+						Messages: []string{"too many replicas"},
+					},
+					{
+						Object: corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "broken"},
+						Errors: []string{"no such key: replicas"},
+					},
+				},
+			},
+			{
+				Policy:     "node-labels",
+				Binding:    "node-labels",
+				Actions:    []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Warn},
+				Count:      2,
+				Namespaces: map[string]int{"": 2},
+			},
+		},
+	}}
+
+	reporter := policyreport.NewReporter(client, source, time.Minute, time.Hour)
+	observed := validator.NewObserved(stubValidator, reporter.RecordAdmission)
+
+	warnings := evaluate.NewWarningCollector(ctx)
+	for _, name := range []string{"big", "big", "small"} {
+		observed.Validate(warning.WithWarningRecorder(ctx, warnings), request("prod", name), nil)
+	}
+
+	if len(warnings.Warnings()) != 3 {
+		t.Errorf("expected warnings to be passed on, got: %q", warnings.Warnings())
+	}
+
+	if err := reporter.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	report := getReport(t, client, "prod")
+	expected := []string{
+		"replica-limit/replica-limit-prod admission error count=1: param not found [prod/small]",
+		"replica-limit/replica-limit-prod admission fail count=2: too many replicas [prod/big]",
+		"replica-limit/replica-limit-prod audit error count=1: 1 existing objects could not be evaluated: no such key: replicas [prod/broken]",
+		"replica-limit/replica-limit-prod audit fail count=1: 1 existing objects fail validation: too many replicas [prod/old]",
+		"team-label/team-label-all admission warn count=3: missing team label [prod/small prod/big]",
+	}
+
+	if len(report.Results) != len(expected) {
+		t.Fatalf("expected %d results, got: %+v", len(expected), report.Results)
+	}
+
+	for i, result := range report.Results {
+		var resources []string
+		for _, resource := range result.Resources {
+			resources = append(resources, resource.Namespace+"/"+resource.Name)
+		}
+
+		summary := fmt.Sprintf("%s/%s %s %s count=%s: %s [%s]", result.Policy, result.Rule, result.Category, result.Result, result.Properties["count"], result.Message, strings.Join(resources, " "))
+		if summary != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], summary)
+		}
+	}
+
+	if report.Summary.Fail != 2 || report.Summary.Warn != 1 || report.Summary.Error != 2 {
+		t.Errorf("unexpected summary: %+v", report.Summary)
+	}
+
+	clusterReport := getReport(t, client, "")
+	if len(clusterReport.Results) != 1 || clusterReport.Results[0].Result != policyreport.PolicyResultWarn {
+		t.Errorf("expected the cluster report to warn of node-labels, got: %+v", clusterReport.Results)
+	}
+
+	// Unchanged reports are not written again
+	client.ClearActions()
+	if err := reporter.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if actions := client.Actions(); len(actions) != 0 {
+		t.Errorf("expected no requests, got: %v", actions)
+	}
+
+	// Reports of namespaces without violations are emptied
+	source.report = &audit.Report{Completed: time.Unix(2000, 0)}
+	if err := reporter.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if clusterReport := getReport(t, client, ""); len(clusterReport.Results) != 0 {
+		t.Errorf("expected the cluster report to be emptied, got: %+v", clusterReport.Results)
+	}
+
+	var updates []string
+	for _, action := range client.Actions() {
+		if update, ok := action.(clienttesting.UpdateAction); ok {
+			updates = append(updates, update.GetResource().Resource+"/"+update.GetNamespace())
+		}
+	}
+
+	if strings.Join(updates, ",") != "clusterpolicyreports/,policyreports/prod" && strings.Join(updates, ",") != "policyreports/prod,clusterpolicyreports/" {
+		t.Errorf("expected both reports to be updated, got: %v", updates)
+	}
+}
+
+func TestReporterExpiresAdmission(t *testing.T) {
+	ctx := context.Background()
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		policyReports:        "PolicyReportList",
+		clusterPolicyReports: "ClusterPolicyReportList",
+	})
+
+	reporter := policyreport.NewReporter(client, nil, time.Minute, 100*time.Millisecond)
+	observed := validator.NewObserved(stubValidator, reporter.RecordAdmission)
+	observed.Validate(warning.WithWarningRecorder(ctx, evaluate.NewWarningCollector(ctx)), request("prod", "big"), nil)
+
+	if err := reporter.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := getReport(t, client, "prod"); len(report.Results) != 2 {
+		t.Fatalf("expected 2 results, got: %+v", report.Results)
+	}
+
+	// Violations which are not seen again are dropped once they expire
+	time.Sleep(200 * time.Millisecond)
+	if err := reporter.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := getReport(t, client, "prod"); len(report.Results) != 0 {
+		t.Errorf("expected the expired results to be dropped, got: %+v", report.Results)
+	}
+}
@@ -0,0 +1,59 @@
+package policyreport
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The subset of wgpolicyk8s.io/v1alpha2 written by the reporter. The CRDs
+// are installed by the policy engines which share them, so their types are
+// mirrored here rather than depended upon.
+var SchemeGroupVersion = schema.GroupVersion{Group: "wgpolicyk8s.io", Version: "v1alpha2"}
+
+var (
+	policyReportsResource        = SchemeGroupVersion.WithResource("policyreports")
+	clusterPolicyReportsResource = SchemeGroupVersion.WithResource("clusterpolicyreports")
+)
+
+type PolicyResult string
+
+const (
+	PolicyResultPass  PolicyResult = "pass"
+	PolicyResultFail  PolicyResult = "fail"
+	PolicyResultWarn  PolicyResult = "warn"
+	PolicyResultError PolicyResult = "error"
+	PolicyResultSkip  PolicyResult = "skip"
+)
+
+// PolicyReport and ClusterPolicyReport share a schema, besides their scope
+type PolicyReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Summary PolicyReportSummary  `json:"summary,omitempty"`
+	Results []PolicyReportResult `json:"results,omitempty"`
+}
+
+// Number of results of each kind
+type PolicyReportSummary struct {
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Warn  int `json:"warn"`
+	Error int `json:"error"`
+	Skip  int `json:"skip"`
+}
+
+type PolicyReportResult struct {
+	Source   string `json:"source,omitempty"`
+	Policy   string `json:"policy"`
+	Rule     string `json:"rule,omitempty"`
+	Category string `json:"category,omitempty"`
+
+	Timestamp metav1.Timestamp         `json:"timestamp,omitempty"`
+	Result    PolicyResult             `json:"result,omitempty"`
+	Resources []corev1.ObjectReference `json:"resources,omitempty"`
+	Message   string                   `json:"message,omitempty"`
+
+	Properties map[string]string `json:"properties,omitempty"`
+}
@@ -0,0 +1,18 @@
+package policytest
+
+import (
+	"context"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Starts an evaluator for the policies, bindings, params, namespaces and CRDs
+// among the fixtures of a suite. It must be closed to stop its informers.
+func newEnvironment(ctx context.Context, objects []*unstructured.Unstructured) (*evaluate.Evaluator, error) {
+	resources, err := evaluate.ResourcesFromObjects(objects)
+	if err != nil {
+		return nil, err
+	}
+	return evaluate.New(ctx, resources)
+}
@@ -0,0 +1,113 @@
+package policytest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writes results in the Test Anything Protocol, numbering cases across all
+// suites
+func WriteTAP(w io.Writer, suites []*SuiteResult) error {
+	total := 0
+	for _, suite := range suites {
+		total += len(suite.Results)
+	}
+
+	if _, err := fmt.Fprintf(w, "TAP version 13\n1..%d\n", total); err != nil {
+		return err
+	}
+
+	n := 0
+	for _, suite := range suites {
+		for _, result := range suite.Results {
+			n++
+
+			status := "ok"
+			if !result.Passed() {
+				status = "not ok"
+			}
+
+			if _, err := fmt.Fprintf(w, "%s %d - %s: %s\n", status, n, suite.Name, result.Case); err != nil {
+				return err
+			}
+
+			if !result.Passed() {
+				// Diagnostics are a YAML block
+				if _, err := fmt.Fprintf(w, "  ---\n  message: %q\n  ...\n", result.Failure); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Writes results as JUnit XML with a testsuite per suite
+func WriteJUnit(w io.Writer, suites []*SuiteResult) error {
+	out := junitTestSuites{}
+	for _, suite := range suites {
+		junitSuite := junitTestSuite{
+			Name:     suite.Name,
+			Tests:    len(suite.Results),
+			Failures: suite.Failures(),
+			Time:     fmt.Sprintf("%.3f", suite.Duration.Seconds()),
+		}
+
+		for _, result := range suite.Results {
+			testCase := junitTestCase{
+				Name:      result.Case,
+				ClassName: suite.Name,
+				Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+			}
+
+			if !result.Passed() {
+				// The message attribute is kept to the first line
+				testCase.Failure = &junitFailure{
+					Message: strings.SplitN(result.Failure, "\n", 2)[0],
+					Text:    result.Failure,
+				}
+			}
+			junitSuite.Cases = append(junitSuite.Cases, testCase)
+		}
+		out.Suites = append(out.Suites, junitSuite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,150 @@
+package policytest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionv1 "k8s.io/api/admission/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// User making requests of cases which do not name one
+const defaultUsername = "test-user"
+
+type Result struct {
+	Case string
+
+	// Reason the case failed. Empty if it passed
+	Failure string
+
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	return len(r.Failure) == 0
+}
+
+type SuiteResult struct {
+	Name     string
+	Results  []Result
+	Duration time.Duration
+}
+
+func (s *SuiteResult) Failures() int {
+	failures := 0
+	for _, result := range s.Results {
+		if !result.Passed() {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Evaluates each case of the suite against the policies of its fixtures.
+// Returns an error if the fixtures of the suite could not be loaded.
+func Run(ctx context.Context, suite *Suite) (*SuiteResult, error) {
+	start := time.Now()
+
+	objects, err := suite.objects()
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := newEnvironment(ctx, objects)
+	if err != nil {
+		return nil, fmt.Errorf("suite %s: %w", suite.Name, err)
+	}
+	defer env.Close()
+
+	result := &SuiteResult{Name: suite.Name}
+	for _, c := range suite.Cases {
+		caseStart := time.Now()
+		result.Results = append(result.Results, Result{
+			Case:     c.Name,
+			Failure:  runCase(ctx, env, c),
+			Duration: time.Since(caseStart),
+		})
+	}
+
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+func runCase(ctx context.Context, env *evaluate.Evaluator, c Case) string {
+	request, err := c.request()
+	if err != nil {
+		return fmt.Sprintf("invalid case: %v", err)
+	}
+
+	decision, err := env.Evaluate(ctx, request)
+	if err != nil {
+		return fmt.Sprintf("invalid case: %v", err)
+	}
+	return c.Expect.check(decision)
+}
+
+// Builds the admission request described by a case
+func (c Case) request() (*admissionv1.AdmissionRequest, error) {
+	if c.Object == nil && c.OldObject == nil {
+		return nil, fmt.Errorf("one of object or oldObject is required")
+	}
+
+	request := &admissionv1.AdmissionRequest{
+		Operation:   admissionv1.Create,
+		SubResource: c.SubResource,
+		UserInfo:    c.UserInfo,
+	}
+	if len(c.Operation) > 0 {
+		request.Operation = admissionv1.Operation(strings.ToUpper(c.Operation))
+	}
+	if c.Object != nil {
+		request.Object = *c.Object
+	}
+	if c.OldObject != nil {
+		request.OldObject = *c.OldObject
+	}
+
+	if len(request.UserInfo.Username) == 0 {
+		request.UserInfo.Username = defaultUsername
+		request.UserInfo.Groups = append(request.UserInfo.Groups, user.AllAuthenticated)
+	}
+	return request, nil
+}
+
+// Returns the reason the outcome of a request does not meet the expectation,
+// or the empty string if it does
+func (e Expectation) check(decision *evaluate.Decision) string {
+	var err error
+	if decision.Status != nil {
+		err = &k8serrors.StatusError{ErrStatus: *decision.Status}
+	}
+
+	warnings := decision.Warnings
+	switch {
+	case err == nil && !e.Allowed:
+		return "expected the request to be denied, but it was allowed"
+	case err != nil && e.Allowed:
+		return fmt.Sprintf("expected the request to be allowed, but it was denied: %v", err)
+	case err != nil && !strings.Contains(err.Error(), e.Message):
+		return fmt.Sprintf("expected the denial to contain %q, got: %v", e.Message, err)
+	}
+
+	for _, expected := range e.Warnings {
+		found := false
+		for _, w := range warnings {
+			if strings.Contains(w, expected) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Sprintf("expected a warning containing %q, got: %q", expected, warnings)
+		}
+	}
+	return ""
+}
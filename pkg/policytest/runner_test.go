@@ -0,0 +1,95 @@
+package policytest_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/policytest"
+)
+
+func TestRun(t *testing.T) {
+	suite, err := policytest.LoadSuite("testdata/widgets/suite.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := policytest.Run(context.Background(), suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Results) != len(suite.Cases) {
+		t.Fatalf("expected %d results, got %d", len(suite.Cases), len(result.Results))
+	}
+
+	for _, r := range result.Results {
+		if !r.Passed() {
+			t.Errorf("%s: %s", r.Case, r.Failure)
+		}
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	suite, err := policytest.LoadSuite("testdata/widgets/suite.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip the expectations of the first two cases
+	suite.Cases = suite.Cases[:2]
+	suite.Cases[0].Expect.Allowed = false
+	suite.Cases[1].Expect.Allowed = true
+
+	result, err := policytest.Run(context.Background(), suite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Failures() != 2 {
+		t.Fatalf("expected 2 failures: %v", result.Results)
+	}
+
+	expected := []string{
+		"expected the request to be denied, but it was allowed",
+		"expected the request to be allowed, but it was denied: widgets.example.com \"large\" is forbidden: ValidatingAdmissionPolicy 'widget-limits' with binding 'widget-limits-prod' denied request: too many replicas",
+	}
+	for i, r := range result.Results {
+		if r.Failure != expected[i] {
+			t.Errorf("%s: expected failure %q, got %q", r.Case, expected[i], r.Failure)
+		}
+	}
+
+	var tap bytes.Buffer
+	if err := policytest.WriteTAP(&tap, []*policytest.SuiteResult{result}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTAP := `TAP version 13
+1..2
+not ok 1 - widgets: within limits
+  ---
+  message: "expected the request to be denied, but it was allowed"
+  ...
+not ok 2 - widgets: too many replicas
+`
+	if !strings.HasPrefix(tap.String(), expectedTAP) {
+		t.Fatalf("unexpected TAP output:\n%s", tap.String())
+	}
+
+	var junit bytes.Buffer
+	if err := policytest.WriteJUnit(&junit, []*policytest.SuiteResult{result}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, substring := range []string{
+		`<testsuite name="widgets" tests="2" failures="2"`,
+		`<testcase name="within limits" classname="widgets"`,
+		`<failure message="expected the request to be denied, but it was allowed">`,
+	} {
+		if !strings.Contains(junit.String(), substring) {
+			t.Fatalf("expected JUnit output to contain %s:\n%s", substring, junit.String())
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package policytest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// A set of admission cases run against the policies, bindings, params, CRDs
+// and namespaces loaded from Resources
+type Suite struct {
+	Name string `json:"name,omitempty"`
+
+	// Files of YAML or JSON documents, relative to the suite file
+	Resources []string `json:"resources"`
+
+	Cases []Case `json:"cases"`
+
+	// Directory the suite was loaded from
+	dir string
+}
+
+type Case struct {
+	Name string `json:"name"`
+
+	// One of CREATE, UPDATE, DELETE or CONNECT. Defaults to CREATE
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// +optional
+	Object *runtime.RawExtension `json:"object,omitempty"`
+
+	// +optional
+	OldObject *runtime.RawExtension `json:"oldObject,omitempty"`
+
+	// +optional
+	SubResource string `json:"subResource,omitempty"`
+
+	// User making the request. Defaults to an authenticated user named
+	// test-user
+	// +optional
+	UserInfo authenticationv1.UserInfo `json:"userInfo,omitempty"`
+
+	Expect Expectation `json:"expect"`
+}
+
+type Expectation struct {
+	Allowed bool `json:"allowed"`
+
+	// Substring of the message the request is denied with
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Substrings of warnings which must each be returned
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func LoadSuite(path string) (*Suite, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	suite := &Suite{}
+	if err := yaml.UnmarshalStrict(file, suite); err != nil {
+		return nil, fmt.Errorf("failed to decode suite %s: %w", path, err)
+	}
+
+	if len(suite.Name) == 0 {
+		suite.Name = filepath.Base(path)
+	}
+	suite.dir = filepath.Dir(path)
+	return suite, nil
+}
+
+// Reads the objects of every resource file of the suite
+func (s *Suite) objects() ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	for _, resource := range s.Resources {
+		path := resource
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.dir, path)
+		}
+
+		decoded, err := decodeFile(path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, decoded...)
+	}
+	return objects, nil
+}
+
+// Reads every object from a file of YAML or JSON documents. Documents are
+// converted to JSON before decoding so integers are not read as floats.
+func decodeFile(path string) ([]*unstructured.Unstructured, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var objects []*unstructured.Unstructured
+	reader := yamlutil.NewYAMLReader(bufio.NewReader(file))
+	for {
+		document, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		js, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+
+		// Empty documents
+		if trimmed := bytes.TrimSpace(js); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+			continue
+		}
+
+		obj, err := decodeObject(js)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		objects = append(objects, obj)
+	}
+}
+
+func decodeObject(js []byte) (*unstructured.Unstructured, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(js); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,76 @@
+package protection
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/webhook"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	admissionregistrationv1listers "k8s.io/client-go/listers/admissionregistration/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// Installs the webhook configuration of the polyfill
+type Installer interface {
+	Install(client kubernetes.Interface) error
+	Installed(config *admissionregistrationv1.ValidatingWebhookConfiguration) bool
+}
+
+// Restores the ValidatingWebhookConfiguration of the polyfill if it is
+// deleted or drifts from the configuration the installer applies
+type Reconciler struct {
+	lister    admissionregistrationv1listers.ValidatingWebhookConfigurationLister
+	hasSynced cache.InformerSynced
+
+	client    kubernetes.Interface
+	installer Installer
+	interval  time.Duration
+}
+
+func NewReconciler(factory informers.SharedInformerFactory, client kubernetes.Interface, installer Installer, interval time.Duration) *Reconciler {
+	configurations := factory.Admissionregistration().V1().ValidatingWebhookConfigurations()
+
+	return &Reconciler{
+		lister:    configurations.Lister(),
+		hasSynced: configurations.Informer().HasSynced,
+		client:    client,
+		installer: installer,
+		interval:  interval,
+	}
+}
+
+func (r *Reconciler) Run(ctx context.Context) error {
+	if !cache.WaitForNamedCacheSync("protection", ctx.Done(), r.hasSynced) {
+		return ctx.Err()
+	}
+
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := r.Reconcile(ctx); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}, r.interval)
+	return nil
+}
+
+// Installs the webhook configuration if it is missing or has drifted
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	config, err := r.lister.Get(webhook.ConfigurationName)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	} else if err == nil && r.installer.Installed(config) {
+		return nil
+	}
+
+	klog.Infof("restoring webhook configuration %s", webhook.ConfigurationName)
+	if err := r.installer.Install(r.client); err != nil {
+		return fmt.Errorf("restoring webhook configuration: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package protection_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/protection"
+	"github.com/alexzielenski/cel_polyfill/pkg/webhook"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Considers configurations installed if they are labeled as such
+type installer struct {
+	installs int
+}
+
+func (i *installer) Install(client kubernetes.Interface) error {
+	i.installs++
+	return nil
+}
+
+func (i *installer) Installed(config *admissionregistrationv1.ValidatingWebhookConfiguration) bool {
+	return config.Labels["installed"] == "true"
+}
+
+func configuration(installed string) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: webhook.ConfigurationName, Labels: map[string]string{"installed": installed}},
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing []*admissionregistrationv1.ValidatingWebhookConfiguration
+		installs int
+	}{
+		{
+			name:     "installed",
+			existing: []*admissionregistrationv1.ValidatingWebhookConfiguration{configuration("true")},
+		},
+		{
+			name:     "deleted",
+			installs: 1,
+		},
+		{
+			name:     "drifted",
+			existing: []*admissionregistrationv1.ValidatingWebhookConfiguration{configuration("false")},
+			installs: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			client := fake.NewSimpleClientset()
+			for _, config := range c.existing {
+				if _, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(ctx, config, metav1.CreateOptions{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			factory := informers.NewSharedInformerFactory(client, 0)
+			installer := &installer{}
+			reconciler := protection.NewReconciler(factory, client, installer, time.Minute)
+			factory.Start(ctx.Done())
+			factory.WaitForCacheSync(ctx.Done())
+
+			if err := reconciler.Reconcile(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if installer.installs != c.installs {
+				t.Errorf("expected %d installs, got %d", c.installs, installer.installs)
+			}
+		})
+	}
+}
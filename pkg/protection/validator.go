@@ -0,0 +1,99 @@
+package protection
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	polyfillv0alpha2 "github.com/alexzielenski/cel_polyfill/pkg/apis/celadmissionpolyfill.k8s.io/v0alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+)
+
+// Controllers which delete the polyfill's resources on behalf of their
+// owners, e.g. when the namespace of the Deployment is deleted
+var controllerUsers = []string{
+	serviceaccount.MakeUsername(metav1.NamespaceSystem, "generic-garbage-collector"),
+	serviceaccount.MakeUsername(metav1.NamespaceSystem, "namespace-controller"),
+}
+
+// Subresources which are written by controllers, not by whoever manages the
+// resource. Scaling the Deployment is left to its admins, as scaling it to
+// zero disables the polyfill as much as deleting it does.
+var controllerSubresources = sets.NewString("status")
+
+// Denies changes to the resources the polyfill itself depends on, unless
+// made by one of its admins, by the polyfill itself or by the controllers
+// which delete them with their owners: the CRDs of the polyfill's groups and
+// the Deployment running the polyfill. Creating them is allowed so that they
+// can be restored.
+//
+// The apiserver never sends requests for ValidatingWebhookConfigurations to
+// webhooks, so when the polyfill installs the configuration of its webhook
+// itself it is restored by the Reconciler instead.
+type Validator struct {
+	// Admins, the polyfill's ServiceAccount and controllerUsers
+	exemptUsers sets.String
+	adminGroups sets.String
+	deployment  types.NamespacedName
+}
+
+// The deployment is not protected if its name is empty. The polyfill's own
+// changes are only allowed if the name of its ServiceAccount is set.
+func NewValidator(adminUsers, adminGroups []string, deployment, serviceAccount types.NamespacedName) *Validator {
+	exemptUsers := sets.NewString(adminUsers...).Insert(controllerUsers...)
+	if len(serviceAccount.Name) > 0 {
+		exemptUsers.Insert(serviceaccount.MakeUsername(serviceAccount.Namespace, serviceAccount.Name))
+	}
+
+	return &Validator{
+		exemptUsers: exemptUsers,
+		adminGroups: sets.NewString(adminGroups...),
+		deployment:  deployment,
+	}
+}
+
+func (v *Validator) Handles(operation admission.Operation) bool {
+	return operation == admission.Update || operation == admission.Delete
+}
+
+func (v *Validator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	if controllerSubresources.Has(a.GetSubresource()) {
+		return nil
+	}
+
+	var protected string
+	resource := a.GetResource()
+	switch {
+	case resource.Group == "apiextensions.k8s.io" && resource.Resource == "customresourcedefinitions":
+		if !isPolyfillCRD(a.GetName()) {
+			return nil
+		}
+		protected = "CustomResourceDefinition " + a.GetName()
+	case resource.Group == "apps" && resource.Resource == "deployments":
+		if len(v.deployment.Name) == 0 || a.GetNamespace() != v.deployment.Namespace || a.GetName() != v.deployment.Name {
+			return nil
+		}
+		protected = "Deployment " + v.deployment.String()
+	default:
+		return nil
+	}
+
+	if userInfo := a.GetUserInfo(); userInfo != nil {
+		if v.exemptUsers.Has(userInfo.GetName()) || v.adminGroups.HasAny(userInfo.GetGroups()...) {
+			return nil
+		}
+	}
+
+	return admission.NewForbidden(a, fmt.Errorf("%s is protected by the polyfill and may only be changed by its admins", protected))
+}
+
+// CRDs are named <plural>.<group>
+func isPolyfillCRD(name string) bool {
+	return strings.HasSuffix(name, "."+polyfillv1alpha1.GroupName) ||
+		strings.HasSuffix(name, "."+polyfillv0alpha2.GroupName)
+}
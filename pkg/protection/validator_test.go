@@ -0,0 +1,126 @@
+package protection_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/protection"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func attributes(resource schema.GroupVersionResource, namespace, name, subresource string, operation admission.Operation, userInfo user.Info) admission.Attributes {
+	return admission.NewAttributesRecord(
+		nil,
+		nil,
+		schema.GroupVersionKind{},
+		namespace,
+		name,
+		resource,
+		subresource,
+		operation,
+		nil,
+		false,
+		userInfo,
+	)
+}
+
+func TestValidator(t *testing.T) {
+	crds := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+
+	alice := &user.DefaultInfo{Name: "alice"}
+	admin := &user.DefaultInfo{Name: "admin"}
+	master := &user.DefaultInfo{Name: "bob", Groups: []string{user.SystemPrivilegedGroup}}
+
+	polyfill := &user.DefaultInfo{Name: "system:serviceaccount:polyfill:cel-admission-polyfill"}
+	namespaceController := &user.DefaultInfo{Name: "system:serviceaccount:kube-system:namespace-controller"}
+	garbageCollector := &user.DefaultInfo{Name: "system:serviceaccount:kube-system:generic-garbage-collector"}
+
+	validator := protection.NewValidator(
+		[]string{"admin"},
+		[]string{user.SystemPrivilegedGroup},
+		types.NamespacedName{Namespace: "polyfill", Name: "cel-admission-polyfill"},
+		types.NamespacedName{Namespace: "polyfill", Name: "cel-admission-polyfill"},
+	)
+
+	cases := []struct {
+		name     string
+		attrs    admission.Attributes
+		expected string
+	}{
+		{
+			name:     "delete policy crd",
+			attrs:    attributes(crds, "", "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io", "", admission.Delete, alice),
+			expected: `customresourcedefinitions.apiextensions.k8s.io "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io" is forbidden: CustomResourceDefinition validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io is protected by the polyfill and may only be changed by its admins`,
+		},
+		{
+			name:     "update template crd",
+			attrs:    attributes(crds, "", "policytemplates.celadmissionpolyfill.k8s.io", "", admission.Update, alice),
+			expected: `customresourcedefinitions.apiextensions.k8s.io "policytemplates.celadmissionpolyfill.k8s.io" is forbidden: CustomResourceDefinition policytemplates.celadmissionpolyfill.k8s.io is protected by the polyfill and may only be changed by its admins`,
+		},
+		{
+			name:  "delete other crd",
+			attrs: attributes(crds, "", "widgets.example.com", "", admission.Delete, alice),
+		},
+		{
+			name:  "update crd status",
+			attrs: attributes(crds, "", "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io", "status", admission.Update, alice),
+		},
+		{
+			name:  "admin user",
+			attrs: attributes(crds, "", "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io", "", admission.Delete, admin),
+		},
+		{
+			name:  "admin group",
+			attrs: attributes(crds, "", "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io", "", admission.Delete, master),
+		},
+		{
+			name:  "polyfill service account",
+			attrs: attributes(crds, "", "validatingadmissionpolicies.admissionregistration.polyfill.sigs.k8s.io", "", admission.Update, polyfill),
+		},
+		{
+			name:     "update deployment",
+			attrs:    attributes(deployments, "polyfill", "cel-admission-polyfill", "", admission.Update, alice),
+			expected: `deployments.apps "cel-admission-polyfill" is forbidden: Deployment polyfill/cel-admission-polyfill is protected by the polyfill and may only be changed by its admins`,
+		},
+		{
+			name:     "scale deployment",
+			attrs:    attributes(deployments, "polyfill", "cel-admission-polyfill", "scale", admission.Update, alice),
+			expected: `deployments.apps "cel-admission-polyfill" is forbidden: Deployment polyfill/cel-admission-polyfill is protected by the polyfill and may only be changed by its admins`,
+		},
+		{
+			name:  "admin scales deployment",
+			attrs: attributes(deployments, "polyfill", "cel-admission-polyfill", "scale", admission.Update, admin),
+		},
+		{
+			name:  "namespace deletion",
+			attrs: attributes(deployments, "polyfill", "cel-admission-polyfill", "", admission.Delete, namespaceController),
+		},
+		{
+			name:  "garbage collection",
+			attrs: attributes(deployments, "polyfill", "cel-admission-polyfill", "", admission.Delete, garbageCollector),
+		},
+		{
+			name:  "other deployment",
+			attrs: attributes(deployments, "default", "cel-admission-polyfill", "", admission.Delete, alice),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validator.Validate(context.Background(), c.attrs, nil)
+
+			message := ""
+			if err != nil {
+				message = err.Error()
+			}
+
+			if message != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/logfile"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// Value the data of captured Secrets is replaced with
+const redacted = "REDACTED"
+
+// Annotation kubectl apply copies the whole object into, including the data
+// of Secrets
+const lastAppliedAnnotation = corev1.LastAppliedConfigAnnotation
+
+// A captured AdmissionReview request and the response the webhook returned
+// for it. Written as a line of JSON
+type Entry struct {
+	Timestamp time.Time                      `json:"timestamp"`
+	Request   *admissionv1.AdmissionRequest  `json:"request"`
+	Response  *admissionv1.AdmissionResponse `json:"response"`
+
+	// Bindings evaluated for the request, including those of
+	// NamespacedValidatingAdmissionPolicies. The response is also decided by
+	// the validators of the polyfill itself, which replay does not evaluate
+	Bindings []evaluate.BindingResult `json:"bindings,omitempty"`
+}
+
+// Appends the reviews received by the webhook to a file, which is rotated
+// like the decision log. Passed to webhook.New
+type Recorder struct {
+	lock sync.Mutex
+	file *logfile.File
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := logfile.Open(path, 0600, logfile.DefaultMaxSize, logfile.DefaultMaxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("opening capture file: %w", err)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Closes the file once ctx is cancelled
+func (r *Recorder) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
+
+// Captures a review, redacting the data of Secrets
+func (r *Recorder) Record(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse, results []evaluate.BindingResult) {
+	redactedRequest, err := Redact(request)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("not capturing request %s: %w", request.UID, err))
+		return
+	}
+
+	line, err := json.Marshal(&Entry{Timestamp: time.Now().UTC(), Request: redactedRequest, Response: response, Bindings: results})
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("capturing request %s: %w", request.UID, err))
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		utilruntime.HandleError(fmt.Errorf("capturing request %s: %w", request.UID, err))
+	}
+}
+
+// Returns a copy of request with the values of Secret data replaced. Requests
+// of other kinds are returned as they are
+func Redact(request *admissionv1.AdmissionRequest) (*admissionv1.AdmissionRequest, error) {
+	if request.Kind.Group != "" || request.Kind.Kind != "Secret" {
+		return request, nil
+	}
+
+	result := request.DeepCopy()
+	for _, raw := range []*[]byte{&result.Object.Raw, &result.OldObject.Raw} {
+		if len(*raw) == 0 {
+			continue
+		}
+
+		var secret map[string]interface{}
+		if err := json.Unmarshal(*raw, &secret); err != nil {
+			return nil, err
+		}
+
+		for _, field := range []string{"data", "stringData"} {
+			if data, ok := secret[field].(map[string]interface{}); ok {
+				for key := range data {
+					data[key] = redacted
+				}
+			}
+		}
+
+		if metadata, ok := secret["metadata"].(map[string]interface{}); ok {
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+				if _, found := annotations[lastAppliedAnnotation]; found {
+					annotations[lastAppliedAnnotation] = redacted
+				}
+			}
+		}
+
+		encoded, err := json.Marshal(secret)
+		if err != nil {
+			return nil, err
+		}
+		*raw = encoded
+	}
+	return result, nil
+}
+
+// Reads the entries of a capture file one at a time
+type Reader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{reader: bufio.NewReader(r)}
+}
+
+// Returns the next entry, or io.EOF after the last
+func (r *Reader) Next() (*Entry, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		r.line++
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(line, entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+
+		if entry.Request == nil || entry.Response == nil {
+			return nil, fmt.Errorf("line %d: request and response are required", r.line)
+		}
+		return entry, nil
+	}
+}
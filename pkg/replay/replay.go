@@ -0,0 +1,198 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	admissionv1 "k8s.io/api/admission/v1"
+)
+
+// The parts of a decision compared between recording and replay
+type Outcome struct {
+	Allowed bool
+
+	// Message the request was denied with
+	Message  string
+	Warnings []string
+}
+
+func (o Outcome) equal(other Outcome) bool {
+	if o.Allowed != other.Allowed || len(o.Warnings) != len(other.Warnings) {
+		return false
+	}
+
+	// Messages of allowed requests are not meaningful
+	if !o.Allowed && o.Message != other.Message {
+		return false
+	}
+
+	for i := range o.Warnings {
+		if o.Warnings[i] != other.Warnings[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// A captured request whose decision differs when replayed
+type Change struct {
+	Entry    *Entry
+	Recorded Outcome
+	Replayed Outcome
+
+	// Set if the request could not be replayed
+	Error string
+}
+
+type Result struct {
+	Replayed int
+	Changes  []Change
+}
+
+// Number of replayed requests which were recorded as allowed but are now
+// denied
+func (r *Result) NewlyDenied() int {
+	count := 0
+	for _, change := range r.Changes {
+		if len(change.Error) == 0 && change.Recorded.Allowed && !change.Replayed.Allowed {
+			count++
+		}
+	}
+	return count
+}
+
+// Evaluates each captured request read from reader against the policies of
+// evaluator, collecting those whose decisions changed
+func Replay(ctx context.Context, evaluator *evaluate.Evaluator, reader *Reader) (*Result, error) {
+	result := &Result{}
+	if err := result.Replay(ctx, evaluator, reader); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Replays the captured requests read from reader like Replay, adding them to
+// the result. Only the decisions of ValidatingAdmissionPolicies are compared:
+// the recorded decision is rebuilt from the captured results of their
+// bindings, leaving out the validators of the polyfill itself and
+// NamespacedValidatingAdmissionPolicies, which are not replayed
+func (r *Result) Replay(ctx context.Context, evaluator *evaluate.Evaluator, reader *Reader) error {
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		r.Replayed++
+
+		var results []evaluate.BindingResult
+		for _, result := range entry.Bindings {
+			if len(result.Namespace) == 0 {
+				results = append(results, result)
+			}
+		}
+
+		recorded, err := evaluator.Decide(entry.Request, results)
+		if err != nil {
+			r.Changes = append(r.Changes, Change{Entry: entry, Recorded: outcome(entry.Response), Error: err.Error()})
+			continue
+		}
+
+		replayed, err := evaluator.Evaluate(ctx, entry.Request)
+		if err != nil {
+			r.Changes = append(r.Changes, Change{Entry: entry, Recorded: decisionOutcome(recorded), Error: err.Error()})
+			continue
+		}
+
+		change := Change{Entry: entry, Recorded: decisionOutcome(recorded), Replayed: decisionOutcome(replayed)}
+		if !change.Recorded.equal(change.Replayed) {
+			r.Changes = append(r.Changes, change)
+		}
+	}
+}
+
+func decisionOutcome(decision *evaluate.Decision) Outcome {
+	result := Outcome{Allowed: decision.Allowed, Warnings: sortedCopy(decision.Warnings)}
+	if decision.Status != nil {
+		result.Message = decision.Status.Message
+	}
+	return result
+}
+
+// Returns the outcome of a response, for requests which could not be decided
+func outcome(response *admissionv1.AdmissionResponse) Outcome {
+	result := Outcome{Allowed: response.Allowed, Warnings: sortedCopy(response.Warnings)}
+	if response.Result != nil {
+		result.Message = response.Result.Message
+	}
+	return result
+}
+
+func sortedCopy(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := append([]string{}, values...)
+	sort.Strings(result)
+	return result
+}
+
+// Writes the changes of result in the style of a unified diff, followed by a
+// summary
+func WriteDiff(w io.Writer, result *Result) error {
+	var b strings.Builder
+	if len(result.Changes) > 0 {
+		fmt.Fprintln(&b, "--- recorded")
+		fmt.Fprintln(&b, "+++ replayed")
+	}
+
+	errs := 0
+	for _, change := range result.Changes {
+		request := change.Entry.Request
+		name := request.Name
+		if len(request.Namespace) > 0 {
+			name = request.Namespace + "/" + name
+		}
+
+		resource := request.Resource.Resource
+		if len(request.Resource.Group) > 0 {
+			resource += "." + request.Resource.Group
+		}
+		if len(request.SubResource) > 0 {
+			resource += "/" + request.SubResource
+		}
+
+		fmt.Fprintf(&b, "@@ %s %s %s %s by %s @@\n", change.Entry.Timestamp.Format(time.RFC3339), request.Operation, resource, name, request.UserInfo.Username)
+		writeOutcome(&b, "-", change.Recorded)
+		if len(change.Error) > 0 {
+			errs++
+			fmt.Fprintf(&b, "+error: %s\n", change.Error)
+		} else {
+			writeOutcome(&b, "+", change.Replayed)
+		}
+	}
+
+	fmt.Fprintf(&b, "replayed %d requests: %d changed, %d newly denied, %d errors\n", result.Replayed, len(result.Changes)-errs, result.NewlyDenied(), errs)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeOutcome(b *strings.Builder, prefix string, outcome Outcome) {
+	if outcome.Allowed {
+		fmt.Fprintf(b, "%sallowed\n", prefix)
+	} else {
+		fmt.Fprintf(b, "%sdenied: %s\n", prefix, outcome.Message)
+	}
+
+	for _, warning := range outcome.Warnings {
+		fmt.Fprintf(b, "%swarning: %s\n", prefix, warning)
+	}
+}
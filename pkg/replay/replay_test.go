@@ -0,0 +1,166 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/replay"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	recorder, err := replay.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"data\":{\"password\":\"aHVudGVyMg==\"}}"}},"data":{"password":"aHVudGVyMg=="},"stringData":{"user":"admin"}}`)
+	request := &admissionv1.AdmissionRequest{
+		UID:       "uid-secret",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Secret"},
+		Resource:  metav1.GroupVersionResource{Version: "v1", Resource: "secrets"},
+		Operation: admissionv1.Update,
+		Object:    runtime.RawExtension{Raw: secret},
+		OldObject: runtime.RawExtension{Raw: secret},
+	}
+	recorder.Record(request, &admissionv1.AdmissionResponse{UID: "uid-secret", Allowed: true}, nil)
+
+	configMap := &admissionv1.AdmissionRequest{
+		UID:       "uid-configmap",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","data":{"password":"hunter2"}}`)},
+	}
+	recorder.Record(configMap, &admissionv1.AdmissionResponse{UID: "uid-configmap", Allowed: true}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := recorder.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The captured request is redacted, but not the one passed in
+	if !bytes.Equal(request.Object.Raw, secret) {
+		t.Error("expected the request to be left unchanged")
+	}
+
+	captured, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(captured), "aHVudGVyMg==") || strings.Contains(string(captured), "admin") {
+		t.Errorf("expected the secret to be redacted, got: %s", captured)
+	}
+
+	reader := replay.NewReader(bytes.NewReader(captured))
+	var entries []*replay.Entry
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 || entries[0].Request.UID != "uid-secret" || entries[1].Request.UID != "uid-configmap" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	for _, raw := range [][]byte{entries[0].Request.Object.Raw, entries[0].Request.OldObject.Raw} {
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(raw); err != nil {
+			t.Fatal(err)
+		}
+
+		password, _, _ := unstructured.NestedString(obj.Object, "data", "password")
+		user, _, _ := unstructured.NestedString(obj.Object, "stringData", "user")
+		if password != "REDACTED" || user != "REDACTED" {
+			t.Errorf("expected the keys of the secret to be kept, got: %v", obj.Object)
+		}
+	}
+
+	if !strings.Contains(string(entries[1].Request.Object.Raw), "hunter2") {
+		t.Errorf("expected only secrets to be redacted, got: %s", entries[1].Request.Object.Raw)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	file, err := os.Open("testdata/policy.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var objects []*unstructured.Unstructured
+	decoder := yamlutil.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		objects = append(objects, obj)
+	}
+
+	resources, err := evaluate.ResourcesFromObjects(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	evaluator, err := evaluate.New(ctx, resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer evaluator.Close()
+
+	capture, err := os.Open("testdata/capture.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	result, err := replay.Replay(ctx, evaluator, replay.NewReader(capture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// big was allowed before the policy and huge was already denied by it.
+	// small was denied by a NamespacedValidatingAdmissionPolicy, which is not
+	// replayed
+	if result.Replayed != 3 || len(result.Changes) != 1 || result.NewlyDenied() != 1 {
+		t.Fatalf("expected big to be newly denied, got: %+v", result)
+	}
+
+	var out strings.Builder
+	if err := replay.WriteDiff(&out, result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `--- recorded
++++ replayed
+@@ 2023-03-01T10:01:00Z CREATE deployments.apps prod/big by alice @@
+-allowed
++denied: deployments.apps "big" is forbidden: ValidatingAdmissionPolicy 'replica-limit' with binding 'replica-limit' denied request: replicas must be at most 3
+replayed 3 requests: 1 changed, 1 newly denied, 0 errors
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
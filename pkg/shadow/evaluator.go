@@ -0,0 +1,313 @@
+package shadow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	polyfilllisters "github.com/alexzielenski/cel_polyfill/pkg/generated/listers/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy"
+	"k8s.io/apiserver/pkg/admission/plugin/validatingadmissionpolicy/matching"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	admissionregistrationv1alpha1listers "k8s.io/client-go/listers/admissionregistration/v1alpha1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// Number of requests waiting to be evaluated. Requests are dropped while the
+// queue is full rather than slowing admission down
+const queueSize = 1000
+
+// Number of violating requests kept in the status of each binding
+const recentViolations = 10
+
+// A request waiting to be evaluated by shadow bindings
+type request struct {
+	attrs admission.Attributes
+	time  time.Time
+}
+
+// Wraps a validator to evaluate shadow bindings against the requests it
+// validates, without affecting the responses to them. Enforcement never sees
+// shadow bindings, so they are evaluated again off the admission path. The
+// results are counted in memory and periodically added to the status of each
+// binding.
+type Evaluator struct {
+	admission.ValidationInterface
+
+	policyLister  admissionregistrationv1alpha1listers.ValidatingAdmissionPolicyLister
+	bindingLister polyfilllisters.ValidatingAdmissionPolicyBindingLister
+	hasSynced     []cache.InformerSynced
+
+	client   versioned.Interface
+	matcher  validatingadmissionpolicy.Matcher
+	params   *evaluate.ParamCache
+	interval time.Duration
+
+	queue chan request
+
+	// Counts of each binding not yet written to its status
+	lock    sync.Mutex
+	pending map[string]*polyfillv1alpha1.ShadowStatus
+}
+
+func NewEvaluator(
+	validator admission.ValidationInterface,
+	factory informers.SharedInformerFactory,
+	customFactory externalversions.SharedInformerFactory,
+	client kubernetes.Interface,
+	customClient versioned.Interface,
+	restMapper meta.RESTMapper,
+	dynamicClient dynamic.Interface,
+	interval time.Duration,
+) *Evaluator {
+	policies := factory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicies()
+	bindings := customFactory.Admissionregistration().V1alpha1().ValidatingAdmissionPolicyBindings()
+	namespaces := factory.Core().V1().Namespaces()
+
+	return &Evaluator{
+		ValidationInterface: validator,
+		policyLister:        policies.Lister(),
+		bindingLister:       bindings.Lister(),
+		hasSynced: []cache.InformerSynced{
+			policies.Informer().HasSynced,
+			bindings.Informer().HasSynced,
+			namespaces.Informer().HasSynced,
+		},
+		client:   customClient,
+		matcher:  validatingadmissionpolicy.NewMatcher(matching.NewMatcher(namespaces.Lister(), client)),
+		params:   evaluate.NewDefaultParamCache(restMapper, dynamicClient),
+		interval: interval,
+		queue:    make(chan request, queueSize),
+		pending:  map[string]*polyfillv1alpha1.ShadowStatus{},
+	}
+}
+
+func (e *Evaluator) Validate(ctx context.Context, a admission.Attributes, o admission.ObjectInterfaces) error {
+	err := e.ValidationInterface.Validate(ctx, a, o)
+
+	select {
+	case e.queue <- request{attrs: a, time: time.Now()}:
+	default:
+		utilruntime.HandleError(fmt.Errorf("shadow queue is full, dropping %s of %s", a.GetOperation(), a.GetName()))
+	}
+	return err
+}
+
+// Evaluates queued requests until ctx is cancelled, writing their counts to
+// the bindings every interval
+func (e *Evaluator) Run(ctx context.Context) error {
+	if !cache.WaitForNamedCacheSync("shadow", ctx.Done(), e.hasSynced...) {
+		return ctx.Err()
+	}
+
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := e.Flush(ctx); err != nil {
+			utilruntime.HandleError(err)
+		}
+	}, e.interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case r := <-e.queue:
+			e.evaluate(ctx, r)
+		}
+	}
+}
+
+func (e *Evaluator) evaluate(ctx context.Context, r request) {
+	crdBindings, err := e.bindingLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("listing shadow bindings: %w", err))
+		return
+	}
+
+	var bindings []*admissionregistrationv1alpha1.ValidatingAdmissionPolicyBinding
+	for _, crdBinding := range crdBindings {
+		if controllerv1alpha1.IsEnforced(crdBinding) {
+			continue
+		}
+
+		binding, err := controllerv1alpha1.CRDToNativePolicyBinding(crdBinding)
+		if err != nil {
+			utilruntime.HandleError(fmt.Errorf("converting shadow binding %s: %w", crdBinding.Name, err))
+			continue
+		}
+		bindings = append(bindings, binding)
+	}
+
+	if len(bindings) == 0 {
+		return
+	}
+
+	policies, err := e.policyLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("listing policies for shadow bindings: %w", err))
+		return
+	}
+
+	results := evaluate.NewExplainer(e.matcher, e.params.Resolver(ctx)).Explain(ctx, r.attrs, policies, bindings)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, result := range results {
+		// Errors matching the policy itself are not of any one binding
+		if len(result.Binding) == 0 {
+			continue
+		}
+
+		status, ok := e.pending[result.Binding]
+		if !ok {
+			status = &polyfillv1alpha1.ShadowStatus{}
+			e.pending[result.Binding] = status
+		}
+		record(status, r, result)
+	}
+}
+
+// Counts the result of a binding for a request
+func record(status *polyfillv1alpha1.ShadowStatus, r request, result evaluate.BindingResult) {
+	status.Matched++
+
+	message := result.Error
+	for _, validation := range result.Validations {
+		if validation.Outcome == evaluate.ValidationError && len(message) == 0 {
+			message = validation.Error
+		}
+	}
+
+	if len(message) > 0 {
+		status.Errors++
+		return
+	}
+
+	for _, validation := range result.Validations {
+		if validation.Outcome == evaluate.ValidationDeny {
+			message = validation.Message
+			break
+		}
+	}
+
+	if len(message) == 0 {
+		return
+	}
+
+	for _, action := range result.Actions {
+		switch action {
+		case admissionregistrationv1alpha1.Deny:
+			status.WouldDeny++
+		case admissionregistrationv1alpha1.Warn:
+			status.WouldWarn++
+		}
+	}
+
+	now := metav1.NewTime(r.time)
+	if status.FirstSeen == nil {
+		status.FirstSeen = &now
+	}
+	status.LastSeen = &now
+
+	a := r.attrs
+	status.RecentViolations = appendViolations(status.RecentViolations, polyfillv1alpha1.ShadowViolation{
+		Time:      now,
+		Operation: polyfillv1alpha1.OperationType(a.GetOperation()),
+		Resource:  a.GetResource().GroupResource().String(),
+		Namespace: a.GetNamespace(),
+		Name:      a.GetName(),
+		User:      a.GetUserInfo().GetName(),
+		Message:   message,
+	})
+}
+
+// Appends violations, keeping only the most recent
+func appendViolations(violations []polyfillv1alpha1.ShadowViolation, added ...polyfillv1alpha1.ShadowViolation) []polyfillv1alpha1.ShadowViolation {
+	violations = append(violations, added...)
+	if len(violations) > recentViolations {
+		violations = append([]polyfillv1alpha1.ShadowViolation{}, violations[len(violations)-recentViolations:]...)
+	}
+	return violations
+}
+
+// Adds the counts of requests evaluated since the last flush to the status
+// of each binding. Counts which could not be written are kept for the next
+// flush.
+func (e *Evaluator) Flush(ctx context.Context) error {
+	e.lock.Lock()
+	pending := e.pending
+	e.pending = map[string]*polyfillv1alpha1.ShadowStatus{}
+	e.lock.Unlock()
+
+	var errs []error
+	for name, delta := range pending {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			bindings := e.client.AdmissionregistrationV1alpha1().ValidatingAdmissionPolicyBindings()
+			binding, err := bindings.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			binding = binding.DeepCopy()
+			binding.Status.Shadow = merge(binding.Status.Shadow, delta)
+			_, err = bindings.UpdateStatus(ctx, binding, metav1.UpdateOptions{})
+			return err
+		})
+
+		if k8serrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("updating shadow status of binding %s: %w", name, err))
+
+			e.lock.Lock()
+			if status, ok := e.pending[name]; ok {
+				e.pending[name] = merge(delta, status)
+			} else {
+				e.pending[name] = delta
+			}
+			e.lock.Unlock()
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Returns the counts of status followed by those of delta
+func merge(status, delta *polyfillv1alpha1.ShadowStatus) *polyfillv1alpha1.ShadowStatus {
+	if status == nil {
+		return delta.DeepCopy()
+	}
+
+	merged := status.DeepCopy()
+	merged.Matched += delta.Matched
+	merged.WouldDeny += delta.WouldDeny
+	merged.WouldWarn += delta.WouldWarn
+	merged.Errors += delta.Errors
+
+	if merged.FirstSeen == nil {
+		merged.FirstSeen = delta.FirstSeen.DeepCopy()
+	}
+	if delta.LastSeen != nil {
+		merged.LastSeen = delta.LastSeen.DeepCopy()
+	}
+
+	merged.RecentViolations = appendViolations(merged.RecentViolations, delta.DeepCopy().RecentViolations...)
+	return merged
+}
@@ -0,0 +1,164 @@
+package shadow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	polyfillv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/apis/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	controllerv1alpha1 "github.com/alexzielenski/cel_polyfill/pkg/controller/admissionregistration.polyfill.sigs.k8s.io/v1alpha1"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate/evaluatetest"
+	polyfillfake "github.com/alexzielenski/cel_polyfill/pkg/generated/clientset/versioned/fake"
+	"github.com/alexzielenski/cel_polyfill/pkg/generated/informers/externalversions"
+	"github.com/alexzielenski/cel_polyfill/pkg/shadow"
+	admissionregistrationv1alpha1 "k8s.io/api/admissionregistration/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/authentication/user"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Denies every request, as if by an enforced binding
+var denyingValidator = evaluatetest.Validator(func(a admission.Attributes) []evaluate.BindingResult {
+	return []evaluate.BindingResult{{
+		Policy:      "replica-limit",
+		Binding:     "replica-limit-enforced",
+		Actions:     []admissionregistrationv1alpha1.ValidationAction{admissionregistrationv1alpha1.Deny},
+		Validations: []evaluate.ValidationResult{{Outcome: evaluate.ValidationDeny, Message: "denied"}},
+	}}
+})
+
+func shadowBinding(name string, action polyfillv1alpha1.ValidationAction) *polyfillv1alpha1.ValidatingAdmissionPolicyBinding {
+	binding := evaluatetest.Binding(name, "replica-limit", action)
+	binding.Spec.Shadow = true
+	return binding
+}
+
+func request(name string, replicas int64) admission.Attributes {
+	return evaluatetest.DeploymentAttributes(evaluatetest.Deployment("prod", name, replicas, nil), &user.DefaultInfo{Name: "alice"})
+}
+
+func TestEvaluator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	customClient := polyfillfake.NewSimpleClientset(
+		evaluatetest.Policy("replica-limit", "object.spec.replicas <= 3", "too many replicas"),
+		evaluatetest.Binding("replica-limit-enforced", "replica-limit"),
+		shadowBinding("replica-limit-shadow", polyfillv1alpha1.Deny),
+		shadowBinding("replica-limit-shadow-warn", polyfillv1alpha1.Warn),
+	)
+	client := controllerv1alpha1.NewWrappedClient(fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod"}}), customClient)
+
+	// Shadow bindings are hidden from enforcement
+	nativeBindings, err := client.AdmissionregistrationV1alpha1().ValidatingAdmissionPolicyBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nativeBindings.Items) != 1 || nativeBindings.Items[0].Name != "replica-limit-enforced" {
+		t.Errorf("expected only the enforced binding to be listed, got: %+v", nativeBindings.Items)
+	}
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	customFactory := externalversions.NewSharedInformerFactory(customClient, 0)
+	evaluator := shadow.NewEvaluator(
+		denyingValidator,
+		factory,
+		customFactory,
+		client,
+		customClient,
+		meta.NewDefaultRESTMapper(nil),
+		dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		time.Hour,
+	)
+	factory.Start(ctx.Done())
+	customFactory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	customFactory.WaitForCacheSync(ctx.Done())
+	go evaluator.Run(ctx)
+
+	for _, name := range []string{"small", "big", "bigger"} {
+		attrs := request(name, map[string]int64{"small": 2, "big": 5, "bigger": 10}[name])
+
+		// The response of the wrapped validator is returned unchanged
+		if err := evaluator.Validate(ctx, attrs, admission.NewObjectInterfacesFromScheme(runtime.NewScheme())); err == nil {
+			t.Errorf("expected %s to be denied by the wrapped validator", name)
+		}
+	}
+
+	statusOf := func(name string) *polyfillv1alpha1.ShadowStatus {
+		b, err := customClient.AdmissionregistrationV1alpha1().ValidatingAdmissionPolicyBindings().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.Status.Shadow
+	}
+
+	// Requests are evaluated in the background, so flush until all of them
+	// have been counted
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		if err := evaluator.Flush(ctx); err != nil {
+			return false, err
+		}
+		status := statusOf("replica-limit-shadow-warn")
+		return status != nil && status.Matched == 3 && statusOf("replica-limit-shadow").Matched == 3, nil
+	}); err != nil {
+		t.Fatalf("expected 3 requests to be counted, got: %+v", statusOf("replica-limit-shadow"))
+	}
+
+	denied := statusOf("replica-limit-shadow")
+	if denied.WouldDeny != 2 || denied.WouldWarn != 0 || denied.Errors != 0 {
+		t.Errorf("unexpected counts: %+v", denied)
+	}
+
+	if denied.FirstSeen == nil || denied.LastSeen == nil || denied.LastSeen.Before(denied.FirstSeen) {
+		t.Errorf("unexpected first and last seen: %v, %v", denied.FirstSeen, denied.LastSeen)
+	}
+
+	expected := []string{"big", "bigger"}
+	if len(denied.RecentViolations) != len(expected) {
+		t.Fatalf("expected %d violations, got: %+v", len(expected), denied.RecentViolations)
+	}
+
+	for i, violation := range denied.RecentViolations {
+		if violation.Name != expected[i] || violation.Namespace != "prod" || violation.User != "alice" ||
+			violation.Operation != "CREATE" || violation.Resource != "deployments.apps" || violation.Message != "too many replicas" {
+			t.Errorf("unexpected violation %d: %+v", i, violation)
+		}
+	}
+
+	if warned := statusOf("replica-limit-shadow-warn"); warned.WouldWarn != 2 || warned.WouldDeny != 0 {
+		t.Errorf("unexpected counts: %+v", warned)
+	}
+
+	if status := statusOf("replica-limit-enforced"); status != nil {
+		t.Errorf("expected the enforced binding to have no shadow status, got: %+v", status)
+	}
+
+	// Later counts are added to those already written
+	for i := 0; i < 12; i++ {
+		evaluator.Validate(ctx, request("huge", 20), admission.NewObjectInterfacesFromScheme(runtime.NewScheme()))
+	}
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		if err := evaluator.Flush(ctx); err != nil {
+			return false, err
+		}
+		return statusOf("replica-limit-shadow").Matched == 15, nil
+	}); err != nil {
+		t.Fatalf("expected 15 requests to be counted, got: %+v", statusOf("replica-limit-shadow"))
+	}
+
+	denied = statusOf("replica-limit-shadow")
+	if denied.WouldDeny != 14 || len(denied.RecentViolations) != 10 || denied.RecentViolations[0].Name != "huge" {
+		t.Errorf("expected only the 10 most recent violations to be kept, got: %+v", denied)
+	}
+}
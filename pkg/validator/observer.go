@@ -0,0 +1,45 @@
+package validator
+
+import (
+	"context"
+
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"k8s.io/apiserver/pkg/admission"
+	"k8s.io/apiserver/pkg/warning"
+)
+
+// Called with the results of the bindings evaluated for each request which
+// matched any
+type Observer func(attrs admission.Attributes, results []evaluate.BindingResult)
+
+// Wraps a validator to pass the results of the bindings it evaluates to
+// observers
+func NewObserved(validator admission.ValidationInterface, observers ...Observer) admission.ValidationInterface {
+	return observed{ValidationInterface: validator, observers: observers}
+}
+
+type observed struct {
+	admission.ValidationInterface
+	observers []Observer
+}
+
+func (o observed) Validate(ctx context.Context, a admission.Attributes, oi admission.ObjectInterfaces) error {
+	results, _, err := ValidateWithResults(ctx, o.ValidationInterface, a, oi)
+
+	if len(results) > 0 {
+		for _, observer := range o.observers {
+			observer(a, results)
+		}
+	}
+	return err
+}
+
+// Validates a request, returning the results of the bindings validator
+// evaluated and the warnings it added, in addition to passing both on to the
+// recorders of ctx
+func ValidateWithResults(ctx context.Context, validator admission.ValidationInterface, a admission.Attributes, o admission.ObjectInterfaces) ([]evaluate.BindingResult, []string, error) {
+	results := evaluate.NewResultCollector(ctx)
+	warnings := evaluate.NewWarningCollector(ctx)
+	err := validator.Validate(warning.WithWarningRecorder(evaluate.WithResultRecorder(ctx, results), warnings), a, o)
+	return results.Results(), warnings.Warnings(), err
+}
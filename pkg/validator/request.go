@@ -0,0 +1,21 @@
+package validator
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type requestUIDKey struct{}
+
+// Returns a context carrying the UID of the AdmissionReview being validated.
+// admission.Attributes has no UID of its own
+func WithRequestUID(ctx context.Context, uid types.UID) context.Context {
+	return context.WithValue(ctx, requestUIDKey{}, uid)
+}
+
+// Returns the UID of the AdmissionReview being validated, if ctx has one
+func RequestUID(ctx context.Context) types.UID {
+	uid, _ := ctx.Value(requestUIDKey{}).(types.UID)
+	return uid
+}
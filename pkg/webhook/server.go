@@ -14,9 +14,12 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/alexzielenski/cel_polyfill/pkg/evaluate"
+	"github.com/alexzielenski/cel_polyfill/pkg/validator"
 	admissionv1 "k8s.io/api/admission/v1"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -64,9 +67,16 @@ func NewCertInfoFromFiles(certFile, keyFile, rootFile string) (CertInfo, error)
 	}, nil
 }
 
+// Name of the ValidatingWebhookConfiguration and of its webhook
+const ConfigurationName = "cel-admission-polyfill.k8s.io"
+
 type Interface interface {
 	Install(client kubernetes.Interface) error
 
+	// Returns whether config is the configuration Install would apply for
+	// the running server
+	Installed(config *admissionregistrationv1.ValidatingWebhookConfiguration) bool
+
 	// Runs the webhook server until the passed context is cancelled, or it
 	// experiences an internal error.
 	//
@@ -77,7 +87,11 @@ type Interface interface {
 	Run(ctx context.Context) error
 }
 
-func New(port int, certs CertInfo, scheme *runtime.Scheme, validator admission.ValidationInterface) Interface {
+// Called with each review request, the response returned for it and the
+// results of the bindings evaluated for it
+type ReviewObserver func(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse, results []evaluate.BindingResult)
+
+func New(port int, certs CertInfo, scheme *runtime.Scheme, validator admission.ValidationInterface, observers ...ReviewObserver) Interface {
 	codecs := serializer.NewCodecFactory(scheme)
 	if port < 0 {
 		port = 0
@@ -88,6 +102,7 @@ func New(port int, certs CertInfo, scheme *runtime.Scheme, validator admission.V
 		decoder:          codecs.UniversalDeserializer(),
 		port:             port,
 		validator:        validator,
+		observers:        observers,
 	}
 }
 
@@ -96,6 +111,7 @@ type webhook struct {
 	port             int
 	serverPort       int
 	validator        admission.ValidationInterface
+	observers        []ReviewObserver
 	objectInferfaces admission.ObjectInterfaces
 	decoder          runtime.Decoder
 	CertInfo
@@ -115,30 +131,7 @@ func (wh *webhook) Install(client kubernetes.Interface) error {
 		ValidatingWebhookConfigurations().
 		Apply(
 			context.TODO(),
-			admissionregistrationv1apply.ValidatingWebhookConfiguration("cel-admission-polyfill.k8s.io").
-				WithWebhooks(
-					admissionregistrationv1apply.ValidatingWebhook().
-						WithName("cel-admission-polyfill.k8s.io").
-						WithRules(
-							admissionregistrationv1apply.RuleWithOperations().
-								WithScope("*").
-								WithAPIGroups("*").
-								WithAPIVersions("*").
-								WithOperations("*").
-								WithResources("*"),
-						).
-						WithAdmissionReviewVersions("v1").
-						WithClientConfig(
-							//TODO: When in cluster install a service too
-							// and use a service for this
-							admissionregistrationv1apply.WebhookClientConfig().
-								WithURL("https://127.0.0.1:"+strconv.Itoa(int(port))+"/validate").
-								WithCABundle(wh.Root...)).
-						WithSideEffects(
-							admissionregistrationv1.SideEffectClassNone).
-						//!TODO: gate for debugging
-						WithFailurePolicy(admissionregistrationv1.Ignore),
-				),
+			wh.configuration(port),
 			metav1.ApplyOptions{
 				FieldManager: "cel_polyfill_debug",
 			},
@@ -150,6 +143,67 @@ func (wh *webhook) Install(client kubernetes.Interface) error {
 	return nil
 }
 
+func (wh *webhook) Installed(config *admissionregistrationv1.ValidatingWebhookConfiguration) bool {
+	wh.lock.Lock()
+	port := wh.serverPort
+	wh.lock.Unlock()
+
+	if port == 0 {
+		return false
+	}
+
+	// Only the fields which are applied are compared, since the others are
+	// defaulted by the apiserver
+	var expected admissionregistrationv1.ValidatingWebhookConfiguration
+	if byts, err := json.Marshal(wh.configuration(port)); err != nil {
+		return false
+	} else if err := json.Unmarshal(byts, &expected); err != nil {
+		return false
+	}
+
+	for _, actual := range config.Webhooks {
+		if actual.Name != ConfigurationName {
+			continue
+		}
+
+		want := expected.Webhooks[0]
+		return equality.Semantic.DeepEqual(actual.Rules, want.Rules) &&
+			equality.Semantic.DeepEqual(actual.ClientConfig, want.ClientConfig) &&
+			equality.Semantic.DeepEqual(actual.AdmissionReviewVersions, want.AdmissionReviewVersions) &&
+			equality.Semantic.DeepEqual(actual.SideEffects, want.SideEffects) &&
+			equality.Semantic.DeepEqual(actual.FailurePolicy, want.FailurePolicy)
+	}
+	return false
+}
+
+// Returns the configuration of the webhook served on port
+func (wh *webhook) configuration(port int) *admissionregistrationv1apply.ValidatingWebhookConfigurationApplyConfiguration {
+	return admissionregistrationv1apply.ValidatingWebhookConfiguration(ConfigurationName).
+		WithWebhooks(
+			admissionregistrationv1apply.ValidatingWebhook().
+				WithName(ConfigurationName).
+				WithRules(
+					admissionregistrationv1apply.RuleWithOperations().
+						WithScope("*").
+						WithAPIGroups("*").
+						WithAPIVersions("*").
+						WithOperations("*").
+						WithResources("*"),
+				).
+				WithAdmissionReviewVersions("v1").
+				WithClientConfig(
+					//TODO: When in cluster install a service too
+					// and use a service for this
+					admissionregistrationv1apply.WebhookClientConfig().
+						WithURL("https://127.0.0.1:" + strconv.Itoa(int(port)) + "/validate").
+						WithCABundle(wh.Root...)).
+				WithSideEffects(
+					admissionregistrationv1.SideEffectClassNone).
+				//!TODO: gate for debugging
+				WithFailurePolicy(admissionregistrationv1.Ignore),
+		)
+}
+
 func (wh *webhook) createListener() (net.Listener, int, error) {
 	wh.lock.Lock()
 	defer wh.lock.Unlock()
@@ -267,6 +321,8 @@ func (wh *webhook) handleWebhookValidate(w http.ResponseWriter, req *http.Reques
 	}
 
 	err = nil
+	var warnings []string
+	var results []evaluate.BindingResult
 
 	if wh.validator.Handles(admission.Operation(parsed.Request.Operation)) {
 		var object runtime.Object
@@ -363,14 +419,20 @@ func (wh *webhook) handleWebhookValidate(w http.ResponseWriter, req *http.Reques
 				Extra:  convertExtra(parsed.Request.UserInfo.Extra),
 			})
 
-		err = wh.validator.Validate(context.TODO(), attrs, wh.objectInferfaces)
+		ctx := validator.WithRequestUID(context.TODO(), parsed.Request.UID)
+		results, warnings, err = validator.ValidateWithResults(ctx, wh.validator, attrs, wh.objectInferfaces)
 	}
 
 	response := reviewResponse(
 		parsed.Request.UID,
 		err,
+		warnings,
 	)
 
+	for _, observe := range wh.observers {
+		observe(parsed.Request, response.Response, results)
+	}
+
 	out, err := json.Marshal(response)
 	if err != nil {
 		failure(err, http.StatusInternalServerError)
@@ -403,7 +465,7 @@ func (wh *webhook) handleWebhookMutate(w http.ResponseWriter, req *http.Request)
 	w.WriteHeader(500)
 }
 
-func reviewResponse(uid types.UID, err error) *admissionv1.AdmissionReview {
+func reviewResponse(uid types.UID, err error, warnings []string) *admissionv1.AdmissionReview {
 	allowed := err == nil
 	var status int32 = http.StatusAccepted
 	if err != nil {
@@ -415,11 +477,14 @@ func reviewResponse(uid types.UID, err error) *admissionv1.AdmissionReview {
 		message = err.Error()
 	}
 
-	var statusErr *k8serrors.StatusError
-	if ok := errors.As(err, &statusErr); ok {
-		reason = statusErr.ErrStatus.Reason
-		message = statusErr.ErrStatus.Message
-		status = statusErr.ErrStatus.Code
+	var details *metav1.StatusDetails
+	var apiStatus k8serrors.APIStatus
+	if ok := errors.As(err, &apiStatus); ok {
+		errStatus := apiStatus.Status()
+		reason = errStatus.Reason
+		message = errStatus.Message
+		status = errStatus.Code
+		details = errStatus.Details
 	}
 
 	return &admissionv1.AdmissionReview{
@@ -428,17 +493,21 @@ func reviewResponse(uid types.UID, err error) *admissionv1.AdmissionReview {
 			APIVersion: "admission.k8s.io/v1",
 		},
 		Response: &admissionv1.AdmissionResponse{
-			UID:     uid,
-			Allowed: allowed,
+			UID:      uid,
+			Allowed:  allowed,
+			Warnings: warnings,
 			Result: &metav1.Status{
 				Code:    status,
 				Message: message,
 				Reason:  reason,
+				Details: details,
 			},
 		},
 	}
 }
 
+// Collects warnings added by validators so they are returned in the
+// admission response
 // parseRequest extracts an AdmissionReview from an http.Request if possible
 func parseRequest(r *http.Request) (*admissionv1.AdmissionReview, error) {
 	if r.Header.Get("Content-Type") != "application/json" {